@@ -1,8 +1,15 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"projet-devops-coveo/pkg"
+	"projet-devops-coveo/pkg/aws"
 	"projet-devops-coveo/pkg/util"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -19,13 +26,36 @@ const (
 	ORDER_BY_DEC_DEFAULT     = ""
 
 	GROUP_BY             = "group-by"
-	GROUP_BY_DESCRIPTION = "Supported: [region]"
+	GROUP_BY_DESCRIPTION = "Supported: [region, encryption, tag:<key>]. With tag:<key>, the buckets without the tag are in the untagged group"
 	GROUP_BY_DEFAULT     = ""
 
 	RETURNS_EMTPY             = "omit-empty"
 	RETURNS_EMTPY_DESCRIPTION = "Omit empty buckets"
 	RETURNS_EMTPY_DEFAULT     = false
 
+	INCLUDE_VERSIONS             = "versions"
+	INCLUDE_VERSIONS_DESCRIPTION = "Scan all the versions of the objects to account for noncurrent versions and delete markers"
+	INCLUDE_VERSIONS_DEFAULT     = false
+
+	INCLUDE_MULTIPART_UPLOADS             = "multipart-uploads"
+	INCLUDE_MULTIPART_UPLOADS_DESCRIPTION = "Account for the parts of incomplete multipart uploads (Use --multipart-uploads=false to skip)"
+	INCLUDE_MULTIPART_UPLOADS_DEFAULT     = true
+
+	INCLUDE_CONFIG             = "include-config"
+	INCLUDE_CONFIG_DESCRIPTION = "Add the configuration of the buckets to the output: versioning, lifecycle rules, replication, default encryption, object lock, logging, public access block and ownership controls"
+	INCLUDE_CONFIG_DEFAULT     = false
+
+	ENCRYPTION_SAMPLE             = "encryption-sample"
+	ENCRYPTION_SAMPLE_DESCRIPTION = "Ratio of the objects headed to get the number and the size of the objects per encryption (Ex.: 0.01, 1 for all the objects). 0 to disable. The inventory reports have the encryption of all the objects without sampling"
+	ENCRYPTION_SAMPLE_DEFAULT     = 0
+
+	SOURCE             = "source"
+	SOURCE_DESCRIPTION = "Where the objects of the buckets are read from. Supported: [list, inventory, cloudwatch]. cloudwatch estimates the buckets with the daily storage metrics without listing the objects"
+	SOURCE_DEFAULT     = util.SOURCE_LIST
+
+	INVENTORY_LOCATIONS             = "inventory"
+	INVENTORY_LOCATIONS_DESCRIPTION = "Select multiples S3 Inventory locations: manifest.json files, directories or s3://destination-bucket/prefix (Used with --source inventory)"
+
 	RATE_LIMIT             = "ratelimit"
 	RATE_LIMIT_DESCRIPTION = "Choose the rate limit on the S3 services (Max Get = 5500 per second)"
 	RATE_LIMIT_DEFAULT     = 5400
@@ -34,6 +64,69 @@ const (
 	THREADING_DESCRIPTION = "Choose the number of concurrent task for performance, This is multiply per number of regions that you want to scrap"
 	THREADING_DEFAULT     = 400
 
+	MAX_RETRIES             = "max-retries"
+	MAX_RETRIES_DESCRIPTION = "Number of retries of a call throttled by AWS or failed with a transient error"
+	MAX_RETRIES_DEFAULT     = 8
+
+	RETRY_BUDGET             = "retry-budget"
+	RETRY_BUDGET_DESCRIPTION = "Number of retries allowed for all the calls of a bucket (0 for unlimited)"
+	RETRY_BUDGET_DEFAULT     = 100
+
+	PARTITION_DEPTH             = "partition-depth"
+	PARTITION_DEPTH_DESCRIPTION = "Number of prefix levels discovered with the delimiter '/' to list a bucket with concurrent tasks (0 to disable)"
+	PARTITION_DEPTH_DEFAULT     = 1
+
+	RESUME             = "resume"
+	RESUME_DESCRIPTION = "State file where the progress of the scan is saved. If it exists, the scan resumes where it stopped"
+
+	TIMEOUT             = "timeout"
+	TIMEOUT_DESCRIPTION = "Stop the scan after this duration and output the buckets scanned so far (Ex.: 30m, 1h). 0 for no timeout"
+
+	BUCKET_TIMEOUT             = "bucket-timeout"
+	BUCKET_TIMEOUT_DESCRIPTION = "Stop the scan of a bucket after this duration, the bucket is marked incomplete (Ex.: 5m). 0 for no timeout"
+
+	LOCATION_CACHE             = "location-cache"
+	LOCATION_CACHE_DESCRIPTION = "File where the locations of the buckets are cached between the runs"
+
+	LOCATION_CACHE_TTL             = "location-cache-ttl"
+	LOCATION_CACHE_TTL_DESCRIPTION = "Duration the locations of the buckets are kept in the cache (Ex.: 24h). 0 to disable the cache"
+	LOCATION_CACHE_TTL_DEFAULT     = 30 * 24 * time.Hour
+
+	FAIL_ON_ERROR             = "fail-on-error"
+	FAIL_ON_ERROR_DESCRIPTION = "Exit with a non-zero code when a bucket or a region can't be scanned or priced completely. The output is still printed"
+	FAIL_ON_ERROR_DEFAULT     = false
+
+	PREFIX_DEPTH             = "prefix-depth"
+	PREFIX_DEPTH_DESCRIPTION = "Break down the usage of each bucket by key prefix, up to the number of / levels (0 to disable). The prefixes are a tree in the output and a table sorted by size in the terminal"
+	PREFIX_DEPTH_DEFAULT     = 0
+
+	DUPLICATES             = "duplicates"
+	DUPLICATES_DESCRIPTION = "Find the objects copied in the scanned buckets by ETag and size, with the size and the monthly cost wasted. The objects are spilled to temporary files"
+	DUPLICATES_DEFAULT     = false
+
+	TOP             = "top"
+	TOP_DESCRIPTION = "Report the N largest objects and prefixes of each bucket and of the account (0 to disable). The prefixes are the top-level ones without --prefix-depth, estimated with a bounded memory"
+	TOP_DEFAULT     = 0
+
+	LIFECYCLE_PROJECTION             = "lifecycle-projection"
+	LIFECYCLE_PROJECTION_DESCRIPTION = "Project the monthly cost of each bucket in 3, 6 and 12 months with its own lifecycle rules, from the age of the objects already stored. The tags of the objects are fetched when a rule is filtered by tags. Use --versions for the noncurrent versions"
+	LIFECYCLE_PROJECTION_DEFAULT     = false
+
+	USAGE             = "usage"
+	USAGE_DESCRIPTION = "File with the monthly requests, data retrieved and data transferred out of the buckets, in JSON or in YAML (.yaml or .yml), by bucket name with * for the others. The fees are added to the cost of the buckets"
+
+	USAGE_FROM_METRICS             = "usage-from-metrics"
+	USAGE_FROM_METRICS_DESCRIPTION = "Estimate the monthly requests and data downloaded of the buckets that are not in --usage from their CloudWatch request metrics of the last 30 days. The request metrics must be enabled on the buckets"
+	USAGE_FROM_METRICS_DEFAULT     = false
+
+	REQUEST_METRICS_FILTER             = "request-metrics-filter"
+	REQUEST_METRICS_FILTER_DESCRIPTION = "Name of the request metrics configuration of the buckets used with --usage-from-metrics"
+	REQUEST_METRICS_FILTER_DEFAULT     = "EntireBucket"
+
+	STALE_DAYS             = "stale-days"
+	STALE_DAYS_DESCRIPTION = "Report the buckets whose current objects are mostly older than the number of days, by size (0 to disable)"
+	STALE_DAYS_DEFAULT     = 0
+
 	SIZE_CONV             = "display-size"
 	SIZE_CONV_DESCRIPTION = "Display the size in: [by, kb, mb, gb, tb]"
 	SIZE_CONV_DEFAULT     = "by"
@@ -42,11 +135,29 @@ const (
 	FILTER_BY_NAME_DESCRIPTION = "Select multiples bucket name to."
 
 	FILTER_BY_STORAGE_CLASS             = "storage-class"
-	FILTER_BY_STORAGE_CLASS_DESCRIPTION = "Select multiples storage class to filter bucket contents. Supported: [STANDARD, REDUCED_REDUNDANCY, GLACIER, STANDARD_IA, INTELLIGENT_TIERING, DEEP_ARCHIVE, GLACIER_IR]"
+	FILTER_BY_STORAGE_CLASS_DESCRIPTION = "Select multiples storage class to filter bucket contents. Supported: [STANDARD, REDUCED_REDUNDANCY, GLACIER, STANDARD_IA, INTELLIGENT_TIERING, DEEP_ARCHIVE, GLACIER_IR, ONEZONE_IA, EXPRESS_ONEZONE, OUTPOSTS, SNOW]"
+
+	FILTER_BY_PREFIX             = "prefix"
+	FILTER_BY_PREFIX_DESCRIPTION = "Select multiples s3:// patterns to filter objects. Glob supported (Ex.: s3://mybucket/Folder/SubFolder/log*)"
+
+	FILTER_BY_GLOB             = "glob"
+	FILTER_BY_GLOB_DESCRIPTION = "Select multiples glob patterns to filter object keys in all buckets, * does not match / (Ex.: logs/*.log)"
+
+	FILTER_BY_REGEX             = "regex"
+	FILTER_BY_REGEX_DESCRIPTION = "Select multiples regular expressions to filter object keys in all buckets"
+
+	FILTER_BY_ENCRYPTION             = "encryption"
+	FILTER_BY_ENCRYPTION_DESCRIPTION = "Select multiples default encryption of the buckets. Supported: [SSE-S3, SSE-KMS, DSSE-KMS, none]. Buckets whose encryption can't be fetched are kept"
+
+	FILTER_BY_TAG             = "filter-tag"
+	FILTER_BY_TAG_DESCRIPTION = "Select multiples bucket tags as key=value. The buckets must have all the keys, the values of the same key are alternatives (Ex.: --filter-tag team=data --filter-tag team=web)"
 
 	BUCKET_REGIONS             = "regions"
 	BUCKET_REGIONS_DESCRIPTION = "Regions in which the bucket are created"
 
+	ALL_REGIONS             = "all-regions"
+	ALL_REGIONS_DESCRIPTION = "Scan the buckets of all the regions, found with the location of each bucket (Default when --regions is not given)"
+
 	OUTPUT             = "output"
 	OUTPUT_DESCRIPTION = "Output to a file (Enter the file name)"
 )
@@ -55,48 +166,194 @@ func NewS3Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use: "aws-s3",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			options := &util.CliOptions{
-				Regions:              viper.GetStringSlice(BUCKET_REGIONS),
-				FilterByName:         viper.GetStringSlice(FILTER_BY_NAME),
-				OmitEmpty:            viper.GetBool(RETURNS_EMTPY),
-				FilterByStorageClass: viper.GetStringSlice(FILTER_BY_STORAGE_CLASS),
-				RateLimit:            viper.GetInt(RATE_LIMIT),
-				Threading:            viper.GetInt(THREADING),
-				OutputOptions: &util.OutputOptions{
-					GroupBy:        viper.GetString(GROUP_BY),
-					OrderByInc:     viper.GetString(ORDER_BY_INC),
-					OrderByDec:     viper.GetString(ORDER_BY_DEC),
-					FileOutput:     viper.GetString(OUTPUT),
-					SizeConversion: float64(getSizeConstant(viper.GetString(SIZE_CONV))),
-				},
-			}
-			err := pkg.RunS3Command(options)
+			options, err := getS3Options(cmd)
 			if err != nil {
 				return err
 			}
-			return nil
+			// The options are valid, an error of the scan doesn't need the usage
+			cmd.SilenceUsage = true
+			return pkg.RunS3Command(cmd.Context(), options)
 		},
 	}
-	cmd.Flags().String(ORDER_BY_INC, ORDER_BY_INC_DEFAULT, ORDER_BY_INC_DESCRIPTION)
-	cmd.Flags().String(ORDER_BY_DEC, ORDER_BY_DEC_DEFAULT, ORDER_BY_DEC_DESCRIPTION)
-	cmd.Flags().String(GROUP_BY, GROUP_BY_DEFAULT, GROUP_BY_DESCRIPTION)
-	cmd.Flags().String(SIZE_CONV, SIZE_CONV_DEFAULT, SIZE_CONV_DESCRIPTION)
-	cmd.Flags().Int(RATE_LIMIT, RATE_LIMIT_DEFAULT, RATE_LIMIT_DESCRIPTION)
-	cmd.Flags().Int(THREADING, THREADING_DEFAULT, THREADING_DESCRIPTION)
-	cmd.Flags().String(OUTPUT, "", OUTPUT_DESCRIPTION)
-	cmd.Flags().Bool(RETURNS_EMTPY, RETURNS_EMTPY_DEFAULT, RETURNS_EMTPY_DESCRIPTION)
-	cmd.Flags().StringSlice(FILTER_BY_NAME, nil, FILTER_BY_NAME_DESCRIPTION)
-	cmd.Flags().StringSlice(BUCKET_REGIONS, []string{"ca-central-1"}, BUCKET_REGIONS_DESCRIPTION)
-	cmd.Flags().StringSlice(FILTER_BY_STORAGE_CLASS, nil, FILTER_BY_STORAGE_CLASS_DESCRIPTION)
-	err := viper.BindPFlags(cmd.Flags())
+	// The flags are shared with the subcommands
+	cmd.PersistentFlags().String(ORDER_BY_INC, ORDER_BY_INC_DEFAULT, ORDER_BY_INC_DESCRIPTION)
+	cmd.PersistentFlags().String(ORDER_BY_DEC, ORDER_BY_DEC_DEFAULT, ORDER_BY_DEC_DESCRIPTION)
+	cmd.PersistentFlags().String(GROUP_BY, GROUP_BY_DEFAULT, GROUP_BY_DESCRIPTION)
+	cmd.PersistentFlags().String(SIZE_CONV, SIZE_CONV_DEFAULT, SIZE_CONV_DESCRIPTION)
+	cmd.PersistentFlags().Int64(STALE_DAYS, STALE_DAYS_DEFAULT, STALE_DAYS_DESCRIPTION)
+	cmd.PersistentFlags().Int(PREFIX_DEPTH, PREFIX_DEPTH_DEFAULT, PREFIX_DEPTH_DESCRIPTION)
+	cmd.PersistentFlags().Int(TOP, TOP_DEFAULT, TOP_DESCRIPTION)
+	cmd.PersistentFlags().Bool(DUPLICATES, DUPLICATES_DEFAULT, DUPLICATES_DESCRIPTION)
+	cmd.PersistentFlags().Bool(LIFECYCLE_PROJECTION, LIFECYCLE_PROJECTION_DEFAULT, LIFECYCLE_PROJECTION_DESCRIPTION)
+	cmd.PersistentFlags().String(USAGE, "", USAGE_DESCRIPTION)
+	cmd.PersistentFlags().Bool(USAGE_FROM_METRICS, USAGE_FROM_METRICS_DEFAULT, USAGE_FROM_METRICS_DESCRIPTION)
+	cmd.PersistentFlags().String(REQUEST_METRICS_FILTER, REQUEST_METRICS_FILTER_DEFAULT, REQUEST_METRICS_FILTER_DESCRIPTION)
+	cmd.PersistentFlags().Int(RATE_LIMIT, RATE_LIMIT_DEFAULT, RATE_LIMIT_DESCRIPTION)
+	cmd.PersistentFlags().Int(THREADING, THREADING_DEFAULT, THREADING_DESCRIPTION)
+	cmd.PersistentFlags().Int(PARTITION_DEPTH, PARTITION_DEPTH_DEFAULT, PARTITION_DEPTH_DESCRIPTION)
+	cmd.PersistentFlags().Int(MAX_RETRIES, MAX_RETRIES_DEFAULT, MAX_RETRIES_DESCRIPTION)
+	cmd.PersistentFlags().Int(RETRY_BUDGET, RETRY_BUDGET_DEFAULT, RETRY_BUDGET_DESCRIPTION)
+	cmd.PersistentFlags().String(RESUME, "", RESUME_DESCRIPTION)
+	cmd.PersistentFlags().Duration(TIMEOUT, 0, TIMEOUT_DESCRIPTION)
+	cmd.PersistentFlags().Duration(BUCKET_TIMEOUT, 0, BUCKET_TIMEOUT_DESCRIPTION)
+	cmd.PersistentFlags().String(LOCATION_CACHE, defaultLocationCache(), LOCATION_CACHE_DESCRIPTION)
+	cmd.PersistentFlags().Duration(LOCATION_CACHE_TTL, LOCATION_CACHE_TTL_DEFAULT, LOCATION_CACHE_TTL_DESCRIPTION)
+	cmd.PersistentFlags().Bool(FAIL_ON_ERROR, FAIL_ON_ERROR_DEFAULT, FAIL_ON_ERROR_DESCRIPTION)
+	cmd.PersistentFlags().String(OUTPUT, "", OUTPUT_DESCRIPTION)
+	cmd.PersistentFlags().Bool(RETURNS_EMTPY, RETURNS_EMTPY_DEFAULT, RETURNS_EMTPY_DESCRIPTION)
+	cmd.PersistentFlags().Bool(INCLUDE_VERSIONS, INCLUDE_VERSIONS_DEFAULT, INCLUDE_VERSIONS_DESCRIPTION)
+	cmd.PersistentFlags().String(SOURCE, SOURCE_DEFAULT, SOURCE_DESCRIPTION)
+	cmd.PersistentFlags().StringSlice(INVENTORY_LOCATIONS, nil, INVENTORY_LOCATIONS_DESCRIPTION)
+	cmd.PersistentFlags().Bool(INCLUDE_MULTIPART_UPLOADS, INCLUDE_MULTIPART_UPLOADS_DEFAULT, INCLUDE_MULTIPART_UPLOADS_DESCRIPTION)
+	cmd.PersistentFlags().Bool(INCLUDE_CONFIG, INCLUDE_CONFIG_DEFAULT, INCLUDE_CONFIG_DESCRIPTION)
+	cmd.PersistentFlags().StringSlice(FILTER_BY_NAME, nil, FILTER_BY_NAME_DESCRIPTION)
+	cmd.PersistentFlags().StringSlice(BUCKET_REGIONS, []string{"ca-central-1"}, BUCKET_REGIONS_DESCRIPTION)
+	cmd.PersistentFlags().Bool(ALL_REGIONS, false, ALL_REGIONS_DESCRIPTION)
+	cmd.PersistentFlags().StringSlice(FILTER_BY_STORAGE_CLASS, nil, FILTER_BY_STORAGE_CLASS_DESCRIPTION)
+	cmd.PersistentFlags().StringSlice(FILTER_BY_PREFIX, nil, FILTER_BY_PREFIX_DESCRIPTION)
+	cmd.PersistentFlags().StringSlice(FILTER_BY_GLOB, nil, FILTER_BY_GLOB_DESCRIPTION)
+	cmd.PersistentFlags().StringSlice(FILTER_BY_REGEX, nil, FILTER_BY_REGEX_DESCRIPTION)
+	cmd.PersistentFlags().StringSlice(FILTER_BY_ENCRYPTION, nil, FILTER_BY_ENCRYPTION_DESCRIPTION)
+	cmd.PersistentFlags().StringSlice(FILTER_BY_TAG, nil, FILTER_BY_TAG_DESCRIPTION)
+	cmd.PersistentFlags().Float64(ENCRYPTION_SAMPLE, ENCRYPTION_SAMPLE_DEFAULT, ENCRYPTION_SAMPLE_DESCRIPTION)
+	err := viper.BindPFlags(cmd.PersistentFlags())
 	if err != nil {
 		logrus.Error(err)
 		return nil
 	}
+	cmd.AddCommand(NewSimulateCommand())
 
 	return cmd
 }
 
+// Options of the scan from the flags, the errors are the invalid combinations.
+func getS3Options(cmd *cobra.Command) (*util.CliOptions, error) {
+	options := &util.CliOptions{
+		Regions:                 viper.GetStringSlice(BUCKET_REGIONS),
+		AllRegions:              viper.GetBool(ALL_REGIONS) || !cmd.Flags().Changed(BUCKET_REGIONS) && !cmd.Flags().Changed(ALL_REGIONS),
+		FilterByName:            viper.GetStringSlice(FILTER_BY_NAME),
+		OmitEmpty:               viper.GetBool(RETURNS_EMTPY),
+		IncludeVersions:         viper.GetBool(INCLUDE_VERSIONS),
+		IncludeMultipartUploads: viper.GetBool(INCLUDE_MULTIPART_UPLOADS),
+		IncludeConfig:           viper.GetBool(INCLUDE_CONFIG),
+		FindDuplicates:          viper.GetBool(DUPLICATES),
+		UsageFromMetrics:        viper.GetBool(USAGE_FROM_METRICS),
+		RequestMetricsFilter:    viper.GetString(REQUEST_METRICS_FILTER),
+		Source:                  viper.GetString(SOURCE),
+		InventoryLocations:      viper.GetStringSlice(INVENTORY_LOCATIONS),
+		FilterByStorageClass:    viper.GetStringSlice(FILTER_BY_STORAGE_CLASS),
+		FilterByPrefix:          viper.GetStringSlice(FILTER_BY_PREFIX),
+		FilterByGlob:            viper.GetStringSlice(FILTER_BY_GLOB),
+		FilterByRegex:           viper.GetStringSlice(FILTER_BY_REGEX),
+		FilterByEncryption:      viper.GetStringSlice(FILTER_BY_ENCRYPTION),
+		FilterByTag:             viper.GetStringSlice(FILTER_BY_TAG),
+		EncryptionSample:        viper.GetFloat64(ENCRYPTION_SAMPLE),
+		RateLimit:               viper.GetInt(RATE_LIMIT),
+		Threading:               viper.GetInt(THREADING),
+		PartitionDepth:          viper.GetInt(PARTITION_DEPTH),
+		PrefixDepth:             viper.GetInt(PREFIX_DEPTH),
+		MaxRetries:              viper.GetInt(MAX_RETRIES),
+		RetryBudget:             viper.GetInt(RETRY_BUDGET),
+		ResumeState:             viper.GetString(RESUME),
+		Timeout:                 viper.GetDuration(TIMEOUT),
+		BucketTimeout:           viper.GetDuration(BUCKET_TIMEOUT),
+		LocationCache:           viper.GetString(LOCATION_CACHE),
+		LocationCacheTTL:        viper.GetDuration(LOCATION_CACHE_TTL),
+		FailOnError:             viper.GetBool(FAIL_ON_ERROR),
+		OutputOptions: &util.OutputOptions{
+			GroupBy:             viper.GetString(GROUP_BY),
+			OrderByInc:          viper.GetString(ORDER_BY_INC),
+			OrderByDec:          viper.GetString(ORDER_BY_DEC),
+			FileOutput:          viper.GetString(OUTPUT),
+			SizeConversion:      float64(getSizeConstant(viper.GetString(SIZE_CONV))),
+			StaleDays:           viper.GetInt64(STALE_DAYS),
+			Top:                 viper.GetInt(TOP),
+			LifecycleProjection: viper.GetBool(LIFECYCLE_PROJECTION),
+		},
+	}
+	if viper.GetBool(ALL_REGIONS) && cmd.Flags().Changed(BUCKET_REGIONS) {
+		return nil, fmt.Errorf("--%s can't be used with --%s", ALL_REGIONS, BUCKET_REGIONS)
+	}
+	if !slices.Contains([]string{util.SOURCE_LIST, util.SOURCE_INVENTORY, util.SOURCE_CLOUDWATCH}, options.Source) {
+		return nil, fmt.Errorf("unsupported --%s %s", SOURCE, options.Source)
+	}
+	if options.Source == util.SOURCE_INVENTORY && len(options.InventoryLocations) == 0 {
+		return nil, fmt.Errorf("--%s requires at least one --%s location", SOURCE, INVENTORY_LOCATIONS)
+	}
+	if options.Source == util.SOURCE_CLOUDWATCH && (len(options.FilterByPrefix) != 0 || len(options.FilterByGlob) != 0 || len(options.FilterByRegex) != 0) {
+		return nil, fmt.Errorf("object filters can't be used with --%s %s", SOURCE, util.SOURCE_CLOUDWATCH)
+	}
+	if options.PrefixDepth < 0 {
+		return nil, fmt.Errorf("--%s must be positive", PREFIX_DEPTH)
+	}
+	if options.Source == util.SOURCE_CLOUDWATCH && options.PrefixDepth > 0 {
+		return nil, fmt.Errorf("--%s can't be used with --%s %s", PREFIX_DEPTH, SOURCE, util.SOURCE_CLOUDWATCH)
+	}
+	if options.FindDuplicates && options.Source == util.SOURCE_CLOUDWATCH {
+		return nil, fmt.Errorf("--%s can't be used with --%s %s", DUPLICATES, SOURCE, util.SOURCE_CLOUDWATCH)
+	}
+	// The objects of the buckets scanned before the interruption are not in the index anymore
+	if options.FindDuplicates && options.ResumeState != "" {
+		return nil, fmt.Errorf("--%s can't be used with --%s", DUPLICATES, RESUME)
+	}
+	if options.OutputOptions.Top < 0 {
+		return nil, fmt.Errorf("--%s must be positive", TOP)
+	}
+	if options.Source == util.SOURCE_CLOUDWATCH && options.OutputOptions.Top > 0 {
+		return nil, fmt.Errorf("--%s can't be used with --%s %s", TOP, SOURCE, util.SOURCE_CLOUDWATCH)
+	}
+	if options.Source == util.SOURCE_CLOUDWATCH && options.OutputOptions.LifecycleProjection {
+		return nil, fmt.Errorf("--%s can't be used with --%s %s", LIFECYCLE_PROJECTION, SOURCE, util.SOURCE_CLOUDWATCH)
+	}
+	if options.OutputOptions.StaleDays < 0 {
+		return nil, fmt.Errorf("--%s must be positive", STALE_DAYS)
+	}
+	if options.Source == util.SOURCE_CLOUDWATCH && options.OutputOptions.StaleDays > 0 {
+		return nil, fmt.Errorf("--%s can't be used with --%s %s", STALE_DAYS, SOURCE, util.SOURCE_CLOUDWATCH)
+	}
+	if options.EncryptionSample < 0 || options.EncryptionSample > 1 {
+		return nil, fmt.Errorf("--%s must be between 0 and 1", ENCRYPTION_SAMPLE)
+	}
+	if options.Source == util.SOURCE_CLOUDWATCH && options.EncryptionSample > 0 {
+		return nil, fmt.Errorf("--%s can't be used with --%s %s", ENCRYPTION_SAMPLE, SOURCE, util.SOURCE_CLOUDWATCH)
+	}
+	for _, encryption := range options.FilterByEncryption {
+		if !slices.ContainsFunc([]string{util.ENCRYPTION_SSE_S3, util.ENCRYPTION_SSE_KMS, util.ENCRYPTION_DSSE_KMS, util.ENCRYPTION_NONE}, func(supported string) bool {
+			return strings.EqualFold(supported, encryption)
+		}) {
+			return nil, fmt.Errorf("unsupported --%s %s", FILTER_BY_ENCRYPTION, encryption)
+		}
+	}
+	for _, tag := range options.FilterByTag {
+		if key, _, ok := strings.Cut(tag, "="); !ok || key == "" {
+			return nil, fmt.Errorf("--%s %s must be key=value", FILTER_BY_TAG, tag)
+		}
+	}
+	if options.OutputOptions.GroupBy == util.GROUP_BY_TAG_PREFIX {
+		return nil, fmt.Errorf("--%s %s requires a tag key (Ex.: tag:team)", GROUP_BY, util.GROUP_BY_TAG_PREFIX)
+	}
+	if options.UsageFromMetrics && options.RequestMetricsFilter == "" {
+		return nil, fmt.Errorf("--%s requires a --%s", USAGE_FROM_METRICS, REQUEST_METRICS_FILTER)
+	}
+	if usagePath := viper.GetString(USAGE); usagePath != "" {
+		var err error
+		options.Usage, err = aws.LoadBucketUsage(usagePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// The location cache is in the cache directory of the user, it's disabled if there's none.
+func defaultLocationCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "projet-devops-coveo", "bucket-locations.json")
+}
+
 func getSizeConstant(size string) int {
 	switch size {
 	case "by":
@@ -34,7 +34,13 @@ func NewGuiCommand() *cobra.Command {
 				case "AWSS3":
 					frontend.RunCommand.Options.RateLimit = 5000
 					frontend.RunCommand.Options.Threading = 400
-					err := pkg.RunS3Command(frontend.RunCommand.Options)
+					frontend.RunCommand.Options.PartitionDepth = 1
+					frontend.RunCommand.Options.MaxRetries = 8
+					frontend.RunCommand.Options.RetryBudget = 100
+					frontend.RunCommand.Options.IncludeMultipartUploads = true
+					frontend.RunCommand.Options.LocationCache = defaultLocationCache()
+					frontend.RunCommand.Options.LocationCacheTTL = LOCATION_CACHE_TTL_DEFAULT
+					err := pkg.RunS3Command(cmd.Context(), frontend.RunCommand.Options)
 					if err != nil {
 						return err
 					}
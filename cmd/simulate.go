@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"projet-devops-coveo/pkg"
+	"projet-devops-coveo/pkg/aws"
+	"projet-devops-coveo/pkg/util"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	RULES             = "rules"
+	RULES_DESCRIPTION = "Lifecycle rules to simulate, in the AWS lifecycle JSON format or in the simple YAML format (.yaml or .yml). The rules apply to the current objects of the whole bucket"
+)
+
+// Scan the buckets like aws-s3 and project the lifecycle rules on their current objects.
+func NewSimulateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "simulate",
+		Short: "Compare the current monthly cost of the buckets with their cost once the lifecycle rules are applied",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options, err := getS3Options(cmd)
+			if err != nil {
+				return err
+			}
+			// The ages of the objects are needed
+			if options.Source == util.SOURCE_CLOUDWATCH {
+				return fmt.Errorf("simulate can't be used with --%s %s", SOURCE, util.SOURCE_CLOUDWATCH)
+			}
+			rulesPath := viper.GetString(RULES)
+			if rulesPath == "" {
+				return fmt.Errorf("simulate requires --%s", RULES)
+			}
+			options.SimulationRules, err = aws.LoadLifecycleRules(rulesPath)
+			if err != nil {
+				return err
+			}
+			options.OutputOptions.Simulation = true
+			// The options are valid, an error of the scan doesn't need the usage
+			cmd.SilenceUsage = true
+			return pkg.RunS3Command(cmd.Context(), options)
+		},
+	}
+	cmd.Flags().String(RULES, "", RULES_DESCRIPTION)
+	err := viper.BindPFlags(cmd.Flags())
+	if err != nil {
+		logrus.Error(err)
+		return nil
+	}
+
+	return cmd
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"os"
 	"projet-devops-coveo/cmd"
 
 	"github.com/sirupsen/logrus"
@@ -11,6 +12,7 @@ func main() {
 	err := cmdOne.Execute()
 	if err != nil {
 		logrus.Error(err)
+		os.Exit(1)
 	}
 
 }
@@ -2,35 +2,60 @@ package aws
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/pricing"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"go.uber.org/ratelimit"
 )
 
 type AwsInterface interface {
-	ListBuckets(input *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error)
-	GetBucketLocation(params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (string, error)
-	ListObjectsV2(params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
-	ListPriceLists(params *pricing.ListPriceListsInput, optFns ...func(*pricing.Options)) (*pricing.ListPriceListsOutput, error)
-	GetPriceListFileUrl(params *pricing.GetPriceListFileUrlInput, optFns ...func(*pricing.Options)) (*pricing.GetPriceListFileUrlOutput, error)
-	GetProducts(params *pricing.GetProductsInput, optFns ...func(*pricing.Options)) (*pricing.GetProductsOutput, error)
-	NewListObjectsV2Paginator(bucketName string) *s3.ListObjectsV2Paginator
-	NextPage(paginator *s3.ListObjectsV2Paginator) (*s3.ListObjectsV2Output, error)
+	ListBuckets(ctx context.Context, input *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error)
+	GetBucketLocation(ctx context.Context, params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (string, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetBucketTagging(ctx context.Context, params *s3.GetBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketTaggingOutput, error)
+	GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+	GetBucketVersioning(ctx context.Context, params *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error)
+	GetBucketLifecycleConfiguration(ctx context.Context, params *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error)
+	GetBucketReplication(ctx context.Context, params *s3.GetBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.GetBucketReplicationOutput, error)
+	GetBucketEncryption(ctx context.Context, params *s3.GetBucketEncryptionInput, optFns ...func(*s3.Options)) (*s3.GetBucketEncryptionOutput, error)
+	GetObjectLockConfiguration(ctx context.Context, params *s3.GetObjectLockConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetObjectLockConfigurationOutput, error)
+	GetBucketLogging(ctx context.Context, params *s3.GetBucketLoggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketLoggingOutput, error)
+	GetPublicAccessBlock(ctx context.Context, params *s3.GetPublicAccessBlockInput, optFns ...func(*s3.Options)) (*s3.GetPublicAccessBlockOutput, error)
+	GetBucketOwnershipControls(ctx context.Context, params *s3.GetBucketOwnershipControlsInput, optFns ...func(*s3.Options)) (*s3.GetBucketOwnershipControlsOutput, error)
+	ListPriceLists(ctx context.Context, params *pricing.ListPriceListsInput, optFns ...func(*pricing.Options)) (*pricing.ListPriceListsOutput, error)
+	GetPriceListFileUrl(ctx context.Context, params *pricing.GetPriceListFileUrlInput, optFns ...func(*pricing.Options)) (*pricing.GetPriceListFileUrlOutput, error)
+	GetProducts(ctx context.Context, params *pricing.GetProductsInput, optFns ...func(*pricing.Options)) (*pricing.GetProductsOutput, error)
+	GetMetricData(ctx context.Context, params *cloudwatch.GetMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricDataOutput, error)
+	NewListObjectsV2Paginator(params *s3.ListObjectsV2Input) *s3.ListObjectsV2Paginator
+	NextPage(ctx context.Context, paginator *s3.ListObjectsV2Paginator) (*s3.ListObjectsV2Output, error)
 	HasMorePages(paginator *s3.ListObjectsV2Paginator) bool
+	NewListObjectVersionsPaginator(params *s3.ListObjectVersionsInput) *s3.ListObjectVersionsPaginator
+	NextVersionsPage(ctx context.Context, paginator *s3.ListObjectVersionsPaginator) (*s3.ListObjectVersionsOutput, error)
+	HasMoreVersionsPages(paginator *s3.ListObjectVersionsPaginator) bool
+	NewListMultipartUploadsPaginator(params *s3.ListMultipartUploadsInput) *s3.ListMultipartUploadsPaginator
+	NextMultipartUploadsPage(ctx context.Context, paginator *s3.ListMultipartUploadsPaginator) (*s3.ListMultipartUploadsOutput, error)
+	HasMoreMultipartUploadsPages(paginator *s3.ListMultipartUploadsPaginator) bool
+	NewListPartsPaginator(params *s3.ListPartsInput) *s3.ListPartsPaginator
+	NextPartsPage(ctx context.Context, paginator *s3.ListPartsPaginator) (*s3.ListPartsOutput, error)
+	HasMorePartsPages(paginator *s3.ListPartsPaginator) bool
 }
 
 type AwsClient struct {
-	s3      *s3.Client
-	limiter ratelimit.Limiter
-	pricing *pricing.Client
-	ctx     context.Context
+	s3         *s3.Client
+	retryer    *Retryer
+	pricing    *pricing.Client
+	cloudwatch *cloudwatch.Client
 }
 
-func NewAwsClient(region string, limiter ratelimit.Limiter) (AwsInterface, error) {
-	cfg, err := config.LoadDefaultConfig(context.Background())
+// The retries of the SDK are disabled, the retryer handles them for all the clients.
+// A failed page leaves the paginator as is, so NextPage can be retried.
+func NewAwsClient(ctx context.Context, region string, retryer *Retryer) (AwsInterface, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		panic("configuration error, " + err.Error())
 	}
@@ -38,62 +63,203 @@ func NewAwsClient(region string, limiter ratelimit.Limiter) (AwsInterface, error
 		s3: s3.New(s3.Options{
 			Region:      region,
 			Credentials: cfg.Credentials,
+			Retryer:     aws.NopRetryer{},
 		}),
-		ctx:     context.Background(),
-		limiter: limiter,
+		retryer: retryer,
 		pricing: pricing.New(pricing.Options{
 			Region:      "us-east-1",
 			Credentials: cfg.Credentials,
+			Retryer:     aws.NopRetryer{},
+		}),
+		cloudwatch: cloudwatch.New(cloudwatch.Options{
+			Region:      region,
+			Credentials: cfg.Credentials,
+			Retryer:     aws.NopRetryer{},
 		}),
 	}, nil
 }
 
-func (a *AwsClient) ListBuckets(input *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error) {
-	a.limiter.Take()
-	return a.s3.ListBuckets(a.ctx, input, optFns...)
+func (a *AwsClient) ListBuckets(ctx context.Context, input *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.ListBucketsOutput, error) {
+		return a.s3.ListBuckets(ctx, input, optFns...)
+	})
+}
+
+// Client in the region of the bucket, the location is fetched with the client given.
+func NewAwsClientForBucket(ctx context.Context, awsClient AwsInterface, bucket string, retryer *Retryer) (AwsInterface, error) {
+	region, err := awsClient.GetBucketLocation(ctx, &s3.GetBucketLocationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the location of bucket %s: %w", bucket, err)
+	}
+	return NewAwsClient(ctx, region, retryer)
 }
 
-func (a *AwsClient) GetBucketLocation(params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (string, error) {
-	a.limiter.Take()
-	output, err := a.s3.GetBucketLocation(a.ctx, params, optFns...)
+func (a *AwsClient) GetBucketLocation(ctx context.Context, params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (string, error) {
+	output, err := retryCall(ctx, a.retryer, func() (*s3.GetBucketLocationOutput, error) {
+		return a.s3.GetBucketLocation(ctx, params, optFns...)
+	})
 	if err != nil {
 		return "", err
 	}
 	return GetBucketLocationConstant(output.LocationConstraint), nil
 }
 
-func (a *AwsClient) ListObjectsV2(params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
-	a.limiter.Take()
-	return a.s3.ListObjectsV2(a.ctx, params, optFns...)
+func (a *AwsClient) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.ListObjectsV2Output, error) {
+		return a.s3.ListObjectsV2(ctx, params, optFns...)
+	})
+}
+
+func (a *AwsClient) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.GetObjectOutput, error) {
+		return a.s3.GetObject(ctx, params, optFns...)
+	})
+}
+
+func (a *AwsClient) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.HeadObjectOutput, error) {
+		return a.s3.HeadObject(ctx, params, optFns...)
+	})
 }
 
-func (a *AwsClient) ListPriceLists(params *pricing.ListPriceListsInput, optFns ...func(*pricing.Options)) (*pricing.ListPriceListsOutput, error) {
-	a.limiter.Take()
-	return a.pricing.ListPriceLists(a.ctx, params, optFns...)
+func (a *AwsClient) GetBucketTagging(ctx context.Context, params *s3.GetBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketTaggingOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.GetBucketTaggingOutput, error) {
+		return a.s3.GetBucketTagging(ctx, params, optFns...)
+	})
 }
 
-func (a *AwsClient) GetPriceListFileUrl(params *pricing.GetPriceListFileUrlInput, optFns ...func(*pricing.Options)) (*pricing.GetPriceListFileUrlOutput, error) {
-	a.limiter.Take()
-	return a.pricing.GetPriceListFileUrl(a.ctx, params, optFns...)
+func (a *AwsClient) GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.GetObjectTaggingOutput, error) {
+		return a.s3.GetObjectTagging(ctx, params, optFns...)
+	})
 }
 
-func (a *AwsClient) GetProducts(params *pricing.GetProductsInput, optFns ...func(*pricing.Options)) (*pricing.GetProductsOutput, error) {
-	a.limiter.Take()
-	return a.pricing.GetProducts(a.ctx, params, optFns...)
+func (a *AwsClient) GetBucketVersioning(ctx context.Context, params *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.GetBucketVersioningOutput, error) {
+		return a.s3.GetBucketVersioning(ctx, params, optFns...)
+	})
 }
 
-func (a *AwsClient) NewListObjectsV2Paginator(bucketName string) *s3.ListObjectsV2Paginator {
-	a.limiter.Take()
-	return s3.NewListObjectsV2Paginator(a.s3, &s3.ListObjectsV2Input{
-		Bucket: aws.String(bucketName),
+func (a *AwsClient) GetBucketLifecycleConfiguration(ctx context.Context, params *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.GetBucketLifecycleConfigurationOutput, error) {
+		return a.s3.GetBucketLifecycleConfiguration(ctx, params, optFns...)
 	})
 }
 
-func (a *AwsClient) NextPage(paginator *s3.ListObjectsV2Paginator) (*s3.ListObjectsV2Output, error) {
-	a.limiter.Take()
-	return paginator.NextPage(a.ctx)
+func (a *AwsClient) GetBucketReplication(ctx context.Context, params *s3.GetBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.GetBucketReplicationOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.GetBucketReplicationOutput, error) {
+		return a.s3.GetBucketReplication(ctx, params, optFns...)
+	})
+}
+
+func (a *AwsClient) GetBucketEncryption(ctx context.Context, params *s3.GetBucketEncryptionInput, optFns ...func(*s3.Options)) (*s3.GetBucketEncryptionOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.GetBucketEncryptionOutput, error) {
+		return a.s3.GetBucketEncryption(ctx, params, optFns...)
+	})
+}
+
+func (a *AwsClient) GetObjectLockConfiguration(ctx context.Context, params *s3.GetObjectLockConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetObjectLockConfigurationOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.GetObjectLockConfigurationOutput, error) {
+		return a.s3.GetObjectLockConfiguration(ctx, params, optFns...)
+	})
+}
+
+func (a *AwsClient) GetBucketLogging(ctx context.Context, params *s3.GetBucketLoggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketLoggingOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.GetBucketLoggingOutput, error) {
+		return a.s3.GetBucketLogging(ctx, params, optFns...)
+	})
+}
+
+func (a *AwsClient) GetPublicAccessBlock(ctx context.Context, params *s3.GetPublicAccessBlockInput, optFns ...func(*s3.Options)) (*s3.GetPublicAccessBlockOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.GetPublicAccessBlockOutput, error) {
+		return a.s3.GetPublicAccessBlock(ctx, params, optFns...)
+	})
+}
+
+func (a *AwsClient) GetBucketOwnershipControls(ctx context.Context, params *s3.GetBucketOwnershipControlsInput, optFns ...func(*s3.Options)) (*s3.GetBucketOwnershipControlsOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.GetBucketOwnershipControlsOutput, error) {
+		return a.s3.GetBucketOwnershipControls(ctx, params, optFns...)
+	})
+}
+
+func (a *AwsClient) ListPriceLists(ctx context.Context, params *pricing.ListPriceListsInput, optFns ...func(*pricing.Options)) (*pricing.ListPriceListsOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*pricing.ListPriceListsOutput, error) {
+		return a.pricing.ListPriceLists(ctx, params, optFns...)
+	})
+}
+
+func (a *AwsClient) GetPriceListFileUrl(ctx context.Context, params *pricing.GetPriceListFileUrlInput, optFns ...func(*pricing.Options)) (*pricing.GetPriceListFileUrlOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*pricing.GetPriceListFileUrlOutput, error) {
+		return a.pricing.GetPriceListFileUrl(ctx, params, optFns...)
+	})
+}
+
+func (a *AwsClient) GetProducts(ctx context.Context, params *pricing.GetProductsInput, optFns ...func(*pricing.Options)) (*pricing.GetProductsOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*pricing.GetProductsOutput, error) {
+		return a.pricing.GetProducts(ctx, params, optFns...)
+	})
+}
+
+func (a *AwsClient) GetMetricData(ctx context.Context, params *cloudwatch.GetMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricDataOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*cloudwatch.GetMetricDataOutput, error) {
+		return a.cloudwatch.GetMetricData(ctx, params, optFns...)
+	})
+}
+
+func (a *AwsClient) NewListObjectsV2Paginator(params *s3.ListObjectsV2Input) *s3.ListObjectsV2Paginator {
+	return s3.NewListObjectsV2Paginator(a.s3, params)
+}
+
+func (a *AwsClient) NextPage(ctx context.Context, paginator *s3.ListObjectsV2Paginator) (*s3.ListObjectsV2Output, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.ListObjectsV2Output, error) {
+		return paginator.NextPage(ctx)
+	})
 }
 
 func (a *AwsClient) HasMorePages(paginator *s3.ListObjectsV2Paginator) bool {
 	return paginator.HasMorePages()
 }
+
+func (a *AwsClient) NewListObjectVersionsPaginator(params *s3.ListObjectVersionsInput) *s3.ListObjectVersionsPaginator {
+	return s3.NewListObjectVersionsPaginator(a.s3, params)
+}
+
+func (a *AwsClient) NextVersionsPage(ctx context.Context, paginator *s3.ListObjectVersionsPaginator) (*s3.ListObjectVersionsOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.ListObjectVersionsOutput, error) {
+		return paginator.NextPage(ctx)
+	})
+}
+
+func (a *AwsClient) HasMoreVersionsPages(paginator *s3.ListObjectVersionsPaginator) bool {
+	return paginator.HasMorePages()
+}
+
+func (a *AwsClient) NewListMultipartUploadsPaginator(params *s3.ListMultipartUploadsInput) *s3.ListMultipartUploadsPaginator {
+	return s3.NewListMultipartUploadsPaginator(a.s3, params)
+}
+
+func (a *AwsClient) NextMultipartUploadsPage(ctx context.Context, paginator *s3.ListMultipartUploadsPaginator) (*s3.ListMultipartUploadsOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.ListMultipartUploadsOutput, error) {
+		return paginator.NextPage(ctx)
+	})
+}
+
+func (a *AwsClient) HasMoreMultipartUploadsPages(paginator *s3.ListMultipartUploadsPaginator) bool {
+	return paginator.HasMorePages()
+}
+
+func (a *AwsClient) NewListPartsPaginator(params *s3.ListPartsInput) *s3.ListPartsPaginator {
+	return s3.NewListPartsPaginator(a.s3, params)
+}
+
+func (a *AwsClient) NextPartsPage(ctx context.Context, paginator *s3.ListPartsPaginator) (*s3.ListPartsOutput, error) {
+	return retryCall(ctx, a.retryer, func() (*s3.ListPartsOutput, error) {
+		return paginator.NextPage(ctx)
+	})
+}
+
+func (a *AwsClient) HasMorePartsPages(paginator *s3.ListPartsPaginator) bool {
+	return paginator.HasMorePages()
+}
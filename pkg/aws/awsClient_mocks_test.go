@@ -0,0 +1,478 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"projet-devops-coveo/pkg/util"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/pricing"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// In memory implementation of AwsInterface used by the tests.
+type AwsClientMock struct {
+	Buckets         []types.Bucket
+	BucketLocations map[string]string
+	Objects         map[string][]types.Object
+	ObjectVersions  map[string][]types.ObjectVersion
+	DeleteMarkers   map[string][]types.DeleteMarkerEntry
+	Uploads         map[string][]types.MultipartUpload
+	Parts           map[string][]types.Part
+	ObjectsData     map[string][]byte
+	Metrics         map[string]map[string]float64
+	ListErrors      map[string]error
+	Configs         map[string]*BucketConfigMock
+	Encryptions     map[string]types.ServerSideEncryption
+	Tags            map[string]map[string]string
+	ObjectTags      map[string]map[string]string
+	PageSize        int
+}
+
+// Configuration of a bucket. A configuration that isn't set fails with the error code of AWS,
+// Errors fails a call by operation name (Ex.: GetBucketReplication).
+type BucketConfigMock struct {
+	Versioning        *s3.GetBucketVersioningOutput
+	Lifecycle         *s3.GetBucketLifecycleConfigurationOutput
+	Replication       *s3.GetBucketReplicationOutput
+	Encryption        *s3.GetBucketEncryptionOutput
+	ObjectLock        *s3.GetObjectLockConfigurationOutput
+	Logging           *s3.GetBucketLoggingOutput
+	PublicAccessBlock *s3.GetPublicAccessBlockOutput
+	OwnershipControls *s3.GetBucketOwnershipControlsOutput
+	Errors            map[string]error
+}
+
+// Implements the api clients of the paginators of the SDK.
+type s3ApiMock struct {
+	mock *AwsClientMock
+}
+
+func (m *AwsClientMock) pageSize() int {
+	if m.PageSize <= 0 {
+		return 1000
+	}
+	return m.PageSize
+}
+
+func (m *AwsClientMock) ListBuckets(ctx context.Context, input *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error) {
+	return &s3.ListBucketsOutput{Buckets: m.Buckets}, nil
+}
+
+func (m *AwsClientMock) GetBucketLocation(ctx context.Context, params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (string, error) {
+	location, ok := m.BucketLocations[*params.Bucket]
+	if !ok {
+		return "", fmt.Errorf("NoSuchBucket: %s", *params.Bucket)
+	}
+	return location, nil
+}
+
+func (m *AwsClientMock) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	return (&s3ApiMock{mock: m}).ListObjectsV2(ctx, params, optFns...)
+}
+
+// Content of the objects is stored by bucket/key.
+func (m *AwsClientMock) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := m.ObjectsData[*params.Bucket+"/"+*params.Key]
+	if !ok {
+		return nil, fmt.Errorf("NoSuchKey: %s", *params.Key)
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+// The encryption of the objects is stored by bucket/key, SSE-S3 by default.
+func (m *AwsClientMock) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(m.Objects[*params.Bucket], func(obj types.Object) bool {
+		return *obj.Key == *params.Key
+	})
+	if i < 0 {
+		return nil, fmt.Errorf("NotFound: %s", *params.Key)
+	}
+	encryption, ok := m.Encryptions[*params.Bucket+"/"+*params.Key]
+	if !ok {
+		encryption = types.ServerSideEncryptionAes256
+	}
+	if encryption == util.ENCRYPTION_SSE_C {
+		// An object encrypted with SSE-C can't be headed without its key, like AWS.
+		return nil, &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusBadRequest}},
+			Err:      &smithy.GenericAPIError{Code: "BadRequest"},
+		}
+	}
+	output := &s3.HeadObjectOutput{ServerSideEncryption: encryption}
+	if encryption == types.ServerSideEncryptionAwsKms {
+		output.SSEKMSKeyId = aws.String("arn:aws:kms:ca-central-1:123456789012:key/" + *params.Bucket)
+	}
+	return output, nil
+}
+
+// A bucket without tags fails like AWS.
+func (m *AwsClientMock) GetBucketTagging(ctx context.Context, params *s3.GetBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketTaggingOutput, error) {
+	tags, ok := m.Tags[*params.Bucket]
+	if !ok {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchTagSet"}
+	}
+	output := &s3.GetBucketTaggingOutput{}
+	for k, v := range tags {
+		output.TagSet = append(output.TagSet, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return output, nil
+}
+
+// The tags of the objects are stored by bucket/key, an object without tags has an empty tag set.
+func (m *AwsClientMock) GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	output := &s3.GetObjectTaggingOutput{TagSet: []types.Tag{}}
+	for k, v := range m.ObjectTags[*params.Bucket+"/"+*params.Key] {
+		output.TagSet = append(output.TagSet, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return output, nil
+}
+
+func (m *AwsClientMock) bucketConfig(bucketName string, operation string) (*BucketConfigMock, error) {
+	config, ok := m.Configs[bucketName]
+	if !ok {
+		return &BucketConfigMock{}, nil
+	}
+	return config, config.Errors[operation]
+}
+
+func (m *AwsClientMock) GetBucketVersioning(ctx context.Context, params *s3.GetBucketVersioningInput, optFns ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error) {
+	config, err := m.bucketConfig(*params.Bucket, "GetBucketVersioning")
+	if err != nil {
+		return nil, err
+	}
+	if config.Versioning == nil {
+		return &s3.GetBucketVersioningOutput{}, nil
+	}
+	return config.Versioning, nil
+}
+
+func (m *AwsClientMock) GetBucketLifecycleConfiguration(ctx context.Context, params *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	config, err := m.bucketConfig(*params.Bucket, "GetBucketLifecycleConfiguration")
+	if err != nil {
+		return nil, err
+	}
+	if config.Lifecycle == nil {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchLifecycleConfiguration"}
+	}
+	return config.Lifecycle, nil
+}
+
+func (m *AwsClientMock) GetBucketReplication(ctx context.Context, params *s3.GetBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.GetBucketReplicationOutput, error) {
+	config, err := m.bucketConfig(*params.Bucket, "GetBucketReplication")
+	if err != nil {
+		return nil, err
+	}
+	if config.Replication == nil {
+		return nil, &smithy.GenericAPIError{Code: "ReplicationConfigurationNotFoundError"}
+	}
+	return config.Replication, nil
+}
+
+func (m *AwsClientMock) GetBucketEncryption(ctx context.Context, params *s3.GetBucketEncryptionInput, optFns ...func(*s3.Options)) (*s3.GetBucketEncryptionOutput, error) {
+	config, err := m.bucketConfig(*params.Bucket, "GetBucketEncryption")
+	if err != nil {
+		return nil, err
+	}
+	if config.Encryption == nil {
+		return nil, &smithy.GenericAPIError{Code: "ServerSideEncryptionConfigurationNotFoundError"}
+	}
+	return config.Encryption, nil
+}
+
+func (m *AwsClientMock) GetObjectLockConfiguration(ctx context.Context, params *s3.GetObjectLockConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetObjectLockConfigurationOutput, error) {
+	config, err := m.bucketConfig(*params.Bucket, "GetObjectLockConfiguration")
+	if err != nil {
+		return nil, err
+	}
+	if config.ObjectLock == nil {
+		return nil, &smithy.GenericAPIError{Code: "ObjectLockConfigurationNotFoundError"}
+	}
+	return config.ObjectLock, nil
+}
+
+func (m *AwsClientMock) GetBucketLogging(ctx context.Context, params *s3.GetBucketLoggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketLoggingOutput, error) {
+	config, err := m.bucketConfig(*params.Bucket, "GetBucketLogging")
+	if err != nil {
+		return nil, err
+	}
+	if config.Logging == nil {
+		return &s3.GetBucketLoggingOutput{}, nil
+	}
+	return config.Logging, nil
+}
+
+func (m *AwsClientMock) GetPublicAccessBlock(ctx context.Context, params *s3.GetPublicAccessBlockInput, optFns ...func(*s3.Options)) (*s3.GetPublicAccessBlockOutput, error) {
+	config, err := m.bucketConfig(*params.Bucket, "GetPublicAccessBlock")
+	if err != nil {
+		return nil, err
+	}
+	if config.PublicAccessBlock == nil {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchPublicAccessBlockConfiguration"}
+	}
+	return config.PublicAccessBlock, nil
+}
+
+func (m *AwsClientMock) GetBucketOwnershipControls(ctx context.Context, params *s3.GetBucketOwnershipControlsInput, optFns ...func(*s3.Options)) (*s3.GetBucketOwnershipControlsOutput, error) {
+	config, err := m.bucketConfig(*params.Bucket, "GetBucketOwnershipControls")
+	if err != nil {
+		return nil, err
+	}
+	if config.OwnershipControls == nil {
+		return nil, &smithy.GenericAPIError{Code: "OwnershipControlsNotFoundError"}
+	}
+	return config.OwnershipControls, nil
+}
+
+func (m *AwsClientMock) ListPriceLists(ctx context.Context, params *pricing.ListPriceListsInput, optFns ...func(*pricing.Options)) (*pricing.ListPriceListsOutput, error) {
+	return &pricing.ListPriceListsOutput{}, nil
+}
+
+func (m *AwsClientMock) GetPriceListFileUrl(ctx context.Context, params *pricing.GetPriceListFileUrlInput, optFns ...func(*pricing.Options)) (*pricing.GetPriceListFileUrlOutput, error) {
+	return &pricing.GetPriceListFileUrlOutput{}, nil
+}
+
+func (m *AwsClientMock) GetProducts(ctx context.Context, params *pricing.GetProductsInput, optFns ...func(*pricing.Options)) (*pricing.GetProductsOutput, error) {
+	return &pricing.GetProductsOutput{}, nil
+}
+
+// Metrics are stored by bucket then by MetricName/StorageType, or MetricName/FilterId for the request metrics.
+func (m *AwsClientMock) GetMetricData(ctx context.Context, params *cloudwatch.GetMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricDataOutput, error) {
+	output := &cloudwatch.GetMetricDataOutput{}
+	for _, query := range params.MetricDataQueries {
+		dimensions := make(map[string]string)
+		for _, dimension := range query.MetricStat.Metric.Dimensions {
+			dimensions[*dimension.Name] = *dimension.Value
+		}
+		result := cwtypes.MetricDataResult{Id: query.Id}
+		value, ok := m.Metrics[dimensions["BucketName"]][*query.MetricStat.Metric.MetricName+"/"+dimensions["StorageType"]+dimensions["FilterId"]]
+		if ok {
+			result.Values = []float64{value}
+			result.Timestamps = []time.Time{time.Now()}
+		}
+		output.MetricDataResults = append(output.MetricDataResults, result)
+	}
+	return output, nil
+}
+
+func (m *AwsClientMock) NewListObjectsV2Paginator(params *s3.ListObjectsV2Input) *s3.ListObjectsV2Paginator {
+	return s3.NewListObjectsV2Paginator(&s3ApiMock{mock: m}, params)
+}
+
+func (m *AwsClientMock) NextPage(ctx context.Context, paginator *s3.ListObjectsV2Paginator) (*s3.ListObjectsV2Output, error) {
+	return paginator.NextPage(ctx)
+}
+
+func (m *AwsClientMock) HasMorePages(paginator *s3.ListObjectsV2Paginator) bool {
+	return paginator.HasMorePages()
+}
+
+func (m *AwsClientMock) NewListObjectVersionsPaginator(params *s3.ListObjectVersionsInput) *s3.ListObjectVersionsPaginator {
+	return s3.NewListObjectVersionsPaginator(&s3ApiMock{mock: m}, params)
+}
+
+func (m *AwsClientMock) NextVersionsPage(ctx context.Context, paginator *s3.ListObjectVersionsPaginator) (*s3.ListObjectVersionsOutput, error) {
+	return paginator.NextPage(ctx)
+}
+
+func (m *AwsClientMock) HasMoreVersionsPages(paginator *s3.ListObjectVersionsPaginator) bool {
+	return paginator.HasMorePages()
+}
+
+func (m *AwsClientMock) NewListMultipartUploadsPaginator(params *s3.ListMultipartUploadsInput) *s3.ListMultipartUploadsPaginator {
+	return s3.NewListMultipartUploadsPaginator(&s3ApiMock{mock: m}, params)
+}
+
+func (m *AwsClientMock) NextMultipartUploadsPage(ctx context.Context, paginator *s3.ListMultipartUploadsPaginator) (*s3.ListMultipartUploadsOutput, error) {
+	return paginator.NextPage(ctx)
+}
+
+func (m *AwsClientMock) HasMoreMultipartUploadsPages(paginator *s3.ListMultipartUploadsPaginator) bool {
+	return paginator.HasMorePages()
+}
+
+func (m *AwsClientMock) NewListPartsPaginator(params *s3.ListPartsInput) *s3.ListPartsPaginator {
+	return s3.NewListPartsPaginator(&s3ApiMock{mock: m}, params)
+}
+
+func (m *AwsClientMock) NextPartsPage(ctx context.Context, paginator *s3.ListPartsPaginator) (*s3.ListPartsOutput, error) {
+	return paginator.NextPage(ctx)
+}
+
+func (m *AwsClientMock) HasMorePartsPages(paginator *s3.ListPartsPaginator) bool {
+	return paginator.HasMorePages()
+}
+
+// A cancelled context fails the calls like the SDK does, ListErrors fails the listing of a bucket (Ex.: AccessDenied).
+// With a delimiter, the keys that contain it after the prefix are rolled up in common prefixes.
+// Objects and common prefixes are paged together, the continuation token being the index of the next entry.
+func (api *s3ApiMock) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err, ok := api.mock.ListErrors[*params.Bucket]; ok {
+		return nil, err
+	}
+	prefix := aws.ToString(params.Prefix)
+	delimiter := aws.ToString(params.Delimiter)
+	var objects []types.Object
+	var commonPrefixes []string
+	for _, obj := range api.mock.Objects[*params.Bucket] {
+		if !strings.HasPrefix(*obj.Key, prefix) {
+			continue
+		}
+		if i := strings.Index(strings.TrimPrefix(*obj.Key, prefix), delimiter); delimiter != "" && i >= 0 {
+			commonPrefix := (*obj.Key)[:len(prefix)+i+len(delimiter)]
+			if !slices.Contains(commonPrefixes, commonPrefix) {
+				commonPrefixes = append(commonPrefixes, commonPrefix)
+			}
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	start, err := parseMockToken(params.ContinuationToken)
+	if err != nil {
+		return nil, err
+	}
+	total := len(objects) + len(commonPrefixes)
+	end := min(start+api.mock.pageSize(), total)
+	output := &s3.ListObjectsV2Output{
+		IsTruncated: aws.Bool(end < total),
+	}
+	for i := start; i < end; i++ {
+		if i < len(objects) {
+			output.Contents = append(output.Contents, objects[i])
+		} else {
+			output.CommonPrefixes = append(output.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(commonPrefixes[i-len(objects)])})
+		}
+	}
+	if end < total {
+		output.NextContinuationToken = aws.String(strconv.Itoa(end))
+	}
+	return output, nil
+}
+
+// Versions and delete markers are paged together in the order of S3, by key and from the newest. The key marker is the
+// index of the next entry.
+func (api *s3ApiMock) ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	type entry struct {
+		key          string
+		lastModified time.Time
+		version      *types.ObjectVersion
+		marker       *types.DeleteMarkerEntry
+	}
+	var entries []entry
+	for _, version := range api.mock.ObjectVersions[*params.Bucket] {
+		if strings.HasPrefix(*version.Key, aws.ToString(params.Prefix)) {
+			entries = append(entries, entry{key: *version.Key, lastModified: aws.ToTime(version.LastModified), version: &version})
+		}
+	}
+	for _, marker := range api.mock.DeleteMarkers[*params.Bucket] {
+		if strings.HasPrefix(*marker.Key, aws.ToString(params.Prefix)) {
+			entries = append(entries, entry{key: *marker.Key, lastModified: aws.ToTime(marker.LastModified), marker: &marker})
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		if a.key != b.key {
+			return strings.Compare(a.key, b.key)
+		}
+		return b.lastModified.Compare(a.lastModified)
+	})
+	start, err := parseMockToken(params.KeyMarker)
+	if err != nil {
+		return nil, err
+	}
+	end := min(start+api.mock.pageSize(), len(entries))
+	output := &s3.ListObjectVersionsOutput{
+		IsTruncated: aws.Bool(end < len(entries)),
+	}
+	for _, entry := range entries[start:end] {
+		if entry.version != nil {
+			output.Versions = append(output.Versions, *entry.version)
+		} else {
+			output.DeleteMarkers = append(output.DeleteMarkers, *entry.marker)
+		}
+	}
+	if end < len(entries) {
+		output.NextKeyMarker = aws.String(strconv.Itoa(end))
+	}
+	return output, nil
+}
+
+func (api *s3ApiMock) ListMultipartUploads(ctx context.Context, params *s3.ListMultipartUploadsInput, optFns ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var uploads []types.MultipartUpload
+	for _, upload := range api.mock.Uploads[*params.Bucket] {
+		if strings.HasPrefix(*upload.Key, aws.ToString(params.Prefix)) {
+			uploads = append(uploads, upload)
+		}
+	}
+	start, err := parseMockToken(params.KeyMarker)
+	if err != nil {
+		return nil, err
+	}
+	end := min(start+api.mock.pageSize(), len(uploads))
+	output := &s3.ListMultipartUploadsOutput{
+		Uploads:     uploads[start:end],
+		IsTruncated: aws.Bool(end < len(uploads)),
+	}
+	if end < len(uploads) {
+		output.NextKeyMarker = aws.String(strconv.Itoa(end))
+	}
+	return output, nil
+}
+
+// Parts are stored by upload id.
+func (api *s3ApiMock) ListParts(ctx context.Context, params *s3.ListPartsInput, optFns ...func(*s3.Options)) (*s3.ListPartsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	parts, ok := api.mock.Parts[*params.UploadId]
+	if !ok {
+		return nil, fmt.Errorf("NoSuchUpload: %s", *params.UploadId)
+	}
+	start, err := parseMockToken(params.PartNumberMarker)
+	if err != nil {
+		return nil, err
+	}
+	end := min(start+api.mock.pageSize(), len(parts))
+	output := &s3.ListPartsOutput{
+		Parts:       parts[start:end],
+		IsTruncated: aws.Bool(end < len(parts)),
+	}
+	if end < len(parts) {
+		output.NextPartNumberMarker = aws.String(strconv.Itoa(end))
+	}
+	return output, nil
+}
+
+func parseMockToken(token *string) (int, error) {
+	if token == nil {
+		return 0, nil
+	}
+	return strconv.Atoi(*token)
+}
@@ -1,9 +1,14 @@
 package aws
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
@@ -30,11 +35,11 @@ func InitConnectionPricingList(awsClient AwsInterface) *AwsPricing {
 	}
 }
 
-// Get skus of AmazonS3 storage products
-func (ap *AwsPricing) GetSkusForRegions(regions []string) (RegionSkuList, error) {
+// Get skus of AmazonS3 storage, request, retrieval, monitoring and transfer products
+func (ap *AwsPricing) GetSkusForRegions(ctx context.Context, regions []string) (RegionSkuList, error) {
 	var regionSkuList = make(RegionSkuList)
 	for _, region := range regions {
-		results, err := ap.Session.ListPriceLists(&pricing.ListPriceListsInput{
+		results, err := ap.Session.ListPriceLists(ctx, &pricing.ListPriceListsInput{
 			RegionCode:    aws.String(region),
 			ServiceCode:   aws.String("AmazonS3"),
 			CurrencyCode:  aws.String("USD"),
@@ -45,7 +50,7 @@ func (ap *AwsPricing) GetSkusForRegions(regions []string) (RegionSkuList, error)
 		}
 		var priceslist []Product
 		for _, priceList := range results.PriceLists {
-			productPrices, err := ap.getProductWithArn(priceList.PriceListArn)
+			productPrices, err := ap.getProductWithArn(ctx, priceList.PriceListArn)
 			if err != nil {
 				return nil, err
 			}
@@ -58,18 +63,23 @@ func (ap *AwsPricing) GetSkusForRegions(regions []string) (RegionSkuList, error)
 }
 
 // Get PriceList of a product with an arn provided by AWS. Returns a list of products.
-func (ap *AwsPricing) getProductWithArn(priceListArn *string) (list []Product, err error) {
-	results, err := ap.Session.GetPriceListFileUrl(&pricing.GetPriceListFileUrlInput{
+func (ap *AwsPricing) getProductWithArn(ctx context.Context, priceListArn *string) (list []Product, err error) {
+	results, err := ap.Session.GetPriceListFileUrl(ctx, &pricing.GetPriceListFileUrlInput{
 		FileFormat:   aws.String("json"),
 		PriceListArn: priceListArn,
 	})
 	if err != nil {
 		return nil, err
 	}
-	resp, err := http.Get(*results.Url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *results.Url, nil)
 	if err != nil {
 		return nil, err
 	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -80,7 +90,7 @@ func (ap *AwsPricing) getProductWithArn(priceListArn *string) (list []Product, e
 		return nil, err
 	}
 	for _, product := range products.Products {
-		if product.ProductFamily == "Storage" && product.Attributes.Operation == "" && product.Attributes.Usagetype != "TagStorage-TagHrs" {
+		if getPriceListKey(product) != "" {
 			list = append(list, product)
 		}
 	}
@@ -88,12 +98,13 @@ func (ap *AwsPricing) getProductWithArn(priceListArn *string) (list []Product, e
 }
 
 // Get Region Price price list with the sku list. Returns an Master price list of all prices in all regions.
-func (ap *AwsPricing) GetRegionPriceList(regionSkuList RegionSkuList) MasterPriceList {
+// The storage prices are by volume type and the fees by usage type.
+func (ap *AwsPricing) GetRegionPriceList(ctx context.Context, regionSkuList RegionSkuList) MasterPriceList {
 	regionMasterPriceList := make(MasterPriceList)
 	for k, v := range regionSkuList {
 		var productPriceList = make(ProductPriceList)
 		for _, product := range v {
-			productPrice, err := ap.GetPriceListWithSku(product.Sku)
+			productPrice, err := ap.GetPriceListWithSku(ctx, product.Sku)
 			if err != nil {
 				logrus.Error(err)
 				continue
@@ -103,7 +114,7 @@ func (ap *AwsPricing) GetRegionPriceList(regionSkuList RegionSkuList) MasterPric
 				logrus.Error(err)
 				continue
 			}
-			productPriceList[product.Attributes.VolumeType] = priceList
+			productPriceList[getPriceListKey(product)] = priceList
 		}
 		regionMasterPriceList[k] = productPriceList
 	}
@@ -111,7 +122,7 @@ func (ap *AwsPricing) GetRegionPriceList(regionSkuList RegionSkuList) MasterPric
 }
 
 // Get a price list with a sku.
-func (ap *AwsPricing) GetPriceListWithSku(sku string) (*pricing.GetProductsOutput, error) {
+func (ap *AwsPricing) GetPriceListWithSku(ctx context.Context, sku string) (*pricing.GetProductsOutput, error) {
 	filters := []types.Filter{{
 		Field: aws.String("sku"),
 		Value: aws.String(sku),
@@ -121,7 +132,7 @@ func (ap *AwsPricing) GetPriceListWithSku(sku string) (*pricing.GetProductsOutpu
 		Filters:     filters,
 		ServiceCode: aws.String("AmazonS3"),
 	}
-	productPrice, err := ap.Session.GetProducts(input)
+	productPrice, err := ap.Session.GetProducts(ctx, input)
 	if err != nil {
 		return nil, err
 	}
@@ -138,19 +149,62 @@ func DecodePricingList(productPrice *pricing.GetProductsOutput) (priceList Price
 }
 
 // Calculate the average price for a SKU. It will take the total size of all the storage class and return a map of average price per storage class.
-func GetTierPriceList(totalStorageClassSize util.StorageClassSizeMap, priceList ProductPriceList) map[string]float64 {
+// The storage classes that can't be priced are left out of the map, one error is joined for each of them.
+func GetTierPriceList(totalStorageClassSize util.StorageClassSizeMap, priceList ProductPriceList) (map[string]float64, error) {
 	tierList := make(map[string]float64)
-	for k, v := range totalStorageClassSize {
-		priceListForSku := priceList[GetStorageClassType(k)]
+	var errs []error
+	// The errors are sorted by storage class so they are reported the same way every time
+	storageClasses := make([]string, 0, len(totalStorageClassSize))
+	for k := range totalStorageClassSize {
+		storageClasses = append(storageClasses, k)
+	}
+	slices.Sort(storageClasses)
+	for _, k := range storageClasses {
+		v := totalStorageClassSize[k]
+		volumeType, err := GetStorageClassType(k)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w, its cost is not computed", err))
+			continue
+		}
+		// Not billed by S3, the cost is reported as 0
+		if volumeType == "" {
+			tierList[k] = 0
+			errs = append(errs, fmt.Errorf("storage class %s is not billed through the S3 price list, its cost is 0", k))
+			continue
+		}
+		priceListForSku, ok := priceList[volumeType]
+		if !ok {
+			errs = append(errs, fmt.Errorf("no price for %q in the price list, the cost of %s is not computed", volumeType, k))
+			continue
+		}
+		// The price of an empty storage class isn't defined, its objects cost nothing
+		if v <= 0 {
+			continue
+		}
 		price, err := getPriceForSize(TransformSizeToGB(v), priceListForSku)
 		if err != nil {
-			logrus.Error(err)
+			errs = append(errs, fmt.Errorf("%w, its cost is not computed", err))
 			continue
 		}
 		tierList[k] = price
 	}
-	return tierList
+	return tierList, errors.Join(errs...)
+}
 
+// Add the errors of the price list of a region to the scan errors, an error already reported isn't added again.
+// A region whose prices couldn't be fetched already has its error.
+func appendRegionPriceErrors(scanErrors []util.ScanError, priceList MasterPriceList, region string, err error) []util.ScanError {
+	if _, ok := priceList[region]; err == nil || !ok {
+		return scanErrors
+	}
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		scanErrors = util.AppendScanErrors(scanErrors, util.ScanError{Region: region, Error: err.Error()})
+	}
+	return scanErrors
 }
 
 // Function to calculate the average price per storage class based on total size of storage class.
@@ -158,7 +212,7 @@ func getPriceForSize(sizeGB float64, priceListForSku PriceList) (price float64,
 	var totalPrice float64
 	var tempSize = sizeGB
 	for _, j := range priceListForSku.Terms.OnDemand {
-		for _, l := range j.PriceDimensions {
+		for _, l := range sortPriceDimensions(j.PriceDimensions) {
 			bRange, err := strconv.ParseFloat(l.BeginRange, 64)
 			if err != nil {
 				logrus.Error(err)
@@ -190,22 +244,45 @@ func getPriceForSize(sizeGB float64, priceListForSku PriceList) (price float64,
 	return totalPrice / sizeGB, nil
 }
 
+// The tiers have to be applied from the lowest to the highest range. Map iteration order is random.
+func sortPriceDimensions(priceDimensions map[string]PriceDimension) []PriceDimension {
+	dimensions := make([]PriceDimension, 0, len(priceDimensions))
+	for _, dimension := range priceDimensions {
+		dimensions = append(dimensions, dimension)
+	}
+	slices.SortFunc(dimensions, func(a, b PriceDimension) int {
+		aRange, _ := strconv.ParseFloat(a.BeginRange, 64)
+		bRange, _ := strconv.ParseFloat(b.BeginRange, 64)
+		return cmp.Compare(aRange, bRange)
+	})
+	return dimensions
+}
+
+// Volume type of the storage products of the price list for each storage class. Outposts and Snow are billed
+// with their capacity instead of the S3 price list, their volume type is empty.
+var storageClassVolumeTypes = map[string]string{
+	S3_STORAGE_CLASS_STANDARD:                "Standard",
+	S3_STORAGE_CLASS_REDUCED_REDUNDANCY:      "Reduced Redundancy",
+	S3_STORAGE_CLASS_STANDARD_IA:             "Standard - Infrequent Access",
+	S3_STORAGE_CLASS_ONEZONE_IA:              "One Zone - Infrequent Access",
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING:     "Intelligent-Tiering Frequent Access",
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING_IA:  "Intelligent-Tiering Infrequent Access",
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING_AIA: "Intelligent-Tiering Archive Instant Access",
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING_AA:  "Intelligent-Tiering Archive Access",
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING_DAA: "Intelligent-Tiering Deep Archive Access",
+	S3_STORAGE_CLASS_GLACIER_IR:              "Glacier Instant Retrieval",
+	S3_STORAGE_CLASS_GLACIER:                 "Amazon Glacier",
+	S3_STORAGE_CLASS_DEEP_ARCHIVE:            "Glacier Deep Archive",
+	S3_STORAGE_CLASS_EXPRESS_ONEZONE:         "Express One Zone",
+	S3_STORAGE_CLASS_OUTPOSTS:                "",
+	S3_STORAGE_CLASS_SNOW:                    "",
+}
+
 // Help function to convert between AWS Bucket Storage class and AWS Price liste Storage Class
-func GetStorageClassType(volumeType string) string {
-	switch volumeType {
-	case S3_STORAGE_CLASS_GLACIER_IR:
-		return "Glacier Instant Retrieval"
-	case S3_STORAGE_CLASS_STANDARD:
-		return "Standard"
-	case S3_STORAGE_CLASS_INTELLIGENT_TIERING:
-		return "Intelligent-Tiering Frequent Access"
-	case S3_STORAGE_CLASS_GLACIER:
-		return "Amazon Glacier"
-	case S3_STORAGE_CLASS_REDUCED_REDUNDANCY:
-		return "Reduced Redundancy"
-	case S3_STORAGE_CLASS_STANDARD_IA:
-		return "Standard - Infrequent Access"
-	default:
-		return S3_STORAGE_CLASS_STANDARD
+func GetStorageClassType(storageClass string) (string, error) {
+	volumeType, ok := storageClassVolumeTypes[storageClass]
+	if !ok {
+		return "", fmt.Errorf("no volume type in the price list for storage class %q", storageClass)
 	}
+	return volumeType, nil
 }
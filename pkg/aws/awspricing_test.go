@@ -4,6 +4,7 @@ import (
 	"projet-devops-coveo/pkg/util"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -76,6 +77,7 @@ func TestGetTierPriceList(t *testing.T) {
 		totalStorageClassSize util.StorageClassSizeMap
 		priceList             ProductPriceList
 		expectedOutput        map[string]float64
+		expectedError         string
 	}{
 		{
 			name: "Test First Tier",
@@ -113,9 +115,112 @@ func TestGetTierPriceList(t *testing.T) {
 				S3_STORAGE_CLASS_GLACIER:  0.20916070722464533,
 			},
 		},
+		{
+			name: "Test Storage Class Not Billed By S3",
+			totalStorageClassSize: util.StorageClassSizeMap{
+				S3_STORAGE_CLASS_STANDARD: 0.1,
+				S3_STORAGE_CLASS_OUTPOSTS: 100,
+			},
+			priceList: MockProductPriceList,
+			expectedOutput: map[string]float64{
+				S3_STORAGE_CLASS_STANDARD: 0.25,
+				S3_STORAGE_CLASS_OUTPOSTS: 0,
+			},
+			expectedError: "storage class OUTPOSTS is not billed through the S3 price list, its cost is 0",
+		},
+		{
+			name: "Test Empty Storage Class",
+			totalStorageClassSize: util.StorageClassSizeMap{
+				S3_STORAGE_CLASS_STANDARD: 0.1,
+				S3_STORAGE_CLASS_GLACIER:  0,
+			},
+			priceList: MockProductPriceList,
+			expectedOutput: map[string]float64{
+				S3_STORAGE_CLASS_STANDARD: 0.25,
+			},
+		},
+		{
+			name: "Test Unknown Storage Class And Missing Price",
+			totalStorageClassSize: util.StorageClassSizeMap{
+				S3_STORAGE_CLASS_STANDARD:     0.1,
+				"UNKNOWN":                     100,
+				S3_STORAGE_CLASS_DEEP_ARCHIVE: 100,
+			},
+			priceList: MockProductPriceList,
+			expectedOutput: map[string]float64{
+				S3_STORAGE_CLASS_STANDARD: 0.25,
+			},
+			expectedError: `no price for "Glacier Deep Archive" in the price list, the cost of DEEP_ARCHIVE is not computed
+no volume type in the price list for storage class "UNKNOWN", its cost is not computed`,
+		},
 	}
 	for _, test := range tests {
-		output := GetTierPriceList(test.totalStorageClassSize, test.priceList)
+		output, err := GetTierPriceList(test.totalStorageClassSize, test.priceList)
 		assert.Equal(t, test.expectedOutput, output)
+		if test.expectedError == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.EqualError(t, err, test.expectedError)
+		}
+	}
+}
+
+func TestGetTierListPricesErrors(t *testing.T) {
+	fs := &S3{
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{
+				"ca-central-1": {S3_STORAGE_CLASS_STANDARD: 0.1, "UNKNOWN": 100},
+				// The prices of the region couldn't be fetched, its error is already reported
+				"us-east-1": {S3_STORAGE_CLASS_STANDARD: 0.1},
+			},
+		},
 	}
+	_, priceErrors := fs.GetTierListPrices(MasterPriceList{"ca-central-1": MockProductPriceList})
+	assert.Equal(t, []util.ScanError{
+		{Region: "ca-central-1", Error: `no volume type in the price list for storage class "UNKNOWN", its cost is not computed`},
+	}, priceErrors)
+}
+
+func TestGetStorageClassType(t *testing.T) {
+	expected := map[string]string{
+		S3_STORAGE_CLASS_STANDARD:            "Standard",
+		S3_STORAGE_CLASS_REDUCED_REDUNDANCY:  "Reduced Redundancy",
+		S3_STORAGE_CLASS_GLACIER:             "Amazon Glacier",
+		S3_STORAGE_CLASS_STANDARD_IA:         "Standard - Infrequent Access",
+		S3_STORAGE_CLASS_ONEZONE_IA:          "One Zone - Infrequent Access",
+		S3_STORAGE_CLASS_INTELLIGENT_TIERING: "Intelligent-Tiering Frequent Access",
+		S3_STORAGE_CLASS_DEEP_ARCHIVE:        "Glacier Deep Archive",
+		S3_STORAGE_CLASS_OUTPOSTS:            "",
+		S3_STORAGE_CLASS_GLACIER_IR:          "Glacier Instant Retrieval",
+		S3_STORAGE_CLASS_SNOW:                "",
+		S3_STORAGE_CLASS_EXPRESS_ONEZONE:     "Express One Zone",
+	}
+	// Every storage class of the SDK is mapped
+	for _, value := range types.ObjectStorageClass("").Values() {
+		storageClass := GetStorageClassConstant(value)
+		volumeType, err := GetStorageClassType(storageClass)
+		assert.Nil(t, err, value)
+		assert.Equal(t, expected[storageClass], volumeType, value)
+		_, ok := expected[storageClass]
+		assert.True(t, ok, value)
+	}
+	// The storage classes of the storage metrics, with the access tiers of Intelligent-Tiering
+	for _, storage := range cloudwatchStorageTypes {
+		for _, storageClass := range []string{storage.storageClass, storage.accessTier} {
+			if storageClass == "" {
+				continue
+			}
+			volumeType, err := GetStorageClassType(storageClass)
+			assert.Nil(t, err, storage.storageType)
+			assert.NotEmpty(t, volumeType, storage.storageType)
+		}
+	}
+	volumeType, err := GetStorageClassType(S3_STORAGE_CLASS_INTELLIGENT_TIERING_AIA)
+	assert.Nil(t, err)
+	assert.Equal(t, "Intelligent-Tiering Archive Instant Access", volumeType)
+
+	_, err = GetStorageClassType("")
+	assert.NotNil(t, err)
+	_, err = GetStorageClassType("UNKNOWN")
+	assert.NotNil(t, err)
 }
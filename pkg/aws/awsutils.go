@@ -30,6 +30,18 @@ func GetBucketsOfRegion(buckets []util.CloudFilesystem, region string) (regionBu
 	return regionBuckets
 }
 
+// Get the regions of the buckets, sorted and without duplicates.
+// The buckets whose location is unknown are skipped.
+func GetRegionsOfBuckets(buckets []util.CloudFilesystem) (regions []string) {
+	for _, bucket := range buckets {
+		if bucket.GetRegion() != "" && !slices.Contains(regions, bucket.GetRegion()) {
+			regions = append(regions, bucket.GetRegion())
+		}
+	}
+	slices.Sort(regions)
+	return regions
+}
+
 // Remove buckets from a list of buckets. Reduce size of array.
 // It has to be sorted beforehand so performance is good.
 func RemoveScrappedBucketFromList(scrappedBuckets []util.CloudFilesystem, bucketList []util.CloudFilesystem) []util.CloudFilesystem {
@@ -73,6 +85,22 @@ func GetStorageClassConstant(value s3types.ObjectStorageClass) string {
 	}
 }
 
+func GetEncryptionType(value s3types.ServerSideEncryption) string {
+	switch value {
+	case s3types.ServerSideEncryptionAes256:
+		return util.ENCRYPTION_SSE_S3
+	case s3types.ServerSideEncryptionAwsKms:
+		return util.ENCRYPTION_SSE_KMS
+	case s3types.ServerSideEncryptionAwsKmsDsse:
+		return util.ENCRYPTION_DSSE_KMS
+	case "":
+		return util.ENCRYPTION_NONE
+	default:
+		return string(value)
+	}
+}
+
+// The legacy EU constraint is the location of the buckets created in eu-west-1.
 func GetBucketLocationConstant(value s3types.BucketLocationConstraint) string {
 	switch value {
 	case s3types.BucketLocationConstraintAfSouth1:
@@ -102,7 +130,7 @@ func GetBucketLocationConstant(value s3types.BucketLocationConstraint) string {
 	case s3types.BucketLocationConstraintCnNorthwest1:
 		return "cn-northwest-1"
 	case s3types.BucketLocationConstraintEu:
-		return "EU"
+		return "eu-west-1"
 	case s3types.BucketLocationConstraintEuCentral1:
 		return "eu-central-1"
 	case s3types.BucketLocationConstraintEuNorth1:
@@ -124,14 +152,18 @@ func GetBucketLocationConstant(value s3types.BucketLocationConstraint) string {
 	case s3types.BucketLocationConstraintUsEast2:
 		return "us-east-2"
 	case s3types.BucketLocationConstraintUsGovEast1:
-		return "s-gov-east-1"
+		return "us-gov-east-1"
 	case s3types.BucketLocationConstraintUsGovWest1:
-		return "s-gov-west-1"
+		return "us-gov-west-1"
 	case s3types.BucketLocationConstraintUsWest1:
 		return "us-west-1"
 	case s3types.BucketLocationConstraintUsWest2:
 		return "us-west-2"
-	default:
+	case "":
+		// Buckets of us-east-1 have no location constraint
 		return "us-east-1"
+	default:
+		// Regions more recent than the SDK are returned as is
+		return string(value)
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"projet-devops-coveo/pkg/util"
 	"testing"
 
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -123,3 +124,29 @@ func TestRemoveScrappedBucketList(t *testing.T) {
 	output := RemoveScrappedBucketFromList(scappredBucket, expectedInput)
 	assert.Equal(t, expectedoutput, output)
 }
+
+func TestGetRegionsOfBuckets(t *testing.T) {
+	var input = []util.CloudFilesystem{
+		&util.BucketDTO{
+			Name:   "One",
+			Region: "us-east-2",
+		}, &util.BucketDTO{
+			Name:   "Two",
+			Region: "ca-central-1",
+		}, &util.BucketDTO{
+			Name:   "Three",
+			Region: "us-east-2",
+		}, &util.BucketDTO{
+			Name: "Four",
+		},
+	}
+	assert.Equal(t, []string{"ca-central-1", "us-east-2"}, GetRegionsOfBuckets(input))
+	assert.Nil(t, GetRegionsOfBuckets(nil))
+}
+
+func TestGetBucketLocationConstant(t *testing.T) {
+	assert.Equal(t, "us-east-1", GetBucketLocationConstant(""))
+	assert.Equal(t, "eu-west-1", GetBucketLocationConstant(s3types.BucketLocationConstraintEu))
+	assert.Equal(t, "us-gov-west-1", GetBucketLocationConstant(s3types.BucketLocationConstraintUsGovWest1))
+	assert.Equal(t, "ap-southeast-4", GetBucketLocationConstant("ap-southeast-4"))
+}
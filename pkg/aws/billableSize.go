@@ -0,0 +1,97 @@
+package aws
+
+import (
+	"slices"
+	"time"
+
+	"projet-devops-coveo/pkg/util"
+)
+
+// Objects smaller than MINIMUM_BILLABLE_SIZE in these storage classes are billed as if they had this size.
+var minimumBillableSizeClasses = []string{
+	S3_STORAGE_CLASS_STANDARD_IA,
+	S3_STORAGE_CLASS_ONEZONE_IA,
+	S3_STORAGE_CLASS_GLACIER_IR,
+}
+
+const MINIMUM_BILLABLE_SIZE = 128 << 10
+
+// Metadata added to each archived object, billed in the storage class of the object for the index and in STANDARD
+// for the name and the user metadata.
+var archiveOverhead = map[string]int64{
+	S3_STORAGE_CLASS_GLACIER:      32 << 10,
+	S3_STORAGE_CLASS_DEEP_ARCHIVE: 32 << 10,
+}
+
+const ARCHIVE_STANDARD_OVERHEAD = 8 << 10
+
+// Size missing to an object to reach the minimum billable size. It's kept with the ages of the objects so their
+// billable size can be computed in the storage class they transition to.
+func getPadding(size int64) int64 {
+	return max(0, MINIMUM_BILLABLE_SIZE-size)
+}
+
+// Size billed for objects beyond their own size in their storage class and in STANDARD, from their number and
+// their total padding.
+func getObjectsOverhead(storageClass string, nbOfFiles int64, padding int64) (float64, float64) {
+	var overhead, standardOverhead float64
+	if slices.Contains(minimumBillableSizeClasses, storageClass) {
+		overhead += float64(padding)
+	}
+	if archived, ok := archiveOverhead[storageClass]; ok {
+		overhead += float64(nbOfFiles * archived)
+		standardOverhead += float64(nbOfFiles * ARCHIVE_STANDARD_OVERHEAD)
+	}
+	return overhead, standardOverhead
+}
+
+// Add the size billed for an object beyond its own size to the overheads by storage class.
+func addBillableOverhead(overhead util.StorageClassSizeMap, size int64, storageClass string) {
+	addObjectsOverhead(overhead, storageClass, 1, getPadding(size), 1)
+}
+
+// Add the overhead of objects to the sizes by storage class, the sign is negative to remove them.
+func addObjectsOverhead(sizes util.StorageClassSizeMap, storageClass string, nbOfFiles int64, padding int64, sign float64) {
+	overhead, standardOverhead := getObjectsOverhead(storageClass, nbOfFiles, padding)
+	if overhead != 0 {
+		sizes[storageClass] += sign * overhead
+	}
+	if standardOverhead != 0 {
+		sizes[S3_STORAGE_CLASS_STANDARD] += sign * standardOverhead
+	}
+}
+
+// Add the billable size of the objects of a day in a storage class to the sizes by storage class, the sign is
+// negative to remove them. The overhead follows the objects in the storage class they transition to.
+func addBillableSize(sizes util.StorageClassSizeMap, storageClass string, modified *modifiedDay, sign float64) {
+	sizes[storageClass] += sign * float64(modified.Size)
+	addObjectsOverhead(sizes, storageClass, modified.NbOfFiles, modified.Padding, sign)
+}
+
+// Billable size of the objects of a day in a storage class, without the metadata billed in STANDARD.
+func billableSizeInClass(storageClass string, modified *modifiedDay) float64 {
+	overhead, _ := getObjectsOverhead(storageClass, modified.NbOfFiles, modified.Padding)
+	return float64(modified.Size) + overhead
+}
+
+// Size billed for the bucket by storage class: the current objects, the noncurrent versions and the incomplete
+// multipart uploads with the overheads of their objects.
+func (stats *bucketStats) billableStorageClassSize() util.StorageClassSizeMap {
+	billable := stats.totalStorageClassSize()
+	for k, v := range stats.billableOverhead {
+		billable[k] += v
+	}
+	return billable
+}
+
+// Billable size of the current objects younger than the minimum storage duration of their storage class, multiplied by the
+// months left. It's billed even if the objects are deleted or transitioned before.
+func (stats *bucketStats) minimumDurationSize(now time.Time) util.StorageClassSizeMap {
+	minimumDurationSize := make(util.StorageClassSizeMap)
+	for storageClass, days := range stats.modifiedDays {
+		for day, modified := range days {
+			addPenaltySize(minimumDurationSize, storageClass, billableSizeInClass(storageClass, modified), toDay(now)-day)
+		}
+	}
+	return minimumDurationSize
+}
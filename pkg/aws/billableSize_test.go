@@ -0,0 +1,80 @@
+package aws
+
+import (
+	"encoding/json"
+	"projet-devops-coveo/pkg/util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddBillableOverhead(t *testing.T) {
+	overhead := make(util.StorageClassSizeMap)
+	addBillableOverhead(overhead, 100, "STANDARD")
+	addBillableOverhead(overhead, 200<<10, "STANDARD_IA")
+	assert.Empty(t, overhead)
+
+	addBillableOverhead(overhead, 28<<10, "ONEZONE_IA")
+	addBillableOverhead(overhead, 0, "GLACIER_IR")
+	addBillableOverhead(overhead, 1<<20, "GLACIER")
+	addBillableOverhead(overhead, 10, "DEEP_ARCHIVE")
+	assert.Equal(t, util.StorageClassSizeMap{
+		"ONEZONE_IA":   100 << 10,
+		"GLACIER_IR":   128 << 10,
+		"GLACIER":      32 << 10,
+		"DEEP_ARCHIVE": 32 << 10,
+		"STANDARD":     16 << 10,
+	}, overhead)
+}
+
+func TestBillableStorageClassSize(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	stats := newBucketStats()
+	stats.addObject(1000, "STANDARD_IA", now.AddDate(0, 0, -10))
+	stats.addObject(200<<10, "STANDARD_IA", now.AddDate(0, 0, -40))
+	stats.addObject(1000, "DEEP_ARCHIVE", now.AddDate(0, 0, -90))
+	stats.addNoncurrentVersion(1000, "GLACIER")
+	stats.addMultipartUpload(500, "STANDARD", now)
+
+	assert.Equal(t, util.StorageClassSizeMap{
+		"STANDARD":     500 + 16<<10,
+		"STANDARD_IA":  128<<10 + 200<<10,
+		"GLACIER":      1000 + 32<<10,
+		"DEEP_ARCHIVE": 1000 + 32<<10,
+	}, stats.billableStorageClassSize())
+	// The objects of 10 and 90 days have 20 and 90 days left, by their billable size in their storage class
+	assert.Equal(t, util.StorageClassSizeMap{
+		"STANDARD_IA":  float64(128<<10) * 20 / 30,
+		"DEEP_ARCHIVE": float64(1000+32<<10) * 90 / 30,
+	}, stats.minimumDurationSize(now))
+
+	// The overheads are saved in the checkpoint
+	data, err := json.Marshal(stats)
+	assert.Nil(t, err)
+	loaded := newBucketStats()
+	assert.Nil(t, json.Unmarshal(data, loaded))
+	assert.Equal(t, stats.billableOverhead, loaded.billableOverhead)
+}
+
+func TestSetBucketCostWithBillableSize(t *testing.T) {
+	gb := float64(1024 * 1024 * 1024)
+	fs := &S3{
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": {"STANDARD_IA": 2 * gb}},
+		},
+	}
+	priceList := MasterPriceList{
+		"ca-central-1": flatPriceList(map[string]string{"Standard - Infrequent Access": "0.01"}),
+	}
+	bucket := &util.BucketDTO{
+		Name:                     "poc-1",
+		Region:                   "ca-central-1",
+		StorageClassSize:         util.StorageClassSizeMap{"STANDARD_IA": gb},
+		BillableStorageClassSize: util.StorageClassSizeMap{"STANDARD_IA": 2 * gb},
+		MinimumDurationSize:      util.StorageClassSizeMap{"STANDARD_IA": gb / 2},
+	}
+	fs.SetBucketCost([]util.CloudFilesystem{bucket}, priceList)
+	assert.InDelta(t, 0.02, bucket.Cost, 1e-6)
+	assert.InDelta(t, 0.005, bucket.MinimumDurationCost, 1e-6)
+}
@@ -0,0 +1,232 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"projet-devops-coveo/pkg/util"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// Error codes returned by AWS when a configuration isn't set on the bucket.
+var configNotFoundCodes = []string{
+	"NoSuchLifecycleConfiguration",
+	"ReplicationConfigurationNotFoundError",
+	"ServerSideEncryptionConfigurationNotFoundError",
+	"ObjectLockConfigurationNotFoundError",
+	"NoSuchPublicAccessBlockConfiguration",
+	"OwnershipControlsNotFoundError",
+	"NoSuchTagSet",
+}
+
+func isConfigNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && slices.Contains(configNotFoundCodes, apiErr.ErrorCode())
+}
+
+// Fetch the configuration of a bucket. A configuration that can't be fetched doesn't fail the bucket,
+// its error is kept in the configuration.
+func (fs *S3) fetchBucketConfig(ctx context.Context, bucketName string) *util.BucketConfig {
+	config := &util.BucketConfig{}
+	bucket := aws.String(bucketName)
+	fail := func(name string, err error) {
+		if config.Errors == nil {
+			config.Errors = make(map[string]string)
+		}
+		config.Errors[name] = err.Error()
+	}
+
+	versioning, err := fs.session.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: bucket})
+	if err != nil {
+		fail("Versioning", err)
+	} else {
+		config.Versioning = string(versioning.Status)
+		if config.Versioning == "" {
+			config.Versioning = "Disabled"
+		}
+		config.MFADelete = string(versioning.MFADelete)
+	}
+
+	lifecycle, err := fs.session.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: bucket})
+	switch {
+	case isConfigNotFound(err):
+	case err != nil:
+		fail("Lifecycle", err)
+	default:
+		for _, rule := range lifecycle.Rules {
+			config.LifecycleRules = append(config.LifecycleRules, toLifecycleRule(rule))
+		}
+	}
+
+	replication, err := fs.session.GetBucketReplication(ctx, &s3.GetBucketReplicationInput{Bucket: bucket})
+	switch {
+	case isConfigNotFound(err):
+	case err != nil:
+		fail("Replication", err)
+	case replication.ReplicationConfiguration != nil:
+		config.Replication = toReplicationConfig(replication.ReplicationConfiguration)
+	}
+
+	encryption, err := fs.session.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: bucket})
+	switch {
+	case isConfigNotFound(err):
+	case err != nil:
+		fail("Encryption", err)
+	case encryption.ServerSideEncryptionConfiguration != nil:
+		for _, rule := range encryption.ServerSideEncryptionConfiguration.Rules {
+			if rule.ApplyServerSideEncryptionByDefault == nil {
+				continue
+			}
+			config.Encryption = append(config.Encryption, util.EncryptionRule{
+				Algorithm:        string(rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm),
+				KMSKeyId:         aws.ToString(rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID),
+				BucketKeyEnabled: aws.ToBool(rule.BucketKeyEnabled),
+			})
+		}
+	}
+
+	objectLock, err := fs.session.GetObjectLockConfiguration(ctx, &s3.GetObjectLockConfigurationInput{Bucket: bucket})
+	switch {
+	case isConfigNotFound(err):
+	case err != nil:
+		fail("ObjectLock", err)
+	case objectLock.ObjectLockConfiguration != nil:
+		config.ObjectLock = toObjectLockConfig(objectLock.ObjectLockConfiguration)
+	}
+
+	logging, err := fs.session.GetBucketLogging(ctx, &s3.GetBucketLoggingInput{Bucket: bucket})
+	switch {
+	case err != nil:
+		fail("Logging", err)
+	case logging.LoggingEnabled != nil:
+		config.Logging = &util.LoggingConfig{
+			TargetBucket: aws.ToString(logging.LoggingEnabled.TargetBucket),
+			TargetPrefix: aws.ToString(logging.LoggingEnabled.TargetPrefix),
+		}
+	}
+
+	publicAccessBlock, err := fs.session.GetPublicAccessBlock(ctx, &s3.GetPublicAccessBlockInput{Bucket: bucket})
+	switch {
+	case isConfigNotFound(err):
+	case err != nil:
+		fail("PublicAccessBlock", err)
+	case publicAccessBlock.PublicAccessBlockConfiguration != nil:
+		block := publicAccessBlock.PublicAccessBlockConfiguration
+		config.PublicAccessBlock = &util.PublicAccessBlockConfig{
+			BlockPublicAcls:       aws.ToBool(block.BlockPublicAcls),
+			IgnorePublicAcls:      aws.ToBool(block.IgnorePublicAcls),
+			BlockPublicPolicy:     aws.ToBool(block.BlockPublicPolicy),
+			RestrictPublicBuckets: aws.ToBool(block.RestrictPublicBuckets),
+		}
+	}
+
+	ownership, err := fs.session.GetBucketOwnershipControls(ctx, &s3.GetBucketOwnershipControlsInput{Bucket: bucket})
+	switch {
+	case isConfigNotFound(err):
+	case err != nil:
+		fail("OwnershipControls", err)
+	case ownership.OwnershipControls != nil && len(ownership.OwnershipControls.Rules) != 0:
+		config.ObjectOwnership = string(ownership.OwnershipControls.Rules[0].ObjectOwnership)
+	}
+	return config
+}
+
+func toLifecycleRule(rule types.LifecycleRule) util.LifecycleRule {
+	output := util.LifecycleRule{
+		ID:     aws.ToString(rule.ID),
+		Status: string(rule.Status),
+		// Deprecated, replaced by the filter
+		Prefix: aws.ToString(rule.Prefix),
+	}
+	switch filter := rule.Filter.(type) {
+	case *types.LifecycleRuleFilterMemberPrefix:
+		output.Prefix = filter.Value
+	case *types.LifecycleRuleFilterMemberTag:
+		output.Tags = map[string]string{aws.ToString(filter.Value.Key): aws.ToString(filter.Value.Value)}
+	case *types.LifecycleRuleFilterMemberObjectSizeGreaterThan:
+		output.ObjectSizeGreaterThan = filter.Value
+	case *types.LifecycleRuleFilterMemberObjectSizeLessThan:
+		output.ObjectSizeLessThan = filter.Value
+	case *types.LifecycleRuleFilterMemberAnd:
+		output.Prefix = aws.ToString(filter.Value.Prefix)
+		output.ObjectSizeGreaterThan = aws.ToInt64(filter.Value.ObjectSizeGreaterThan)
+		output.ObjectSizeLessThan = aws.ToInt64(filter.Value.ObjectSizeLessThan)
+		for _, tag := range filter.Value.Tags {
+			if output.Tags == nil {
+				output.Tags = make(map[string]string)
+			}
+			output.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+	for _, transition := range rule.Transitions {
+		output.Transitions = append(output.Transitions, util.LifecycleTransition{
+			Days:         aws.ToInt32(transition.Days),
+			Date:         transition.Date,
+			StorageClass: string(transition.StorageClass),
+		})
+	}
+	if rule.Expiration != nil {
+		output.ExpirationDays = aws.ToInt32(rule.Expiration.Days)
+		output.ExpirationDate = rule.Expiration.Date
+		output.ExpiredObjectDeleteMarker = aws.ToBool(rule.Expiration.ExpiredObjectDeleteMarker)
+	}
+	for _, transition := range rule.NoncurrentVersionTransitions {
+		output.NoncurrentTransitions = append(output.NoncurrentTransitions, util.LifecycleTransition{
+			Days:         aws.ToInt32(transition.NoncurrentDays),
+			StorageClass: string(transition.StorageClass),
+		})
+	}
+	if rule.NoncurrentVersionExpiration != nil {
+		output.NoncurrentExpirationDays = aws.ToInt32(rule.NoncurrentVersionExpiration.NoncurrentDays)
+		output.NewerNoncurrentVersions = aws.ToInt32(rule.NoncurrentVersionExpiration.NewerNoncurrentVersions)
+	}
+	if rule.AbortIncompleteMultipartUpload != nil {
+		output.AbortIncompleteMultipartUploadDays = aws.ToInt32(rule.AbortIncompleteMultipartUpload.DaysAfterInitiation)
+	}
+	return output
+}
+
+func toReplicationConfig(replication *types.ReplicationConfiguration) *util.ReplicationConfig {
+	output := &util.ReplicationConfig{Role: aws.ToString(replication.Role)}
+	for _, rule := range replication.Rules {
+		outputRule := util.ReplicationRule{
+			ID:       aws.ToString(rule.ID),
+			Status:   string(rule.Status),
+			Priority: aws.ToInt32(rule.Priority),
+			// Deprecated, replaced by the filter
+			Prefix: aws.ToString(rule.Prefix),
+		}
+		switch filter := rule.Filter.(type) {
+		case *types.ReplicationRuleFilterMemberPrefix:
+			outputRule.Prefix = filter.Value
+		case *types.ReplicationRuleFilterMemberAnd:
+			outputRule.Prefix = aws.ToString(filter.Value.Prefix)
+		}
+		if rule.Destination != nil {
+			outputRule.DestinationBucket = aws.ToString(rule.Destination.Bucket)
+			outputRule.DestinationStorageClass = string(rule.Destination.StorageClass)
+		}
+		if rule.DeleteMarkerReplication != nil {
+			outputRule.DeleteMarkerReplication = rule.DeleteMarkerReplication.Status == types.DeleteMarkerReplicationStatusEnabled
+		}
+		output.Rules = append(output.Rules, outputRule)
+	}
+	return output
+}
+
+func toObjectLockConfig(objectLock *types.ObjectLockConfiguration) *util.ObjectLockConfig {
+	output := &util.ObjectLockConfig{
+		Enabled: objectLock.ObjectLockEnabled == types.ObjectLockEnabledEnabled,
+	}
+	if objectLock.Rule != nil && objectLock.Rule.DefaultRetention != nil {
+		output.Mode = string(objectLock.Rule.DefaultRetention.Mode)
+		output.Days = aws.ToInt32(objectLock.Rule.DefaultRetention.Days)
+		output.Years = aws.ToInt32(objectLock.Rule.DefaultRetention.Years)
+	}
+	return output
+}
@@ -0,0 +1,82 @@
+package aws
+
+import (
+	"context"
+	"projet-devops-coveo/pkg/util"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchBucketConfig(t *testing.T) {
+	mock := &AwsClientMock{
+		Objects: map[string][]types.Object{
+			"poc-1": {
+				{Key: aws.String("a.txt"), Size: aws.Int64(1), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+			},
+		},
+		Configs: map[string]*BucketConfigMock{
+			"poc-1": {
+				Versioning: &s3.GetBucketVersioningOutput{Status: types.BucketVersioningStatusEnabled},
+				Lifecycle: &s3.GetBucketLifecycleConfigurationOutput{Rules: []types.LifecycleRule{{
+					ID:     aws.String("archive"),
+					Status: types.ExpirationStatusEnabled,
+					Filter: &types.LifecycleRuleFilterMemberAnd{Value: types.LifecycleRuleAndOperator{
+						Prefix: aws.String("logs/"),
+						Tags:   []types.Tag{{Key: aws.String("team"), Value: aws.String("data")}},
+					}},
+					Transitions:                    []types.Transition{{Days: aws.Int32(30), StorageClass: types.TransitionStorageClassGlacier}},
+					Expiration:                     &types.LifecycleExpiration{Days: aws.Int32(365)},
+					AbortIncompleteMultipartUpload: &types.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int32(7)},
+				}}},
+				Encryption: &s3.GetBucketEncryptionOutput{ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{
+					Rules: []types.ServerSideEncryptionRule{{ApplyServerSideEncryptionByDefault: &types.ServerSideEncryptionByDefault{SSEAlgorithm: types.ServerSideEncryptionAes256}}},
+				}},
+				Logging: &s3.GetBucketLoggingOutput{LoggingEnabled: &types.LoggingEnabled{TargetBucket: aws.String("logs"), TargetPrefix: aws.String("poc-1/")}},
+				OwnershipControls: &s3.GetBucketOwnershipControlsOutput{OwnershipControls: &types.OwnershipControls{
+					Rules: []types.OwnershipControlsRule{{ObjectOwnership: types.ObjectOwnershipBucketOwnerEnforced}},
+				}},
+				Errors: map[string]error{"GetBucketReplication": &smithy.GenericAPIError{Code: "AccessDenied"}},
+			},
+		},
+	}
+	fs := &S3{
+		session: mock,
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": map[string]float64{}},
+		},
+		options: util.CliOptions{Threading: 2, IncludeConfig: true},
+		region:  "ca-central-1",
+	}
+	bucket := &util.BucketDTO{Name: "poc-1", Region: "ca-central-1"}
+	fs.GetObject(context.Background(), []util.CloudFilesystem{bucket}, nil)
+
+	// The configurations that are not set are omitted, the error of the replication doesn't fail the bucket
+	assert.Equal(t, util.BUCKET_STATUS_COMPLETE, bucket.Status)
+	assert.Equal(t, &util.BucketConfig{
+		Versioning: "Enabled",
+		LifecycleRules: []util.LifecycleRule{{
+			ID:                                 "archive",
+			Status:                             "Enabled",
+			Prefix:                             "logs/",
+			Tags:                               map[string]string{"team": "data"},
+			Transitions:                        []util.LifecycleTransition{{Days: 30, StorageClass: "GLACIER"}},
+			ExpirationDays:                     365,
+			AbortIncompleteMultipartUploadDays: 7,
+		}},
+		Encryption:      []util.EncryptionRule{{Algorithm: "AES256"}},
+		Logging:         &util.LoggingConfig{TargetBucket: "logs", TargetPrefix: "poc-1/"},
+		ObjectOwnership: "BucketOwnerEnforced",
+		Errors:          map[string]string{"Replication": "api error AccessDenied: "},
+	}, bucket.Config)
+
+	// Not fetched without --include-config
+	fs.options.IncludeConfig = false
+	bucket = &util.BucketDTO{Name: "poc-1", Region: "ca-central-1"}
+	fs.GetObject(context.Background(), []util.CloudFilesystem{bucket}, nil)
+	assert.Nil(t, bucket.Config)
+}
@@ -0,0 +1,303 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"projet-devops-coveo/pkg/util"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The state file is written at most once per interval while scanning, and when the scan is interrupted.
+const CHECKPOINT_SAVE_INTERVAL = 10 * time.Second
+
+// Progress of a scan saved to a local state file so an interrupted scan can be resumed where it stopped.
+// A nil checkpoint is valid and does nothing. The stats are never modified once in the checkpoint, they are
+// copied in and out of it.
+type Checkpoint struct {
+	Options      string                       `json:"options"`
+	Buckets      map[string]*BucketCheckpoint `json:"buckets"`
+	path         string
+	removed      bool
+	lastSave     time.Time
+	saveInterval time.Duration
+	mutex        sync.Mutex
+	// Held while writing the file, the progress can still be updated meanwhile
+	saveMutex sync.Mutex
+}
+
+// Progress of a bucket. Once done, the stats hold the whole bucket, otherwise the objects found while discovering its prefixes.
+type BucketCheckpoint struct {
+	Done       bool                            `json:"done"`
+	Stats      *bucketStats                    `json:"stats,omitempty"`
+	Partitions map[string]*PartitionCheckpoint `json:"partitions,omitempty"`
+}
+
+// Progress of the listing of a prefix of a bucket, with the stats of the objects listed so far.
+type PartitionCheckpoint struct {
+	ContinuationToken string `json:"continuationToken,omitempty"`
+	KeyMarker         string `json:"keyMarker,omitempty"`
+	VersionIdMarker   string `json:"versionIdMarker,omitempty"`
+	// Key whose versions are being listed, with the last modification of its last version or delete marker listed
+	// and the number of its noncurrent versions listed. A key can continue on the next page.
+	PreviousKey      string       `json:"previousKey,omitempty"`
+	PreviousModified time.Time    `json:"previousModified"`
+	Rank             int          `json:"rank,omitempty"`
+	Done             bool         `json:"done"`
+	Stats            *bucketStats `json:"stats,omitempty"`
+}
+
+// Start the listing of the versions of a key, unless they are already being listed.
+func (progress *PartitionCheckpoint) listVersionsOf(key string) {
+	if key != progress.PreviousKey {
+		progress.PreviousKey, progress.PreviousModified, progress.Rank = key, time.Time{}, 0
+	}
+}
+
+// Load the state file of a scan. A new checkpoint is returned if the file doesn't exist yet.
+func LoadCheckpoint(path string, options util.CliOptions) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{
+		Options:      checkpointOptions(options),
+		Buckets:      make(map[string]*BucketCheckpoint),
+		path:         path,
+		saveInterval: CHECKPOINT_SAVE_INTERVAL,
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	saved := &Checkpoint{}
+	if err := json.Unmarshal(data, saved); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if saved.Options != checkpoint.Options {
+		return nil, fmt.Errorf("the state file %s was written by a scan with different options", path)
+	}
+	if saved.Buckets != nil {
+		checkpoint.Buckets = saved.Buckets
+	}
+	return checkpoint, nil
+}
+
+// Options that change the result of a scan. A scan can only be resumed with the same ones.
+func checkpointOptions(options util.CliOptions) string {
+	return fmt.Sprintf("%s %q %t %t %q %q %q %q %g %d %d %t", options.Source, options.InventoryLocations, options.IncludeVersions, options.IncludeMultipartUploads,
+		options.FilterByStorageClass, options.FilterByPrefix, options.FilterByGlob, options.FilterByRegex, options.EncryptionSample, options.PrefixDepth, options.GetTop(),
+		options.GetLifecycleProjection())
+}
+
+// Number of buckets already scanned.
+func (c *Checkpoint) NbOfBucketsDone() (n int) {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, bucket := range c.Buckets {
+		if bucket.Done {
+			n++
+		}
+	}
+	return n
+}
+
+// Write the state file.
+func (c *Checkpoint) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.saveMutex.Lock()
+	defer c.saveMutex.Unlock()
+	c.mutex.Lock()
+	if c.removed {
+		c.mutex.Unlock()
+		return nil
+	}
+	snapshot := c.snapshot()
+	c.lastSave = time.Now()
+	c.mutex.Unlock()
+	return snapshot.write(c.path)
+}
+
+// Remove the state file once the scan is complete.
+func (c *Checkpoint) Remove() error {
+	if c == nil {
+		return nil
+	}
+	c.saveMutex.Lock()
+	defer c.saveMutex.Unlock()
+	c.mutex.Lock()
+	c.removed = true
+	c.mutex.Unlock()
+	err := os.Remove(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Copy of the buckets and of their partitions, sharing their stats. Must be called with the lock.
+func (c *Checkpoint) snapshot() *Checkpoint {
+	snapshot := &Checkpoint{
+		Options: c.Options,
+		Buckets: make(map[string]*BucketCheckpoint, len(c.Buckets)),
+	}
+	for name, bucket := range c.Buckets {
+		copied := *bucket
+		copied.Partitions = maps.Clone(bucket.Partitions)
+		snapshot.Buckets[name] = &copied
+	}
+	return snapshot
+}
+
+// The file is replaced with a rename so an interruption never leaves a truncated state file.
+func (c *Checkpoint) write(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (c *Checkpoint) isSaveDue() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return !c.removed && time.Since(c.lastSave) >= c.saveInterval
+}
+
+func (c *Checkpoint) saveIfDue() {
+	if !c.isSaveDue() {
+		return
+	}
+	if err := c.Save(); err != nil {
+		logrus.Error(err)
+	}
+}
+
+// Must be called with the lock.
+func (c *Checkpoint) bucket(bucketName string) *BucketCheckpoint {
+	bucket, ok := c.Buckets[bucketName]
+	if !ok {
+		bucket = &BucketCheckpoint{}
+		c.Buckets[bucketName] = bucket
+	}
+	return bucket
+}
+
+// Returns the stats of a bucket already scanned.
+func (c *Checkpoint) finishedBucket(bucketName string) (*bucketStats, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mutex.Lock()
+	bucket, ok := c.Buckets[bucketName]
+	c.mutex.Unlock()
+	if !ok || !bucket.Done || bucket.Stats == nil {
+		return nil, false
+	}
+	return bucket.Stats.copy(), true
+}
+
+// Save the stats of a bucket once it is scanned. The progress of its prefixes isn't needed anymore.
+func (c *Checkpoint) finishBucket(bucketName string, stats *bucketStats) {
+	if c == nil {
+		return
+	}
+	finished := &BucketCheckpoint{
+		Done:  true,
+		Stats: stats.copy(),
+	}
+	c.mutex.Lock()
+	c.Buckets[bucketName] = finished
+	c.mutex.Unlock()
+	c.saveIfDue()
+}
+
+// Returns the prefixes of a bucket to list and the stats of the objects found while discovering them, if they were already discovered.
+func (c *Checkpoint) discoveredPrefixes(bucketName string) (*bucketStats, []string, bool) {
+	if c == nil {
+		return nil, nil, false
+	}
+	c.mutex.Lock()
+	bucket, ok := c.Buckets[bucketName]
+	if !ok || bucket.Partitions == nil {
+		c.mutex.Unlock()
+		return nil, nil, false
+	}
+	discovered := bucket.Stats
+	prefixes := make([]string, 0, len(bucket.Partitions))
+	for prefix := range bucket.Partitions {
+		prefixes = append(prefixes, prefix)
+	}
+	c.mutex.Unlock()
+	slices.Sort(prefixes)
+	stats := newBucketStats()
+	if discovered != nil {
+		stats = discovered.copy()
+	}
+	return stats, prefixes, true
+}
+
+// Save the prefixes of a bucket to list and the stats of the objects found while discovering them.
+func (c *Checkpoint) setDiscoveredPrefixes(bucketName string, stats *bucketStats, prefixes []string) {
+	if c == nil {
+		return
+	}
+	discovered := stats.copy()
+	c.mutex.Lock()
+	bucket := c.bucket(bucketName)
+	bucket.Stats = discovered
+	bucket.Partitions = make(map[string]*PartitionCheckpoint)
+	for _, prefix := range prefixes {
+		bucket.Partitions[prefix] = &PartitionCheckpoint{}
+	}
+	c.mutex.Unlock()
+	c.saveIfDue()
+}
+
+// Returns the progress of the listing of a prefix. The stats returned belong to the caller.
+func (c *Checkpoint) partition(bucketName string, prefix string) PartitionCheckpoint {
+	if c == nil {
+		return PartitionCheckpoint{Stats: newBucketStats()}
+	}
+	c.mutex.Lock()
+	partition, ok := c.bucket(bucketName).Partitions[prefix]
+	c.mutex.Unlock()
+	if !ok || partition.Stats == nil {
+		return PartitionCheckpoint{Stats: newBucketStats()}
+	}
+	progress := *partition
+	progress.Stats = partition.Stats.copy()
+	return progress
+}
+
+// Save the progress of the listing of a prefix. Called after each page by the lister, which owns the stats.
+// They are only copied when the state file is due or the prefix is done, so the pages listed since the last
+// save are listed again when the scan is resumed.
+func (c *Checkpoint) updatePartition(bucketName string, prefix string, progress PartitionCheckpoint) {
+	if c == nil || !progress.Done && !c.isSaveDue() {
+		return
+	}
+	progress.Stats = progress.Stats.copy()
+	c.mutex.Lock()
+	bucket := c.bucket(bucketName)
+	if bucket.Partitions == nil {
+		bucket.Partitions = make(map[string]*PartitionCheckpoint)
+	}
+	bucket.Partitions[prefix] = &progress
+	c.mutex.Unlock()
+	c.saveIfDue()
+}
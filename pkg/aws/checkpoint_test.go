@@ -0,0 +1,102 @@
+package aws
+
+import (
+	"context"
+	"path/filepath"
+	"projet-devops-coveo/pkg/util"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	options := util.CliOptions{FilterByStorageClass: []string{"STANDARD"}}
+	checkpoint, err := LoadCheckpoint(path, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, checkpoint.NbOfBucketsDone())
+
+	stats := newBucketStats()
+	stats.addObject(100, "STANDARD", timeMock)
+	checkpoint.finishBucket("poc-1", stats)
+	// The pages listed before the next save are not kept
+	checkpoint.updatePartition("poc-2", "logs/", PartitionCheckpoint{ContinuationToken: "skipped", Stats: stats})
+	assert.Equal(t, "", checkpoint.partition("poc-2", "logs/").ContinuationToken)
+	checkpoint.saveInterval = 0
+	checkpoint.updatePartition("poc-2", "logs/", PartitionCheckpoint{ContinuationToken: "token", Stats: stats})
+	// The lister keeps adding objects to its stats
+	stats.addObject(50, "STANDARD", timeMock)
+	assert.Nil(t, checkpoint.Save())
+
+	resumed, err := LoadCheckpoint(path, options)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, resumed.NbOfBucketsDone())
+	finished, done := resumed.finishedBucket("poc-1")
+	assert.True(t, done)
+	assert.Equal(t, int64(1), finished.nbOfFiles)
+	assert.Equal(t, util.StorageClassSizeMap{"STANDARD": 100}, finished.storageClassSize)
+	assert.True(t, timeMock.Equal(finished.lastModified))
+	progress := resumed.partition("poc-2", "logs/")
+	assert.Equal(t, "token", progress.ContinuationToken)
+	assert.Equal(t, int64(100), progress.Stats.totalSize)
+
+	// The result would be wrong with other filters
+	_, err = LoadCheckpoint(path, util.CliOptions{})
+	assert.NotNil(t, err)
+
+	assert.Nil(t, resumed.Remove())
+	assert.Nil(t, resumed.Save())
+	checkpoint, err = LoadCheckpoint(path, util.CliOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, checkpoint.NbOfBucketsDone())
+}
+
+func TestFetchBucketResumed(t *testing.T) {
+	mock := &AwsClientMock{
+		Objects: map[string][]types.Object{
+			"poc-1": {
+				{Key: aws.String("a.txt"), Size: aws.Int64(1), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("b.txt"), Size: aws.Int64(2), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("c.txt"), Size: aws.Int64(4), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("d.txt"), Size: aws.Int64(8), StorageClass: "GLACIER", LastModified: aws.Time(timeMock)},
+			},
+			"poc-2": {
+				{Key: aws.String("a.txt"), Size: aws.Int64(1), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+			},
+		},
+		PageSize: 2,
+	}
+	checkpoint, err := LoadCheckpoint(filepath.Join(t.TempDir(), "state.json"), util.CliOptions{})
+	assert.Nil(t, err)
+	// The scan stopped after the first page of poc-1, and after poc-2
+	partial := newBucketStats()
+	partial.addObject(3, "STANDARD", timeMock)
+	partial.nbOfFiles = 2
+	checkpoint.saveInterval = 0
+	checkpoint.setDiscoveredPrefixes("poc-1", newBucketStats(), []string{""})
+	checkpoint.updatePartition("poc-1", "", PartitionCheckpoint{ContinuationToken: "2", Stats: partial})
+	finished := newBucketStats()
+	finished.addObject(1000, "STANDARD", timeMock)
+	checkpoint.finishBucket("poc-2", finished)
+
+	fs := &S3{
+		session: mock,
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": map[string]float64{}},
+		},
+		options:    util.CliOptions{PartitionDepth: 1, Threading: 2},
+		region:     "ca-central-1",
+		checkpoint: checkpoint,
+	}
+	poc1 := &util.BucketDTO{Name: "poc-1", Region: "ca-central-1"}
+	poc2 := &util.BucketDTO{Name: "poc-2", Region: "ca-central-1"}
+	fs.GetObject(context.Background(), []util.CloudFilesystem{poc1, poc2}, nil)
+
+	assert.Equal(t, int64(4), poc1.NbOfFiles)
+	assert.Equal(t, float64(15), poc1.SizeOfBucket)
+	assert.Equal(t, util.StorageClassSizeMap{"STANDARD": 7, "GLACIER": 8}, poc1.StorageClassSize)
+	assert.Equal(t, float64(1000), poc2.SizeOfBucket)
+	assert.Equal(t, 2, checkpoint.NbOfBucketsDone())
+}
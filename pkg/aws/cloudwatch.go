@@ -0,0 +1,132 @@
+package aws
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+const (
+	CLOUDWATCH_NAMESPACE         = "AWS/S3"
+	CLOUDWATCH_METRIC_SIZE       = "BucketSizeBytes"
+	CLOUDWATCH_METRIC_OBJECTS    = "NumberOfObjects"
+	CLOUDWATCH_DIMENSION_BUCKET  = "BucketName"
+	CLOUDWATCH_DIMENSION_STORAGE = "StorageType"
+	CLOUDWATCH_ALL_STORAGE_TYPES = "AllStorageTypes"
+	// Storage metrics are reported once a day
+	CLOUDWATCH_PERIOD   = 86400
+	CLOUDWATCH_LOOKBACK = 3 * 24 * time.Hour
+)
+
+// Storage types of the BucketSizeBytes metric and the storage class they are billed as.
+// Overheads and staging storage are included since they are billed. The Intelligent-Tiering objects are
+// reported by access tier, each tier has its own price.
+var cloudwatchStorageTypes = []struct {
+	storageType  string
+	storageClass string
+	accessTier   string
+}{
+	{"StandardStorage", S3_STORAGE_CLASS_STANDARD, ""},
+	{"IntelligentTieringFAStorage", S3_STORAGE_CLASS_INTELLIGENT_TIERING, ""},
+	{"IntelligentTieringIAStorage", S3_STORAGE_CLASS_INTELLIGENT_TIERING, S3_STORAGE_CLASS_INTELLIGENT_TIERING_IA},
+	{"IntelligentTieringAAStorage", S3_STORAGE_CLASS_INTELLIGENT_TIERING, S3_STORAGE_CLASS_INTELLIGENT_TIERING_AA},
+	{"IntelligentTieringAIAStorage", S3_STORAGE_CLASS_INTELLIGENT_TIERING, S3_STORAGE_CLASS_INTELLIGENT_TIERING_AIA},
+	{"IntelligentTieringDAAStorage", S3_STORAGE_CLASS_INTELLIGENT_TIERING, S3_STORAGE_CLASS_INTELLIGENT_TIERING_DAA},
+	{"StandardIAStorage", S3_STORAGE_CLASS_STANDARD_IA, ""},
+	{"StandardIASizeOverhead", S3_STORAGE_CLASS_STANDARD_IA, ""},
+	{"OneZoneIAStorage", S3_STORAGE_CLASS_ONEZONE_IA, ""},
+	{"OneZoneIASizeOverhead", S3_STORAGE_CLASS_ONEZONE_IA, ""},
+	{"ReducedRedundancyStorage", S3_STORAGE_CLASS_REDUCED_REDUNDANCY, ""},
+	{"GlacierInstantRetrievalStorage", S3_STORAGE_CLASS_GLACIER_IR, ""},
+	{"GlacierIRSizeOverhead", S3_STORAGE_CLASS_GLACIER_IR, ""},
+	{"GlacierStorage", S3_STORAGE_CLASS_GLACIER, ""},
+	{"GlacierObjectOverhead", S3_STORAGE_CLASS_GLACIER, ""},
+	{"GlacierStagingStorage", S3_STORAGE_CLASS_STANDARD, ""},
+	{"GlacierS3ObjectOverhead", S3_STORAGE_CLASS_STANDARD, ""},
+	{"DeepArchiveStorage", S3_STORAGE_CLASS_DEEP_ARCHIVE, ""},
+	{"DeepArchiveObjectOverhead", S3_STORAGE_CLASS_DEEP_ARCHIVE, ""},
+	{"DeepArchiveStagingStorage", S3_STORAGE_CLASS_STANDARD, ""},
+	{"DeepArchiveS3ObjectOverhead", S3_STORAGE_CLASS_STANDARD, ""},
+	{"ExpressOneZone", S3_STORAGE_CLASS_EXPRESS_ONEZONE, ""},
+}
+
+// Estimate the size per storage class and the number of objects of a bucket with the daily storage metrics.
+// All the metrics of the bucket are fetched with a single GetMetricData query.
+func (fs *S3) fetchMetrics(ctx context.Context, bucketName string) (*bucketStats, error) {
+	stats := newBucketStats()
+	queries := []cwtypes.MetricDataQuery{
+		newStorageMetricQuery("objects", bucketName, CLOUDWATCH_METRIC_OBJECTS, CLOUDWATCH_ALL_STORAGE_TYPES),
+	}
+	for i, storage := range cloudwatchStorageTypes {
+		queries = append(queries, newStorageMetricQuery(fmt.Sprintf("size%d", i), bucketName, CLOUDWATCH_METRIC_SIZE, storage.storageType))
+	}
+
+	end := time.Now()
+	input := &cloudwatch.GetMetricDataInput{
+		MetricDataQueries: queries,
+		StartTime:         aws.Time(end.Add(-CLOUDWATCH_LOOKBACK)),
+		EndTime:           aws.Time(end),
+		ScanBy:            cwtypes.ScanByTimestampDescending,
+	}
+	seen := make(map[string]bool)
+	for {
+		output, err := fs.session.GetMetricData(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range output.MetricDataResults {
+			// Only the most recent datapoint is kept. The next pages only hold older datapoints.
+			if len(result.Values) == 0 || seen[aws.ToString(result.Id)] {
+				continue
+			}
+			seen[aws.ToString(result.Id)] = true
+			fs.addMetricResult(stats, aws.ToString(result.Id), result.Values[0])
+		}
+		if output.NextToken == nil {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+	return stats, nil
+}
+
+func (fs *S3) addMetricResult(stats *bucketStats, id string, value float64) {
+	if id == "objects" {
+		stats.nbOfFiles = int64(value)
+		return
+	}
+	var i int
+	if _, err := fmt.Sscanf(id, "size%d", &i); err != nil || i >= len(cloudwatchStorageTypes) {
+		return
+	}
+	storage := cloudwatchStorageTypes[i]
+	if len(fs.options.FilterByStorageClass) != 0 && !slices.Contains(fs.options.FilterByStorageClass, storage.storageClass) {
+		return
+	}
+	stats.totalSize += int64(value)
+	stats.storageClassSize[cmp.Or(storage.accessTier, storage.storageClass)] += value
+}
+
+func newStorageMetricQuery(id string, bucketName string, metricName string, storageType string) cwtypes.MetricDataQuery {
+	return cwtypes.MetricDataQuery{
+		Id: aws.String(id),
+		MetricStat: &cwtypes.MetricStat{
+			Metric: &cwtypes.Metric{
+				Namespace:  aws.String(CLOUDWATCH_NAMESPACE),
+				MetricName: aws.String(metricName),
+				Dimensions: []cwtypes.Dimension{
+					{Name: aws.String(CLOUDWATCH_DIMENSION_BUCKET), Value: aws.String(bucketName)},
+					{Name: aws.String(CLOUDWATCH_DIMENSION_STORAGE), Value: aws.String(storageType)},
+				},
+			},
+			Period: aws.Int32(CLOUDWATCH_PERIOD),
+			Stat:   aws.String("Average"),
+		},
+	}
+}
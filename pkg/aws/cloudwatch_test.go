@@ -0,0 +1,91 @@
+package aws
+
+import (
+	"context"
+	"projet-devops-coveo/pkg/util"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchMetrics(t *testing.T) {
+	mock := &AwsClientMock{
+		Metrics: map[string]map[string]float64{
+			"poc-1": {
+				"NumberOfObjects/AllStorageTypes":         42,
+				"BucketSizeBytes/StandardStorage":         1000,
+				"BucketSizeBytes/StandardIAStorage":       500,
+				"BucketSizeBytes/StandardIASizeOverhead":  20,
+				"BucketSizeBytes/GlacierStorage":          300,
+				"BucketSizeBytes/GlacierObjectOverhead":   10,
+				"BucketSizeBytes/GlacierS3ObjectOverhead": 5,
+			},
+		},
+	}
+	tests := []struct {
+		name           string
+		filter         []string
+		expectedFiles  int64
+		expectedSize   int64
+		expectedSCSize util.StorageClassSizeMap
+	}{
+		{
+			name:          "All storage types",
+			expectedFiles: 42,
+			expectedSize:  1835,
+			expectedSCSize: util.StorageClassSizeMap{
+				S3_STORAGE_CLASS_STANDARD:    1005,
+				S3_STORAGE_CLASS_STANDARD_IA: 520,
+				S3_STORAGE_CLASS_GLACIER:     310,
+			},
+		},
+		{
+			name:          "Filter by storage class",
+			filter:        []string{S3_STORAGE_CLASS_GLACIER},
+			expectedFiles: 42,
+			expectedSize:  310,
+			expectedSCSize: util.StorageClassSizeMap{
+				S3_STORAGE_CLASS_GLACIER: 310,
+			},
+		},
+	}
+	for _, test := range tests {
+		fs := &S3{
+			session: mock,
+			options: util.CliOptions{Source: util.SOURCE_CLOUDWATCH, FilterByStorageClass: test.filter},
+		}
+		stats, err := fs.fetchMetrics(context.Background(), "poc-1")
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, test.expectedFiles, stats.nbOfFiles, test.name)
+		assert.Equal(t, test.expectedSize, stats.totalSize, test.name)
+		assert.Equal(t, test.expectedSCSize, stats.storageClassSize, test.name)
+	}
+}
+
+func TestFetchMetricsAccessTiers(t *testing.T) {
+	mock := &AwsClientMock{
+		Metrics: map[string]map[string]float64{
+			"poc-1": {
+				"BucketSizeBytes/StandardStorage":              1000,
+				"BucketSizeBytes/IntelligentTieringFAStorage":  400,
+				"BucketSizeBytes/IntelligentTieringIAStorage":  300,
+				"BucketSizeBytes/IntelligentTieringAIAStorage": 200,
+				"BucketSizeBytes/IntelligentTieringDAAStorage": 100,
+			},
+		},
+	}
+	fs := &S3{
+		session: mock,
+		options: util.CliOptions{Source: util.SOURCE_CLOUDWATCH, FilterByStorageClass: []string{S3_STORAGE_CLASS_INTELLIGENT_TIERING}},
+	}
+	stats, err := fs.fetchMetrics(context.Background(), "poc-1")
+	assert.Nil(t, err)
+	// The storage class filter applies to all the access tiers
+	assert.Equal(t, int64(1000), stats.totalSize)
+	assert.Equal(t, util.StorageClassSizeMap{
+		S3_STORAGE_CLASS_INTELLIGENT_TIERING:     400,
+		S3_STORAGE_CLASS_INTELLIGENT_TIERING_IA:  300,
+		S3_STORAGE_CLASS_INTELLIGENT_TIERING_AIA: 200,
+		S3_STORAGE_CLASS_INTELLIGENT_TIERING_DAA: 100,
+	}, stats.storageClassSize)
+}
@@ -8,6 +8,20 @@ const (
 	S3_STORAGE_CLASS_INTELLIGENT_TIERING = "INTELLIGENT_TIERING"
 	S3_STORAGE_CLASS_DEEP_ARCHIVE        = "DEEP_ARCHIVE"
 	S3_STORAGE_CLASS_GLACIER_IR          = "GLACIER_IR"
+	S3_STORAGE_CLASS_ONEZONE_IA          = "ONEZONE_IA"
+	S3_STORAGE_CLASS_EXPRESS_ONEZONE     = "EXPRESS_ONEZONE"
+	S3_STORAGE_CLASS_OUTPOSTS            = "OUTPOSTS"
+	S3_STORAGE_CLASS_SNOW                = "SNOW"
+
+	// Access tiers of Intelligent-Tiering, only known from the storage metrics. The frequent access tier is
+	// INTELLIGENT_TIERING.
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING_IA  = "INTELLIGENT_TIERING_IA"
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING_AIA = "INTELLIGENT_TIERING_AIA"
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING_AA  = "INTELLIGENT_TIERING_AA"
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING_DAA = "INTELLIGENT_TIERING_DAA"
 
 	REGION_CST = "region"
+
+	LIFECYCLE_STATUS_ENABLED  = "Enabled"
+	LIFECYCLE_STATUS_DISABLED = "Disabled"
 )
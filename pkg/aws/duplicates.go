@@ -0,0 +1,333 @@
+package aws
+
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"projet-devops-coveo/pkg/util"
+)
+
+const (
+	// Number of files the objects are spilled to, an object always goes to the file of its fingerprint
+	// so the duplicates are found one file at a time.
+	DUPLICATES_SHARDS = 64
+	// Number of objects sorted in memory at a time when a file is read, the larger files are sorted in runs merged from disk.
+	DUPLICATES_SORT_BATCH = 100000
+)
+
+type duplicateEntry struct {
+	ETag         string `json:"e"`
+	Size         int64  `json:"s"`
+	Bucket       string `json:"b"`
+	Region       string `json:"r"`
+	Key          string `json:"k"`
+	StorageClass string `json:"c"`
+}
+
+// Objects fingerprinted by ETag and size with --duplicates, spilled to temporary files instead of being kept in memory.
+// The ETag of an object uploaded in one part is the MD5 of its content. The ETag of a multipart upload is the MD5
+// of the MD5 of its parts followed by the number of parts, so the copies of a multipart upload only match when
+// they were uploaded with the same part size, and never match a copy uploaded in one part.
+// The ETag of an object encrypted with SSE-KMS or SSE-C isn't the MD5 of its content, so its copies are not found.
+// A nil index is valid and does nothing.
+type DuplicateIndex struct {
+	dir       string
+	files     []*os.File
+	writers   []*bufio.Writer
+	sortBatch int
+	err       error
+	mutex     sync.Mutex
+}
+
+func NewDuplicateIndex() (*DuplicateIndex, error) {
+	dir, err := os.MkdirTemp("", "duplicates-*")
+	if err != nil {
+		return nil, err
+	}
+	return &DuplicateIndex{
+		dir:       dir,
+		files:     make([]*os.File, DUPLICATES_SHARDS),
+		writers:   make([]*bufio.Writer, DUPLICATES_SHARDS),
+		sortBatch: DUPLICATES_SORT_BATCH,
+	}, nil
+}
+
+// ETags are quoted and their case isn't guaranteed.
+func normalizeETag(eTag string) string {
+	return strings.ToLower(strings.Trim(eTag, `"`))
+}
+
+func isMultipartETag(eTag string) bool {
+	return strings.Contains(eTag, "-")
+}
+
+func (d *DuplicateIndex) add(bucketName string, region string, key string, eTag string, size int64, storageClass string) {
+	eTag = normalizeETag(eTag)
+	// Empty objects don't waste anything
+	if d == nil || eTag == "" || size == 0 {
+		return
+	}
+	entry := duplicateEntry{ETag: eTag, Size: size, Bucket: bucketName, Region: region, Key: key, StorageClass: storageClass}
+	hash := fnv.New32a()
+	fmt.Fprintf(hash, "%s %d", eTag, size)
+	shard := hash.Sum32() % DUPLICATES_SHARDS
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.err != nil {
+		return
+	}
+	if d.writers[shard] == nil {
+		file, err := os.Create(filepath.Join(d.dir, fmt.Sprintf("%02d.jsonl", shard)))
+		if err != nil {
+			d.err = err
+			return
+		}
+		d.files[shard] = file
+		d.writers[shard] = bufio.NewWriter(file)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		d.err = err
+		return
+	}
+	if _, err := d.writers[shard].Write(append(data, '\n')); err != nil {
+		d.err = err
+	}
+}
+
+// Find the duplicates, the wasted size and cost are the ones of all the copies except the cheapest one.
+// The prices are per GB by region and storage class.
+func (d *DuplicateIndex) Report(tierListPrices map[string]map[string]float64) (*util.DuplicatesReport, error) {
+	if d == nil {
+		return nil, nil
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.err != nil {
+		return nil, d.err
+	}
+	report := &util.DuplicatesReport{Groups: []util.DuplicateGroup{}}
+	for shard, writer := range d.writers {
+		if writer == nil {
+			continue
+		}
+		if err := writer.Flush(); err != nil {
+			return nil, err
+		}
+		err := readDuplicateShard(d.files[shard].Name(), d.sortBatch, func(entries []duplicateEntry) {
+			if len(entries) > 1 {
+				report.AddGroup(newDuplicateGroup(entries, tierListPrices))
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	slices.SortStableFunc(report.Groups, func(a, b util.DuplicateGroup) int {
+		return cmp.Or(cmp.Compare(b.WastedSize, a.WastedSize), cmp.Compare(a.ETag, b.ETag))
+	})
+	return report, nil
+}
+
+func compareFingerprints(a, b duplicateEntry) int {
+	return cmp.Or(cmp.Compare(a.ETag, b.ETag), cmp.Compare(a.Size, b.Size))
+}
+
+func newDuplicateScanner(file *os.File) *bufio.Scanner {
+	scanner := bufio.NewScanner(file)
+	// The keys can be up to 1KB, after escaping
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return scanner
+}
+
+// Objects of a shard sorted by fingerprint, read from a file or from memory.
+type duplicateRun struct {
+	file    *os.File
+	scanner *bufio.Scanner
+	entries []duplicateEntry
+	head    duplicateEntry
+}
+
+// Move to the next object, false at the end of the run.
+func (r *duplicateRun) next() (bool, error) {
+	if r.scanner == nil {
+		if len(r.entries) == 0 {
+			return false, nil
+		}
+		r.head, r.entries = r.entries[0], r.entries[1:]
+		return true, nil
+	}
+	if !r.scanner.Scan() {
+		return false, r.scanner.Err()
+	}
+	r.head = duplicateEntry{}
+	return true, json.Unmarshal(r.scanner.Bytes(), &r.head)
+}
+
+// Sort a batch of objects and spill it to a file next to its shard.
+func writeDuplicateRun(path string, entries []duplicateEntry) (*duplicateRun, error) {
+	slices.SortFunc(entries, compareFingerprints)
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".run-*")
+	if err != nil {
+		return nil, err
+	}
+	run := &duplicateRun{file: file}
+	writer := bufio.NewWriter(file)
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return run, err
+		}
+		if _, err := writer.Write(append(data, '\n')); err != nil {
+			return run, err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return run, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return run, err
+	}
+	run.scanner = newDuplicateScanner(file)
+	return run, nil
+}
+
+// Min-heap of the runs of a shard by the fingerprint of their next object.
+type duplicateRuns []*duplicateRun
+
+func (h duplicateRuns) Len() int           { return len(h) }
+func (h duplicateRuns) Less(i, j int) bool { return compareFingerprints(h[i].head, h[j].head) < 0 }
+func (h duplicateRuns) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *duplicateRuns) Push(x any)        { *h = append(*h, x.(*duplicateRun)) }
+func (h *duplicateRuns) Pop() any {
+	run := (*h)[len(*h)-1]
+	*h = (*h)[:len(*h)-1]
+	return run
+}
+
+// Stream the objects of a shard grouped by fingerprint. The shard is sorted by batches, the batches that don't fit
+// in memory are spilled to sorted runs, then the runs are merged so only one group is in memory at a time.
+func readDuplicateShard(path string, batchSize int, groupFn func(entries []duplicateEntry)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var runs []*duplicateRun
+	defer func() {
+		for _, run := range runs {
+			if run.file != nil {
+				run.file.Close()
+				os.Remove(run.file.Name())
+			}
+		}
+	}()
+	var batch []duplicateEntry
+	scanner := newDuplicateScanner(file)
+	for scanner.Scan() {
+		var entry duplicateEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return err
+		}
+		batch = append(batch, entry)
+		if len(batch) == batchSize {
+			run, err := writeDuplicateRun(path, batch)
+			if run != nil {
+				runs = append(runs, run)
+			}
+			if err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	slices.SortFunc(batch, compareFingerprints)
+	runs = append(runs, &duplicateRun{entries: batch})
+
+	heads := make(duplicateRuns, 0, len(runs))
+	for _, run := range runs {
+		ok, err := run.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heads = append(heads, run)
+		}
+	}
+	heap.Init(&heads)
+	var group []duplicateEntry
+	for len(heads) != 0 {
+		run := heads[0]
+		if len(group) != 0 && compareFingerprints(group[0], run.head) != 0 {
+			groupFn(group)
+			group = nil
+		}
+		group = append(group, run.head)
+		ok, err := run.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&heads, 0)
+		} else {
+			heap.Pop(&heads)
+		}
+	}
+	if len(group) != 0 {
+		groupFn(group)
+	}
+	return nil
+}
+
+func newDuplicateGroup(entries []duplicateEntry, tierListPrices map[string]map[string]float64) util.DuplicateGroup {
+	group := util.DuplicateGroup{
+		ETag:       entries[0].ETag,
+		Size:       float64(entries[0].Size),
+		Multipart:  isMultipartETag(entries[0].ETag),
+		NbOfCopies: len(entries),
+		WastedSize: float64(entries[0].Size) * float64(len(entries)-1),
+	}
+	var total, cheapest float64
+	for i, entry := range entries {
+		cost := TransformSizeToGB(float64(entry.Size)) * tierListPrices[entry.Region][entry.StorageClass]
+		total += cost
+		if i == 0 || cost < cheapest {
+			cheapest = cost
+		}
+		group.Objects = append(group.Objects, util.DuplicateObject{Bucket: entry.Bucket, Region: entry.Region, Key: entry.Key, StorageClass: entry.StorageClass})
+	}
+	group.WastedCost = total - cheapest
+	slices.SortFunc(group.Objects, func(a, b util.DuplicateObject) int {
+		return cmp.Or(cmp.Compare(a.Bucket, b.Bucket), cmp.Compare(a.Key, b.Key))
+	})
+	return group
+}
+
+// Remove the temporary files.
+func (d *DuplicateIndex) Close() error {
+	if d == nil {
+		return nil
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, file := range d.files {
+		if file != nil {
+			file.Close()
+		}
+	}
+	return os.RemoveAll(d.dir)
+}
@@ -0,0 +1,135 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"projet-devops-coveo/pkg/util"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicateIndex(t *testing.T) {
+	var nilIndex *DuplicateIndex
+	nilIndex.add("poc-1", "us-east-1", "a", "abc", 1, "STANDARD")
+	report, err := nilIndex.Report(nil)
+	assert.Nil(t, err)
+	assert.Nil(t, report)
+
+	index, err := NewDuplicateIndex()
+	assert.Nil(t, err)
+	gb := int64(1 << 30)
+	// The ETags are quoted by AWS
+	index.add("poc-1", "us-east-1", "data/a.csv", `"ABC"`, gb, "STANDARD")
+	index.add("poc-2", "ca-central-1", "copy/a.csv", `"abc"`, gb, "GLACIER")
+	index.add("poc-2", "ca-central-1", "copy2/a.csv", "abc", gb, "STANDARD")
+	// Same ETag with another size
+	index.add("poc-1", "us-east-1", "other.csv", "abc", 10, "STANDARD")
+	// The same content uploaded in one part and in 2 parts isn't found
+	index.add("poc-1", "us-east-1", "big.bin", "def-2", 2*gb, "STANDARD")
+	index.add("poc-2", "ca-central-1", "big.bin", "def-2", 2*gb, "STANDARD")
+	index.add("poc-3", "ca-central-1", "big.bin", "123", 2*gb, "STANDARD")
+	// Empty objects are ignored
+	index.add("poc-1", "us-east-1", "empty", "d41d8cd98f00b204e9800998ecf8427e", 0, "STANDARD")
+	index.add("poc-2", "us-east-1", "empty", "d41d8cd98f00b204e9800998ecf8427e", 0, "STANDARD")
+
+	report, err = index.Report(map[string]map[string]float64{
+		"us-east-1":    {"STANDARD": 0.023},
+		"ca-central-1": {"STANDARD": 0.025, "GLACIER": 0.004},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.NbOfGroups)
+	assert.Equal(t, 3, report.NbOfDuplicates)
+	assert.Equal(t, float64(4*gb), report.WastedSize)
+
+	assert.Equal(t, "abc", report.Groups[0].ETag)
+	assert.False(t, report.Groups[0].Multipart)
+	assert.Equal(t, 3, report.Groups[0].NbOfCopies)
+	// The cheapest copy, in GLACIER, is kept
+	assert.InDelta(t, 0.023+0.025, report.Groups[0].WastedCost, 0.000001)
+	assert.Equal(t, []util.DuplicateObject{
+		{Bucket: "poc-1", Region: "us-east-1", Key: "data/a.csv", StorageClass: "STANDARD"},
+		{Bucket: "poc-2", Region: "ca-central-1", Key: "copy/a.csv", StorageClass: "GLACIER"},
+		{Bucket: "poc-2", Region: "ca-central-1", Key: "copy2/a.csv", StorageClass: "STANDARD"},
+	}, report.Groups[0].Objects)
+
+	assert.Equal(t, "def-2", report.Groups[1].ETag)
+	assert.True(t, report.Groups[1].Multipart)
+	assert.InDelta(t, 2*0.025, report.Groups[1].WastedCost, 0.000001)
+
+	dir := index.dir
+	assert.Nil(t, index.Close())
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDuplicateIndexSortedRuns(t *testing.T) {
+	index, err := NewDuplicateIndex()
+	assert.Nil(t, err)
+	defer index.Close()
+	// The objects of a shard don't fit in a batch
+	index.sortBatch = 2
+	for _, folder := range []string{"a", "b", "c"} {
+		for i := range 5 {
+			index.add("poc-1", "us-east-1", fmt.Sprintf("%s/%d.csv", folder, i), fmt.Sprintf("etag-%d", i), int64(i+1), "STANDARD")
+		}
+	}
+	index.add("poc-1", "us-east-1", "unique.csv", "etag-unique", 1, "STANDARD")
+
+	report, err := index.Report(map[string]map[string]float64{"us-east-1": {"STANDARD": 0.023}})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, report.NbOfGroups)
+	assert.Equal(t, 10, report.NbOfDuplicates)
+	for _, group := range report.Groups {
+		assert.Equal(t, 3, group.NbOfCopies)
+	}
+	// The runs are removed once merged
+	files, err := os.ReadDir(index.dir)
+	assert.Nil(t, err)
+	for _, file := range files {
+		assert.NotContains(t, file.Name(), ".run-")
+	}
+}
+
+func TestFetchBucketDuplicates(t *testing.T) {
+	mock := &AwsClientMock{
+		Objects: map[string][]types.Object{
+			"poc-1": {
+				{Key: aws.String("a.csv"), ETag: aws.String(`"abc"`), Size: aws.Int64(10), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("b.csv"), ETag: aws.String(`"def"`), Size: aws.Int64(20), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+			},
+			"poc-2": {
+				{Key: aws.String("copy/a.csv"), ETag: aws.String(`"abc"`), Size: aws.Int64(10), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+			},
+		},
+	}
+	index, err := NewDuplicateIndex()
+	assert.Nil(t, err)
+	defer index.Close()
+	fs := &S3{
+		session: mock,
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": map[string]float64{}},
+		},
+		options:    util.CliOptions{FindDuplicates: true, Threading: 2},
+		region:     "ca-central-1",
+		duplicates: index,
+	}
+	buckets := []util.CloudFilesystem{
+		&util.BucketDTO{Name: "poc-1", Region: "ca-central-1"},
+		&util.BucketDTO{Name: "poc-2", Region: "ca-central-1"},
+	}
+	fs.GetObject(context.Background(), buckets, nil)
+
+	report, err := index.Report(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.NbOfGroups)
+	assert.Equal(t, float64(10), report.WastedSize)
+	assert.Equal(t, []util.DuplicateObject{
+		{Bucket: "poc-1", Region: "ca-central-1", Key: "a.csv", StorageClass: "STANDARD"},
+		{Bucket: "poc-2", Region: "ca-central-1", Key: "copy/a.csv", StorageClass: "STANDARD"},
+	}, report.Groups[0].Objects)
+}
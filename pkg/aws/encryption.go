@@ -0,0 +1,98 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+
+	"projet-devops-coveo/pkg/util"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+)
+
+// Encryption status of the objects in the inventory reports, the others have the same name.
+const INVENTORY_ENCRYPTION_NONE = "NOT-SSE"
+
+// Get the default encryption of a bucket with the KMS key if there's one.
+// The first rule is the one applied to the new objects.
+func (fs *S3) fetchDefaultEncryption(ctx context.Context, bucketName string) (string, string, error) {
+	output, err := fs.session.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: aws.String(bucketName)})
+	if isConfigNotFound(err) {
+		return util.ENCRYPTION_NONE, "", nil
+	}
+	if err != nil {
+		return util.ENCRYPTION_UNKNOWN, "", err
+	}
+	if output.ServerSideEncryptionConfiguration == nil {
+		return util.ENCRYPTION_NONE, "", nil
+	}
+	for _, rule := range output.ServerSideEncryptionConfiguration.Rules {
+		if rule.ApplyServerSideEncryptionByDefault != nil {
+			return GetEncryptionType(rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm), aws.ToString(rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID), nil
+		}
+	}
+	return util.ENCRYPTION_NONE, "", nil
+}
+
+// Filter Bucket by default encryption. A bucket whose encryption is unknown is kept so it isn't dropped silently.
+func (fs *S3) isEncryptionIncluded(encryption string) bool {
+	if len(fs.options.FilterByEncryption) == 0 || encryption == util.ENCRYPTION_UNKNOWN {
+		return true
+	}
+	return slices.ContainsFunc(fs.options.FilterByEncryption, func(filter string) bool {
+		return strings.EqualFold(filter, encryption)
+	})
+}
+
+// Objects are sampled by the hash of their key, so the same objects are sampled when a scan is resumed.
+func (fs *S3) isObjectSampled(key string) bool {
+	if fs.options.EncryptionSample <= 0 {
+		return false
+	}
+	if fs.options.EncryptionSample >= 1 {
+		return true
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return float64(hash.Sum32()) < fs.options.EncryptionSample*math.MaxUint32
+}
+
+// Head an object to get its encryption. The object is counted as unknown if it can't be headed.
+func (fs *S3) headObjectEncryption(ctx context.Context, bucketName string, key string) string {
+	output, err := fs.session.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if isBadRequest(err) {
+		// An object encrypted with SSE-C can't be headed without its key
+		return util.ENCRYPTION_SSE_C
+	}
+	if err != nil {
+		logrus.Debug("Unable to head object ", key, " of bucket ", bucketName, ": ", err)
+		return util.ENCRYPTION_UNKNOWN
+	}
+	if output.SSECustomerAlgorithm != nil {
+		return util.ENCRYPTION_SSE_C
+	}
+	return GetEncryptionType(output.ServerSideEncryption)
+}
+
+// HeadObject has no body, S3 only answers 400 Bad Request.
+func isBadRequest(err error) bool {
+	var response interface{ HTTPStatusCode() int }
+	return errors.As(err, &response) && response.HTTPStatusCode() == http.StatusBadRequest
+}
+
+// Encryption status of an inventory report.
+func getInventoryEncryption(status string) string {
+	if status == INVENTORY_ENCRYPTION_NONE {
+		return util.ENCRYPTION_NONE
+	}
+	return status
+}
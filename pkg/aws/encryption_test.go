@@ -0,0 +1,114 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"projet-devops-coveo/pkg/util"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchBucketEncryption(t *testing.T) {
+	mock := &AwsClientMock{
+		Objects: map[string][]types.Object{
+			"poc-1": {
+				{Key: aws.String("a.txt"), Size: aws.Int64(1), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("b.txt"), Size: aws.Int64(2), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("c.txt"), Size: aws.Int64(4), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("d.txt"), Size: aws.Int64(8), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+			},
+			"poc-2": {
+				{Key: aws.String("a.txt"), Size: aws.Int64(1), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+			},
+		},
+		Configs: map[string]*BucketConfigMock{
+			"poc-1": {
+				Encryption: &s3.GetBucketEncryptionOutput{ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{
+					Rules: []types.ServerSideEncryptionRule{{ApplyServerSideEncryptionByDefault: &types.ServerSideEncryptionByDefault{
+						SSEAlgorithm:   types.ServerSideEncryptionAwsKms,
+						KMSMasterKeyID: aws.String("arn:aws:kms:ca-central-1:123456789012:key/poc-1"),
+					}}},
+				}},
+			},
+		},
+		Encryptions: map[string]types.ServerSideEncryption{
+			"poc-1/a.txt": types.ServerSideEncryptionAwsKms,
+			"poc-1/b.txt": types.ServerSideEncryptionAwsKms,
+			"poc-1/d.txt": util.ENCRYPTION_SSE_C,
+		},
+	}
+	tests := []struct {
+		name               string
+		options            util.CliOptions
+		expectedEncryption map[string]string
+		expectedNbOfFiles  map[string]int64
+		expectedSize       util.StorageClassSizeMap
+	}{
+		{
+			name:               "Default encryption",
+			options:            util.CliOptions{},
+			expectedEncryption: map[string]string{"poc-1": "SSE-KMS", "poc-2": "none"},
+		},
+		{
+			name:               "All objects headed",
+			options:            util.CliOptions{EncryptionSample: 1},
+			expectedEncryption: map[string]string{"poc-1": "SSE-KMS", "poc-2": "none"},
+			expectedNbOfFiles:  map[string]int64{"SSE-KMS": 2, "SSE-S3": 1, "SSE-C": 1},
+			expectedSize:       util.StorageClassSizeMap{"SSE-KMS": 3, "SSE-S3": 4, "SSE-C": 8},
+		},
+		{
+			name:               "Filter by encryption",
+			options:            util.CliOptions{FilterByEncryption: []string{"sse-kms"}},
+			expectedEncryption: map[string]string{"poc-1": "SSE-KMS"},
+		},
+	}
+	for _, test := range tests {
+		test.options.Threading = 2
+		test.options.PartitionDepth = 1
+		fs := &S3{
+			session: mock,
+			totalStorageClassSize: &util.StorageClassSize{
+				SizeMap: util.RegionsStorageMap{"ca-central-1": map[string]float64{}},
+			},
+			options: test.options,
+			region:  "ca-central-1",
+		}
+		buckets := fs.GetObject(context.Background(), []util.CloudFilesystem{
+			&util.BucketDTO{Name: "poc-1", Region: "ca-central-1"},
+			&util.BucketDTO{Name: "poc-2", Region: "ca-central-1"},
+		}, nil)
+
+		encryption := make(map[string]string)
+		for _, bucket := range buckets {
+			encryption[bucket.GetName()] = bucket.GetEncryption()
+			if bucket.GetName() == "poc-1" {
+				assert.Equal(t, "arn:aws:kms:ca-central-1:123456789012:key/poc-1", bucket.GetEncryptionKMSKeyId(), test.name)
+				assert.Equal(t, test.expectedNbOfFiles, bucket.GetObjectEncryptionNbOfFiles(), test.name)
+				assert.Equal(t, test.expectedSize, bucket.GetObjectEncryptionSize(), test.name)
+			}
+		}
+		assert.Equal(t, test.expectedEncryption, encryption, test.name)
+	}
+}
+
+func TestIsObjectSampled(t *testing.T) {
+	fs := &S3{options: util.CliOptions{EncryptionSample: 0.1}}
+	var sampled int
+	for i := 0; i < 10000; i++ {
+		if fs.isObjectSampled(fmt.Sprintf("logs/%d.log", i)) {
+			sampled++
+		}
+	}
+	assert.InDelta(t, 1000, sampled, 100)
+	// The same keys are sampled every time
+	assert.Equal(t, fs.isObjectSampled("logs/1.log"), fs.isObjectSampled("logs/1.log"))
+
+	fs.options.EncryptionSample = 0
+	assert.False(t, fs.isObjectSampled("logs/1.log"))
+	fs.options.EncryptionSample = 1
+	assert.True(t, fs.isObjectSampled("logs/1.log"))
+}
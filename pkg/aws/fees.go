@@ -0,0 +1,185 @@
+package aws
+
+import (
+	"regexp"
+	"strings"
+
+	"projet-devops-coveo/pkg/util"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Usage types of the price list billed per request and per GB retrieved for a storage class, the retrieval is
+// empty for the storage classes without retrieval fee.
+type storageClassFees struct {
+	tier1Requests string
+	tier2Requests string
+	retrieval     string
+}
+
+var storageClassFeeUsageTypes = map[string]storageClassFees{
+	S3_STORAGE_CLASS_STANDARD:                {"Requests-Tier1", "Requests-Tier2", ""},
+	S3_STORAGE_CLASS_REDUCED_REDUNDANCY:      {"Requests-Tier1", "Requests-Tier2", ""},
+	S3_STORAGE_CLASS_STANDARD_IA:             {"Requests-SIA-Tier1", "Requests-SIA-Tier2", "Retrieval-SIA"},
+	S3_STORAGE_CLASS_ONEZONE_IA:              {"Requests-ZIA-Tier1", "Requests-ZIA-Tier2", "Retrieval-ZIA"},
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING:     {"Requests-INT-Tier1", "Requests-INT-Tier2", ""},
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING_IA:  {"Requests-INT-Tier1", "Requests-INT-Tier2", ""},
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING_AIA: {"Requests-INT-Tier1", "Requests-INT-Tier2", ""},
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING_AA:  {"Requests-INT-Tier1", "Requests-INT-Tier2", ""},
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING_DAA: {"Requests-INT-Tier1", "Requests-INT-Tier2", ""},
+	S3_STORAGE_CLASS_GLACIER_IR:              {"Requests-GIR-Tier1", "Requests-GIR-Tier2", "Retrieval-GIR"},
+	S3_STORAGE_CLASS_GLACIER:                 {"Requests-GLACIER-Tier1", "Requests-GLACIER-Tier2", "Retrieval-GLACIER"},
+	S3_STORAGE_CLASS_DEEP_ARCHIVE:            {"Requests-GDA-Tier1", "Requests-GDA-Tier2", "Retrieval-GDA"},
+}
+
+const (
+	// Per object of 128KB or more in Intelligent-Tiering
+	USAGE_TYPE_MONITORING = "Monitoring-Automation-INT"
+	// Per GB transferred out to the internet
+	USAGE_TYPE_EGRESS = "DataTransfer-Out-Bytes"
+)
+
+// The usage types are prefixed by the code of their region in the price list, except in us-east-1 (Ex.: CAN1-Requests-Tier1).
+var usageTypeRegionPrefix = regexp.MustCompile(`^[A-Z]{2,4}[0-9]?-`)
+
+func getUsageType(product Product) string {
+	return usageTypeRegionPrefix.ReplaceAllString(product.Attributes.Usagetype, "")
+}
+
+// Whether the usage type is one of the fees billed on top of the storage.
+func isFeeUsageType(usageType string) bool {
+	if usageType == USAGE_TYPE_MONITORING || usageType == USAGE_TYPE_EGRESS {
+		return true
+	}
+	for _, fees := range storageClassFeeUsageTypes {
+		if usageType == fees.tier1Requests || usageType == fees.tier2Requests || usageType != "" && usageType == fees.retrieval {
+			return true
+		}
+	}
+	return false
+}
+
+// Key of a product in the price list of its region: the usage type for the fees and the volume type for the storage.
+// Empty for the products that are not priced.
+func getPriceListKey(product Product) string {
+	usageType := getUsageType(product)
+	if isFeeUsageType(usageType) {
+		return usageType
+	}
+	if product.ProductFamily == "Storage" && product.Attributes.Operation == "" && usageType != "TagStorage-TagHrs" {
+		return product.Attributes.VolumeType
+	}
+	return ""
+}
+
+// Monthly volumes of the fees of a bucket by usage type. The requests and the data read are split over the storage
+// classes of the bucket by size, they are all in STANDARD for an empty bucket.
+func getFeeVolumes(bucket util.CloudFilesystem) map[string]float64 {
+	volumes := make(map[string]float64)
+	usage := bucket.GetUsage()
+	if usage == nil {
+		return volumes
+	}
+	sizes := bucketStorageClassSize(bucket)
+	var totalSize float64
+	for _, v := range sizes {
+		totalSize += v
+	}
+	if totalSize <= 0 {
+		sizes, totalSize = util.StorageClassSizeMap{S3_STORAGE_CLASS_STANDARD: 1}, 1
+	}
+	for storageClass, size := range sizes {
+		fees, ok := storageClassFeeUsageTypes[storageClass]
+		if !ok || size <= 0 {
+			continue
+		}
+		share := size / totalSize
+		volumes[fees.tier1Requests] += usage.Tier1Requests * share
+		volumes[fees.tier2Requests] += usage.Tier2Requests * share
+		if fees.retrieval != "" {
+			volumes[fees.retrieval] += usage.RetrievalGB * share
+		}
+	}
+	volumes[USAGE_TYPE_MONITORING] += float64(usage.MonitoredObjects)
+	volumes[USAGE_TYPE_EGRESS] += usage.EgressGB
+	for k, v := range volumes {
+		if v <= 0 {
+			delete(volumes, k)
+		}
+	}
+	return volumes
+}
+
+// Price per unit of the fees of each region, the tier is the one of the total volume of the buckets in the region.
+func GetFeeListPrices(buckets []util.CloudFilesystem, priceList MasterPriceList) map[string]map[string]float64 {
+	totalVolumes := make(map[string]map[string]float64)
+	for _, bucket := range buckets {
+		region := bucket.GetRegion()
+		for usageType, volume := range getFeeVolumes(bucket) {
+			if totalVolumes[region] == nil {
+				totalVolumes[region] = make(map[string]float64)
+			}
+			totalVolumes[region][usageType] += volume
+		}
+	}
+	feeListPrices := make(map[string]map[string]float64)
+	for region, volumes := range totalVolumes {
+		// The regions without prices are already reported
+		regionPriceList, ok := priceList[region]
+		if !ok {
+			continue
+		}
+		feeListPrices[region] = make(map[string]float64)
+		for usageType, volume := range volumes {
+			priceListForUsageType, ok := regionPriceList[usageType]
+			if !ok {
+				logrus.Warn("No price for ", usageType, " in ", region, ", its cost is not computed")
+				continue
+			}
+			price, err := getPriceForSize(volume, priceListForUsageType)
+			if err != nil {
+				logrus.Error(err)
+				continue
+			}
+			feeListPrices[region][usageType] = price
+		}
+	}
+	return feeListPrices
+}
+
+// Fees of the lifecycle transitions by storage class they transition to, billed as its PUT requests (tier 1).
+func getTransitionFees(nbOfTransitions map[string]int64, regionPriceList ProductPriceList) float64 {
+	var fees float64
+	for storageClass, nb := range nbOfTransitions {
+		usageType := storageClassFeeUsageTypes[storageClass].tier1Requests
+		priceListForUsageType, ok := regionPriceList[usageType]
+		if !ok {
+			logrus.Warn("No price for ", usageType, ", the fees of the transitions to ", storageClass, " are not computed")
+			continue
+		}
+		price, err := getPriceForSize(float64(nb), priceListForUsageType)
+		if err != nil {
+			logrus.Error(err)
+			continue
+		}
+		fees += float64(nb) * price
+	}
+	return fees
+}
+
+// Add the monthly cost of the fees to the line items of the breakdown.
+func addFeeCost(breakdown *util.CostBreakdown, volumes map[string]float64, feeListPrice map[string]float64) {
+	for usageType, volume := range volumes {
+		cost := volume * feeListPrice[usageType]
+		switch {
+		case usageType == USAGE_TYPE_EGRESS:
+			breakdown.Transfer += cost
+		case usageType == USAGE_TYPE_MONITORING:
+			breakdown.Monitoring += cost
+		case strings.HasPrefix(usageType, "Retrieval-"):
+			breakdown.Retrieval += cost
+		default:
+			breakdown.Requests += cost
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package aws
+
+import (
+	"projet-devops-coveo/pkg/util"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPriceListKey(t *testing.T) {
+	product := func(productFamily string, usageType string, volumeType string) Product {
+		var product Product
+		product.ProductFamily = productFamily
+		product.Attributes.Usagetype = usageType
+		product.Attributes.VolumeType = volumeType
+		return product
+	}
+	assert.Equal(t, "Standard", getPriceListKey(product("Storage", "CAN1-TimedStorage-ByteHrs", "Standard")))
+	assert.Equal(t, "", getPriceListKey(product("Storage", "CAN1-TagStorage-TagHrs", "Tags")))
+	// us-east-1 has no region prefix
+	assert.Equal(t, "Requests-SIA-Tier1", getPriceListKey(product("API Request", "Requests-SIA-Tier1", "")))
+	assert.Equal(t, "Requests-Tier2", getPriceListKey(product("API Request", "EU-Requests-Tier2", "")))
+	assert.Equal(t, "Retrieval-GIR", getPriceListKey(product("Fee", "CAN1-Retrieval-GIR", "")))
+	assert.Equal(t, USAGE_TYPE_MONITORING, getPriceListKey(product("Fee", "CAN1-Monitoring-Automation-INT", "")))
+	assert.Equal(t, USAGE_TYPE_EGRESS, getPriceListKey(product("Data Transfer", "CAN1-DataTransfer-Out-Bytes", "")))
+	assert.Equal(t, "", getPriceListKey(product("Data Transfer", "CAN1-USE1-AWS-Out-Bytes", "")))
+	assert.Equal(t, "", getPriceListKey(product("API Request", "CAN1-Requests-Tier3", "")))
+}
+
+func TestGetFeeVolumes(t *testing.T) {
+	bucket := &util.BucketDTO{
+		StorageClassSize:           util.StorageClassSizeMap{"STANDARD": 300},
+		NoncurrentStorageClassSize: util.StorageClassSizeMap{"STANDARD_IA": 100},
+		Usage:                      &util.BucketUsage{Tier1Requests: 1000, Tier2Requests: 4000, RetrievalGB: 8, EgressGB: 10},
+	}
+	assert.Equal(t, map[string]float64{
+		"Requests-Tier1":     750,
+		"Requests-Tier2":     3000,
+		"Requests-SIA-Tier1": 250,
+		"Requests-SIA-Tier2": 1000,
+		"Retrieval-SIA":      2,
+		USAGE_TYPE_EGRESS:    10,
+	}, getFeeVolumes(bucket))
+
+	// The requests of an empty bucket are in STANDARD
+	empty := &util.BucketDTO{Usage: &util.BucketUsage{Tier1Requests: 100, MonitoredObjects: 5}}
+	assert.Equal(t, map[string]float64{"Requests-Tier1": 100, USAGE_TYPE_MONITORING: 5}, getFeeVolumes(empty))
+	assert.Empty(t, getFeeVolumes(&util.BucketDTO{}))
+}
+
+func TestSetBucketCostWithUsage(t *testing.T) {
+	gb := float64(1024 * 1024 * 1024)
+	fs := &S3{
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": {"STANDARD": 3 * gb, "STANDARD_IA": gb}},
+		},
+	}
+	priceList := MasterPriceList{
+		"ca-central-1": flatPriceList(map[string]string{
+			"Standard":                     "0.02",
+			"Standard - Infrequent Access": "0.01",
+			"Requests-Tier1":               "0.001",
+			"Requests-Tier2":               "0.0001",
+			"Requests-SIA-Tier1":           "0.002",
+			"Requests-SIA-Tier2":           "0.0002",
+			"Retrieval-SIA":                "0.01",
+			USAGE_TYPE_MONITORING:          "0.0025",
+			USAGE_TYPE_EGRESS:              "0.09",
+		}),
+	}
+	bucket := &util.BucketDTO{
+		Name:             "poc-1",
+		Region:           "ca-central-1",
+		StorageClassSize: util.StorageClassSizeMap{"STANDARD": 3 * gb, "STANDARD_IA": gb},
+		Usage:            &util.BucketUsage{Tier1Requests: 1000, Tier2Requests: 4000, RetrievalGB: 8, EgressGB: 10},
+	}
+	monitored := &util.BucketDTO{
+		Name:   "poc-2",
+		Region: "ca-central-1",
+		Usage:  &util.BucketUsage{MonitoredObjects: 1000},
+	}
+	fs.SetBucketCost([]util.CloudFilesystem{bucket, monitored}, priceList)
+
+	assert.InDelta(t, 0.07, bucket.CostBreakdown.Storage, 1e-6)
+	assert.InDelta(t, 0.75+0.5+0.3+0.2, bucket.CostBreakdown.Requests, 1e-6)
+	assert.InDelta(t, 0.02, bucket.CostBreakdown.Retrieval, 1e-6)
+	assert.InDelta(t, 0.9, bucket.CostBreakdown.Transfer, 1e-6)
+	assert.InDelta(t, 0.07+1.75+0.02+0.9, bucket.Cost, 1e-6)
+	assert.InDelta(t, 2.5, monitored.CostBreakdown.Monitoring, 1e-6)
+	assert.InDelta(t, 2.5, monitored.Cost, 1e-6)
+}
@@ -0,0 +1,458 @@
+package aws
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/parquet-go/parquet-go"
+)
+
+const (
+	INVENTORY_MANIFEST_FILE    = "manifest.json"
+	INVENTORY_FORMAT_CSV       = "CSV"
+	INVENTORY_FORMAT_PARQUET   = "Parquet"
+	INVENTORY_FORMAT_ORC       = "ORC"
+	INVENTORY_PARQUET_BATCH    = 1024
+	INVENTORY_FIELD_KEY        = "Key"
+	INVENTORY_FIELD_VERSION    = "VersionId"
+	INVENTORY_FIELD_LATEST     = "IsLatest"
+	INVENTORY_FIELD_DELETE     = "IsDeleteMarker"
+	INVENTORY_FIELD_SIZE       = "Size"
+	INVENTORY_FIELD_MODIFIED   = "LastModifiedDate"
+	INVENTORY_FIELD_STORAGE    = "StorageClass"
+	INVENTORY_FIELD_ENCRYPTION = "EncryptionStatus"
+	INVENTORY_FIELD_ETAG       = "ETag"
+)
+
+// Column names of the fields in the Parquet inventory files.
+var inventoryParquetColumns = map[string]string{
+	INVENTORY_FIELD_KEY:        "key",
+	INVENTORY_FIELD_VERSION:    "version_id",
+	INVENTORY_FIELD_LATEST:     "is_latest",
+	INVENTORY_FIELD_DELETE:     "is_delete_marker",
+	INVENTORY_FIELD_SIZE:       "size",
+	INVENTORY_FIELD_MODIFIED:   "last_modified_date",
+	INVENTORY_FIELD_STORAGE:    "storage_class",
+	INVENTORY_FIELD_ENCRYPTION: "encryption_status",
+	INVENTORY_FIELD_ETAG:       "e_tag",
+}
+
+// manifest.json of an S3 Inventory report
+type InventoryManifest struct {
+	SourceBucket      string          `json:"sourceBucket"`
+	DestinationBucket string          `json:"destinationBucket"`
+	Version           string          `json:"version"`
+	CreationTimestamp string          `json:"creationTimestamp"`
+	FileFormat        string          `json:"fileFormat"`
+	FileSchema        string          `json:"fileSchema"`
+	Files             []InventoryFile `json:"files"`
+	reader            inventoryReader
+}
+
+type InventoryFile struct {
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	MD5checksum string `json:"MD5checksum"`
+}
+
+// Object (or version of an object) listed in an inventory report
+type inventoryRecord struct {
+	key              string
+	versionId        string
+	isLatest         bool
+	isDeleteMarker   bool
+	size             int64
+	lastModified     time.Time
+	storageClass     string
+	encryptionStatus string
+	eTag             string
+}
+
+// Access to the files of an inventory report, either on disk or in the destination bucket.
+type inventoryReader interface {
+	open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+type localInventoryReader struct {
+	manifestDir string
+}
+
+type s3InventoryReader struct {
+	session AwsInterface
+	bucket  string
+}
+
+// Client in the region of a bucket, the destination buckets of the inventories can be in any region.
+type BucketClientFunc func(ctx context.Context, bucket string) (AwsInterface, error)
+
+// Load the latest inventory manifest of each source bucket from the locations given by the user.
+// A location is a manifest.json file, a directory containing manifests or an s3://bucket/prefix of the inventory destination.
+func LoadInventoryManifests(ctx context.Context, bucketClient BucketClientFunc, locations []string) (map[string]*InventoryManifest, error) {
+	manifests := make(map[string]*InventoryManifest)
+	for _, location := range locations {
+		var found []*InventoryManifest
+		var err error
+		if strings.HasPrefix(location, S3_URI_SCHEME) {
+			found, err = loadS3InventoryManifests(ctx, bucketClient, location)
+		} else {
+			found, err = loadLocalInventoryManifests(location)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, manifest := range found {
+			current, ok := manifests[manifest.SourceBucket]
+			if !ok || current.creationTime().Before(manifest.creationTime()) {
+				manifests[manifest.SourceBucket] = manifest
+			}
+		}
+	}
+	return manifests, nil
+}
+
+func loadLocalInventoryManifests(location string) (manifests []*InventoryManifest, err error) {
+	info, err := os.Stat(location)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		manifest, err := readLocalInventoryManifest(location)
+		if err != nil {
+			return nil, err
+		}
+		return []*InventoryManifest{manifest}, nil
+	}
+	err = filepath.WalkDir(location, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || entry.Name() != INVENTORY_MANIFEST_FILE {
+			return nil
+		}
+		manifest, err := readLocalInventoryManifest(filePath)
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, manifest)
+		return nil
+	})
+	return manifests, err
+}
+
+func readLocalInventoryManifest(filePath string) (*InventoryManifest, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return decodeInventoryManifest(file, &localInventoryReader{manifestDir: filepath.Dir(filePath)})
+}
+
+// List the manifests under the prefix and keep the latest one of each inventory configuration.
+// The manifests are stored under <prefix>/<source-bucket>/<config-id>/<date>/manifest.json
+func loadS3InventoryManifests(ctx context.Context, bucketClient BucketClientFunc, location string) ([]*InventoryManifest, error) {
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(location, S3_URI_SCHEME), "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid inventory location %q: missing bucket name", location)
+	}
+	session, err := bucketClient(ctx, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("inventory location %s: %w", location, err)
+	}
+	latest := make(map[string]string)
+	paginator := session.NewListObjectsV2Paginator(&s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for session.HasMorePages(paginator) {
+		resp, err := session.NextPage(ctx, paginator)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range resp.Contents {
+			if path.Base(*obj.Key) != INVENTORY_MANIFEST_FILE {
+				continue
+			}
+			configDir := path.Dir(path.Dir(*obj.Key))
+			if *obj.Key > latest[configDir] {
+				latest[configDir] = *obj.Key
+			}
+		}
+	}
+
+	reader := &s3InventoryReader{session: session, bucket: bucket}
+	var manifests []*InventoryManifest
+	for _, key := range latest {
+		body, err := reader.open(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := decodeInventoryManifest(body, reader)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests, nil
+}
+
+func decodeInventoryManifest(r io.Reader, reader inventoryReader) (*InventoryManifest, error) {
+	manifest := &InventoryManifest{}
+	if err := json.NewDecoder(r).Decode(manifest); err != nil {
+		return nil, err
+	}
+	if manifest.SourceBucket == "" {
+		return nil, errors.New("invalid inventory manifest: missing sourceBucket")
+	}
+	manifest.reader = reader
+	return manifest, nil
+}
+
+// Creation time of the report. The timestamp is in milliseconds since epoch.
+func (manifest *InventoryManifest) creationTime() time.Time {
+	millis, err := strconv.ParseInt(manifest.CreationTimestamp, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(millis)
+}
+
+// Read all the records of the data files of the report.
+func (manifest *InventoryManifest) ReadRecords(ctx context.Context, fn func(record inventoryRecord)) error {
+	for _, file := range manifest.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		switch manifest.FileFormat {
+		case INVENTORY_FORMAT_CSV:
+			err = manifest.readCSVFile(ctx, file.Key, fn)
+		case INVENTORY_FORMAT_PARQUET:
+			err = manifest.readParquetFile(ctx, file.Key, fn)
+		case INVENTORY_FORMAT_ORC:
+			return fmt.Errorf("inventory of bucket %s: ORC reports are not supported, configure the inventory with CSV or Parquet", manifest.SourceBucket)
+		default:
+			return fmt.Errorf("inventory of bucket %s: unknown file format %q", manifest.SourceBucket, manifest.FileFormat)
+		}
+		if err != nil {
+			return fmt.Errorf("inventory file %s: %w", file.Key, err)
+		}
+	}
+	return nil
+}
+
+// CSV files are gzipped, without header. The columns are described by the fileSchema of the manifest.
+func (manifest *InventoryManifest) readCSVFile(ctx context.Context, key string, fn func(record inventoryRecord)) error {
+	body, err := manifest.reader.open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	var r io.Reader = body
+	if strings.HasSuffix(key, ".gz") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	columns := make(map[string]int)
+	for i, field := range strings.Split(manifest.FileSchema, ",") {
+		columns[strings.TrimSpace(field)] = i
+	}
+	if _, ok := columns[INVENTORY_FIELD_KEY]; !ok {
+		return errors.New("missing Key field in fileSchema")
+	}
+	field := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return row[i]
+	}
+
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Keys are URL encoded in CSV reports
+		key, err := url.QueryUnescape(field(row, INVENTORY_FIELD_KEY))
+		if err != nil {
+			return err
+		}
+		record := inventoryRecord{
+			key:              key,
+			versionId:        field(row, INVENTORY_FIELD_VERSION),
+			isLatest:         field(row, INVENTORY_FIELD_LATEST) != "false",
+			isDeleteMarker:   field(row, INVENTORY_FIELD_DELETE) == "true",
+			storageClass:     field(row, INVENTORY_FIELD_STORAGE),
+			encryptionStatus: field(row, INVENTORY_FIELD_ENCRYPTION),
+			eTag:             field(row, INVENTORY_FIELD_ETAG),
+		}
+		if size := field(row, INVENTORY_FIELD_SIZE); size != "" {
+			record.size, err = strconv.ParseInt(size, 10, 64)
+			if err != nil {
+				return err
+			}
+		}
+		if modified := field(row, INVENTORY_FIELD_MODIFIED); modified != "" {
+			record.lastModified, err = time.Parse(time.RFC3339, modified)
+			if err != nil {
+				return err
+			}
+		}
+		fn(record)
+	}
+}
+
+// Parquet files are read row by row, the values being looked up by column name.
+func (manifest *InventoryManifest) readParquetFile(ctx context.Context, key string, fn func(record inventoryRecord)) error {
+	file, cleanup, err := manifest.openReaderAt(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	parquetFile, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		return err
+	}
+
+	// Columns missing from the report are set to -1 so they never match a value.
+	columns := make(map[string]int)
+	for field, name := range inventoryParquetColumns {
+		columns[field] = -1
+		if leaf, ok := parquetFile.Schema().Lookup(name); ok {
+			columns[field] = leaf.ColumnIndex
+		}
+	}
+	if columns[INVENTORY_FIELD_KEY] < 0 {
+		return errors.New("missing key column")
+	}
+
+	reader := parquet.NewReader(parquetFile)
+	defer reader.Close()
+	rows := make([]parquet.Row, INVENTORY_PARQUET_BATCH)
+	for {
+		n, err := reader.ReadRows(rows)
+		for _, row := range rows[:n] {
+			fn(parquetInventoryRecord(row, columns))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func parquetInventoryRecord(row parquet.Row, columns map[string]int) inventoryRecord {
+	record := inventoryRecord{isLatest: true}
+	for _, value := range row {
+		if value.IsNull() {
+			continue
+		}
+		switch value.Column() {
+		case columns[INVENTORY_FIELD_KEY]:
+			record.key = string(value.ByteArray())
+		case columns[INVENTORY_FIELD_VERSION]:
+			record.versionId = string(value.ByteArray())
+		case columns[INVENTORY_FIELD_LATEST]:
+			record.isLatest = value.Boolean()
+		case columns[INVENTORY_FIELD_DELETE]:
+			record.isDeleteMarker = value.Boolean()
+		case columns[INVENTORY_FIELD_SIZE]:
+			record.size = value.Int64()
+		case columns[INVENTORY_FIELD_MODIFIED]:
+			record.lastModified = time.UnixMilli(value.Int64()).UTC()
+		case columns[INVENTORY_FIELD_STORAGE]:
+			record.storageClass = string(value.ByteArray())
+		case columns[INVENTORY_FIELD_ENCRYPTION]:
+			record.encryptionStatus = string(value.ByteArray())
+		case columns[INVENTORY_FIELD_ETAG]:
+			record.eTag = string(value.ByteArray())
+		}
+	}
+	return record
+}
+
+// Parquet needs random access. Files of the destination bucket are downloaded to a temporary file.
+func (manifest *InventoryManifest) openReaderAt(ctx context.Context, key string) (*os.File, func(), error) {
+	body, err := manifest.reader.open(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if file, ok := body.(*os.File); ok {
+		return file, func() { file.Close() }, nil
+	}
+	defer body.Close()
+	file, err := os.CreateTemp("", "inventory-*.parquet")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return file, cleanup, nil
+}
+
+// Keys of the manifest are relative to the root of the destination bucket. A downloaded copy of the
+// destination bucket keeps the same layout, so the data file is searched from the manifest directory up.
+func (r *localInventoryReader) open(ctx context.Context, key string) (io.ReadCloser, error) {
+	dir := r.manifestDir
+	for {
+		file, err := os.Open(filepath.Join(dir, filepath.FromSlash(key)))
+		if err == nil {
+			return file, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return os.Open(filepath.Join(r.manifestDir, path.Base(key)))
+}
+
+func (r *s3InventoryReader) open(ctx context.Context, key string) (io.ReadCloser, error) {
+	output, err := r.session.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.Body, nil
+}
@@ -0,0 +1,243 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"os"
+	"projet-devops-coveo/pkg/util"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadInventoryManifests(t *testing.T) {
+	manifests, err := LoadInventoryManifests(context.Background(), nil, []string{"testdata/inventory"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(manifests))
+	// The latest report of poc-1 is kept
+	assert.Equal(t, "1714525200000", manifests["poc-1"].CreationTimestamp)
+	assert.Equal(t, INVENTORY_FORMAT_CSV, manifests["poc-1"].FileFormat)
+	assert.Equal(t, INVENTORY_FORMAT_PARQUET, manifests["poc-2"].FileFormat)
+
+	_, err = LoadInventoryManifests(context.Background(), nil, []string{"testdata/inventory/missing"})
+	assert.NotNil(t, err)
+}
+
+func TestFetchInventory(t *testing.T) {
+	manifests, err := LoadInventoryManifests(context.Background(), nil, []string{"testdata/inventory"})
+	assert.Nil(t, err)
+	tests := []struct {
+		name           string
+		bucketName     string
+		options        util.CliOptions
+		expectedOutput *util.BucketDTO
+	}{
+		{
+			name:       "CSV report",
+			bucketName: "poc-1",
+			options:    util.CliOptions{},
+			expectedOutput: &util.BucketDTO{
+				Name:                      "poc-1",
+				NbOfFiles:                 2,
+				SizeOfBucket:              1100,
+				LastUpdateDate:            time.Date(2024, 4, 20, 10, 0, 0, 0, time.UTC),
+				StorageClassSize:          util.StorageClassSizeMap{"STANDARD": 100, "GLACIER": 1000},
+				ObjectEncryptionNbOfFiles: map[string]int64{"SSE-S3": 1, "SSE-KMS": 1},
+				ObjectEncryptionSize:      util.StorageClassSizeMap{"SSE-S3": 100, "SSE-KMS": 1000},
+				SizeHistogram:             smallObjectsHistogram(2, 1100),
+				StorageClassSizeHistogram: map[string][]util.HistogramBin{
+					"STANDARD": smallObjectsHistogram(1, 100),
+					"GLACIER":  smallObjectsHistogram(1, 1000),
+				},
+				OldestUpdateDate: time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC),
+				MedianUpdateDate: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+				AgeHistogram:     oldObjectsHistogram(2, 1100),
+				StorageClassAgeHistogram: map[string][]util.HistogramBin{
+					"STANDARD": oldObjectsHistogram(1, 100),
+					"GLACIER":  oldObjectsHistogram(1, 1000),
+				},
+				NoncurrentStorageClassSize: util.StorageClassSizeMap{},
+				MultipartStorageClassSize:  util.StorageClassSizeMap{},
+				// The archived objects have metadata billed in GLACIER and in STANDARD
+				BillableSize:             100 + 1000 + 40<<10,
+				BillableStorageClassSize: util.StorageClassSizeMap{"STANDARD": 100 + 8<<10, "GLACIER": 1000 + 32<<10},
+			},
+		},
+		{
+			name:       "CSV report with versions",
+			bucketName: "poc-1",
+			options:    util.CliOptions{IncludeVersions: true},
+			expectedOutput: &util.BucketDTO{
+				Name:                      "poc-1",
+				NbOfFiles:                 2,
+				SizeOfBucket:              1100,
+				LastUpdateDate:            time.Date(2024, 4, 20, 10, 0, 0, 0, time.UTC),
+				StorageClassSize:          util.StorageClassSizeMap{"STANDARD": 100, "GLACIER": 1000},
+				ObjectEncryptionNbOfFiles: map[string]int64{"SSE-S3": 1, "SSE-KMS": 1},
+				ObjectEncryptionSize:      util.StorageClassSizeMap{"SSE-S3": 100, "SSE-KMS": 1000},
+				SizeHistogram:             smallObjectsHistogram(2, 1100),
+				StorageClassSizeHistogram: map[string][]util.HistogramBin{
+					"STANDARD": smallObjectsHistogram(1, 100),
+					"GLACIER":  smallObjectsHistogram(1, 1000),
+				},
+				OldestUpdateDate: time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC),
+				MedianUpdateDate: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+				AgeHistogram:     oldObjectsHistogram(2, 1100),
+				StorageClassAgeHistogram: map[string][]util.HistogramBin{
+					"STANDARD": oldObjectsHistogram(1, 100),
+					"GLACIER":  oldObjectsHistogram(1, 1000),
+				},
+				NbOfNoncurrentFiles:        2,
+				NoncurrentSize:             130,
+				NoncurrentStorageClassSize: util.StorageClassSizeMap{"STANDARD": 80, "STANDARD_IA": 50},
+				NbOfDeleteMarkers:          1,
+				MultipartStorageClassSize:  util.StorageClassSizeMap{},
+				// The noncurrent versions are billed at least 128KB in STANDARD_IA
+				BillableSize:             100 + 1000 + 40<<10 + 80 + 128<<10,
+				BillableStorageClassSize: util.StorageClassSizeMap{"STANDARD": 180 + 8<<10, "GLACIER": 1000 + 32<<10, "STANDARD_IA": 128 << 10},
+			},
+		},
+		{
+			name:       "Parquet report",
+			bucketName: "poc-2",
+			options:    util.CliOptions{},
+			expectedOutput: &util.BucketDTO{
+				Name:             "poc-2",
+				NbOfFiles:        3,
+				SizeOfBucket:     60,
+				LastUpdateDate:   time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC),
+				StorageClassSize: util.StorageClassSizeMap{"STANDARD": 10, "STANDARD_IA": 50},
+				SizeHistogram:    smallObjectsHistogram(3, 60),
+				StorageClassSizeHistogram: map[string][]util.HistogramBin{
+					"STANDARD":    smallObjectsHistogram(1, 10),
+					"STANDARD_IA": smallObjectsHistogram(2, 50),
+				},
+				OldestUpdateDate: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+				MedianUpdateDate: time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC),
+				AgeHistogram:     oldObjectsHistogram(3, 60),
+				StorageClassAgeHistogram: map[string][]util.HistogramBin{
+					"STANDARD":    oldObjectsHistogram(1, 10),
+					"STANDARD_IA": oldObjectsHistogram(2, 50),
+				},
+				NoncurrentStorageClassSize: util.StorageClassSizeMap{},
+				MultipartStorageClassSize:  util.StorageClassSizeMap{},
+				BillableSize:               10 + 256<<10,
+				BillableStorageClassSize:   util.StorageClassSizeMap{"STANDARD": 10, "STANDARD_IA": 256 << 10},
+			},
+		},
+		{
+			name:       "Parquet report with prefix",
+			bucketName: "poc-2",
+			options:    util.CliOptions{FilterByPrefix: []string{"s3://poc-2/b/"}},
+			expectedOutput: &util.BucketDTO{
+				Name:             "poc-2",
+				NbOfFiles:        2,
+				SizeOfBucket:     50,
+				LastUpdateDate:   time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC),
+				StorageClassSize: util.StorageClassSizeMap{"STANDARD_IA": 50},
+				SizeHistogram:    smallObjectsHistogram(2, 50),
+				StorageClassSizeHistogram: map[string][]util.HistogramBin{
+					"STANDARD_IA": smallObjectsHistogram(2, 50),
+				},
+				OldestUpdateDate: time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC),
+				MedianUpdateDate: time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC),
+				AgeHistogram:     oldObjectsHistogram(2, 50),
+				StorageClassAgeHistogram: map[string][]util.HistogramBin{
+					"STANDARD_IA": oldObjectsHistogram(2, 50),
+				},
+				NoncurrentStorageClassSize: util.StorageClassSizeMap{},
+				MultipartStorageClassSize:  util.StorageClassSizeMap{},
+				BillableSize:               256 << 10,
+				BillableStorageClassSize:   util.StorageClassSizeMap{"STANDARD_IA": 256 << 10},
+			},
+		},
+	}
+	for _, test := range tests {
+		objectFilter, err := NewObjectFilter(test.options)
+		assert.Nil(t, err)
+		fs := &S3{
+			options:      test.options,
+			objectFilter: objectFilter,
+		}
+		stats, err := fs.fetchInventory(context.Background(), test.bucketName, manifests[test.bucketName])
+		assert.Nil(t, err, test.name)
+		bucket := &util.BucketDTO{Name: test.bucketName}
+		stats.applyTo(bucket)
+		assert.True(t, test.expectedOutput.LastUpdateDate.Equal(bucket.LastUpdateDate), test.name)
+		bucket.LastUpdateDate = test.expectedOutput.LastUpdateDate
+		assert.True(t, test.expectedOutput.OldestUpdateDate.Equal(bucket.OldestUpdateDate), test.name)
+		bucket.OldestUpdateDate = test.expectedOutput.OldestUpdateDate
+		assert.Equal(t, test.expectedOutput, bucket, test.name)
+	}
+}
+
+func TestLoadInventoryManifestsFromS3(t *testing.T) {
+	manifest, err := os.ReadFile("testdata/inventory/csv/poc-1/config/2024-05-01T01-00Z/manifest.json")
+	assert.Nil(t, err)
+	data, err := os.ReadFile("testdata/inventory/csv/poc-1/config/data/5f3e2a.csv.gz")
+	assert.Nil(t, err)
+	mock := &AwsClientMock{
+		Objects: map[string][]types.Object{
+			"inventory-destination": {
+				{Key: aws.String("poc-1/config/2024-04-30T01-00Z/manifest.json")},
+				{Key: aws.String("poc-1/config/2024-05-01T01-00Z/manifest.json")},
+				{Key: aws.String("poc-1/config/data/5f3e2a.csv.gz")},
+			},
+		},
+		ObjectsData: map[string][]byte{
+			"inventory-destination/poc-1/config/2024-05-01T01-00Z/manifest.json": manifest,
+			"inventory-destination/poc-1/config/data/5f3e2a.csv.gz":              data,
+		},
+	}
+	// The destination bucket is read with a client of its region
+	var clientBuckets []string
+	bucketClient := func(ctx context.Context, bucket string) (AwsInterface, error) {
+		clientBuckets = append(clientBuckets, bucket)
+		return mock, nil
+	}
+	manifests, err := LoadInventoryManifests(context.Background(), bucketClient, []string{"s3://inventory-destination/poc-1/"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(manifests))
+	assert.Equal(t, []string{"inventory-destination"}, clientBuckets)
+
+	var keys []string
+	err = manifests["poc-1"].ReadRecords(context.Background(), func(record inventoryRecord) {
+		keys = append(keys, record.key)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"logs/app 1.log", "logs/app 1.log", "data/dump.bin", "data/deleted.bin", "data/deleted.bin"}, keys)
+
+	_, err = LoadInventoryManifests(context.Background(), func(ctx context.Context, bucket string) (AwsInterface, error) {
+		return nil, errors.New("AccessDenied")
+	}, []string{"s3://inventory-destination/poc-1/"})
+	assert.NotNil(t, err)
+}
+
+func TestReadRecordsUnsupportedFormat(t *testing.T) {
+	manifest := &InventoryManifest{
+		SourceBucket: "poc-1",
+		FileFormat:   INVENTORY_FORMAT_ORC,
+		Files:        []InventoryFile{{Key: "data/file.orc"}},
+	}
+	err := manifest.ReadRecords(context.Background(), func(record inventoryRecord) {})
+	assert.NotNil(t, err)
+}
+
+// Histogram of objects all smaller than 1KB.
+func smallObjectsHistogram(nbOfFiles int64, size float64) []util.HistogramBin {
+	bins := newHistogram(len(util.SIZE_HISTOGRAM_BINS)).bins(util.SIZE_HISTOGRAM_BINS)
+	bins[0].NbOfFiles = nbOfFiles
+	bins[0].Size = size
+	return bins
+}
+
+// Age histogram of objects all modified more than a year ago.
+func oldObjectsHistogram(nbOfFiles int64, size float64) []util.HistogramBin {
+	bins := newHistogram(len(util.AGE_HISTOGRAM_BINS)).bins(util.AGE_HISTOGRAM_BINS)
+	bins[len(bins)-1].NbOfFiles = nbOfFiles
+	bins[len(bins)-1].Size = size
+	return bins
+}
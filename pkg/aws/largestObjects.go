@@ -0,0 +1,191 @@
+package aws
+
+import (
+	"cmp"
+	"container/heap"
+	"maps"
+	"slices"
+	"time"
+
+	"projet-devops-coveo/pkg/util"
+)
+
+type largestObject struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	StorageClass string    `json:"storageClass"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// The smallest of two objects, the objects of the same size are ordered by key so the result doesn't depend on the order of the listing.
+func compareObjects(a, b largestObject) int {
+	return cmp.Or(cmp.Compare(a.Size, b.Size), cmp.Compare(b.Key, a.Key))
+}
+
+// Min-heap of the largest objects with --top, the smallest of them is the first one so it's replaced by a larger object.
+// The memory is bounded by the limit whatever the number of objects.
+type largestObjects struct {
+	Limit   int             `json:"limit"`
+	Objects []largestObject `json:"objects"`
+}
+
+func newLargestObjects(limit int) *largestObjects {
+	return &largestObjects{Limit: limit}
+}
+
+func (h *largestObjects) Len() int           { return len(h.Objects) }
+func (h *largestObjects) Less(i, j int) bool { return compareObjects(h.Objects[i], h.Objects[j]) < 0 }
+func (h *largestObjects) Swap(i, j int)      { h.Objects[i], h.Objects[j] = h.Objects[j], h.Objects[i] }
+func (h *largestObjects) Push(x any)         { h.Objects = append(h.Objects, x.(largestObject)) }
+func (h *largestObjects) Pop() any {
+	object := h.Objects[len(h.Objects)-1]
+	h.Objects = h.Objects[:len(h.Objects)-1]
+	return object
+}
+
+func (h *largestObjects) add(object largestObject) {
+	if len(h.Objects) < h.Limit {
+		heap.Push(h, object)
+	} else if h.Limit > 0 && compareObjects(object, h.Objects[0]) > 0 {
+		h.Objects[0] = object
+		heap.Fix(h, 0)
+	}
+}
+
+func (h *largestObjects) merge(partial *largestObjects) {
+	for _, object := range partial.Objects {
+		h.add(object)
+	}
+}
+
+// The largest objects first.
+func (h *largestObjects) sorted() []util.TopObject {
+	objects := slices.Clone(h.Objects)
+	slices.SortFunc(objects, func(a, b largestObject) int {
+		return compareObjects(b, a)
+	})
+	output := make([]util.TopObject, len(objects))
+	for i, object := range objects {
+		output[i] = util.TopObject{Key: object.Key, Size: float64(object.Size), StorageClass: object.StorageClass, LastModified: object.LastModified}
+	}
+	return output
+}
+
+// Number of top-level prefixes kept by the sketch for each prefix reported with --top.
+const LARGEST_PREFIXES_CAPACITY = 10
+
+// A prefix of the sketch. The error is the size it may have had before it replaced a smaller prefix.
+type sketchedPrefix struct {
+	Prefix string      `json:"prefix"`
+	Usage  prefixUsage `json:"usage"`
+	Error  int64       `json:"error"`
+}
+
+func (p *sketchedPrefix) estimate() int64 {
+	return p.Usage.Size + p.Error
+}
+
+// The smallest of two prefixes, ordered by prefix when they have the same estimate so the result doesn't depend on the order of the listing.
+func comparePrefixes(a, b *sketchedPrefix) int {
+	return cmp.Or(cmp.Compare(a.estimate(), b.estimate()), cmp.Compare(b.Prefix, a.Prefix))
+}
+
+// Space-saving sketch of the largest top-level prefixes with --top when the prefixes aren't all counted with --prefix-depth.
+// Once full, a new prefix replaces the smallest one. A prefix that is never replaced, like the largest ones usually,
+// has its exact usage, otherwise only the objects added since it replaced another one are counted.
+// The memory is bounded by the limit whatever the number of prefixes.
+type largestPrefixes struct {
+	Limit    int               `json:"limit"`
+	Prefixes []*sketchedPrefix `json:"prefixes"`
+	// Index of the prefixes in the heap
+	index map[string]int
+}
+
+func newLargestPrefixes(limit int) *largestPrefixes {
+	return &largestPrefixes{Limit: limit, index: make(map[string]int)}
+}
+
+func (h *largestPrefixes) Len() int { return len(h.Prefixes) }
+func (h *largestPrefixes) Less(i, j int) bool {
+	return comparePrefixes(h.Prefixes[i], h.Prefixes[j]) < 0
+}
+func (h *largestPrefixes) Swap(i, j int) {
+	h.Prefixes[i], h.Prefixes[j] = h.Prefixes[j], h.Prefixes[i]
+	h.index[h.Prefixes[i].Prefix] = i
+	h.index[h.Prefixes[j].Prefix] = j
+}
+func (h *largestPrefixes) Push(x any) {
+	prefix := x.(*sketchedPrefix)
+	h.index[prefix.Prefix] = len(h.Prefixes)
+	h.Prefixes = append(h.Prefixes, prefix)
+}
+func (h *largestPrefixes) Pop() any {
+	prefix := h.Prefixes[len(h.Prefixes)-1]
+	h.Prefixes = h.Prefixes[:len(h.Prefixes)-1]
+	delete(h.index, prefix.Prefix)
+	return prefix
+}
+
+func (h *largestPrefixes) add(prefix string, usage *prefixUsage, overestimate int64) {
+	if i, ok := h.index[prefix]; ok {
+		h.Prefixes[i].Usage.add(usage)
+		h.Prefixes[i].Error += overestimate
+		heap.Fix(h, i)
+		return
+	}
+	sketched := &sketchedPrefix{Prefix: prefix, Usage: prefixUsage{StorageClassSize: make(util.StorageClassSizeMap)}, Error: overestimate}
+	sketched.Usage.add(usage)
+	if len(h.Prefixes) < h.Limit*LARGEST_PREFIXES_CAPACITY {
+		heap.Push(h, sketched)
+	} else if h.Limit > 0 {
+		// The size of the smallest prefix may belong to the new one
+		smallest := h.Prefixes[0]
+		sketched.Error += smallest.estimate()
+		delete(h.index, smallest.Prefix)
+		h.Prefixes[0] = sketched
+		h.index[prefix] = 0
+		heap.Fix(h, 0)
+	}
+}
+
+func (h *largestPrefixes) merge(partial *largestPrefixes) {
+	for _, prefix := range partial.Prefixes {
+		h.add(prefix.Prefix, &prefix.Usage, prefix.Error)
+	}
+}
+
+// The largest prefixes first, by the size counted.
+func (h *largestPrefixes) sorted() []util.TopPrefix {
+	prefixes := make([]util.TopPrefix, len(h.Prefixes))
+	for i, prefix := range h.Prefixes {
+		prefixes[i] = util.TopPrefix{
+			Prefix:           prefix.Prefix,
+			NbOfFiles:        prefix.Usage.NbOfFiles,
+			Size:             float64(prefix.Usage.Size),
+			StorageClassSize: maps.Clone(prefix.Usage.StorageClassSize),
+			LastModified:     prefix.Usage.LastModified,
+		}
+	}
+	util.SortTopPrefixes(prefixes)
+	return prefixes[:min(h.Limit, len(prefixes))]
+}
+
+// The largest prefixes first. With --prefix-depth all the levels of the prefixes are ranked together,
+// otherwise the top-level prefixes of the sketch.
+func (stats *bucketStats) topPrefixes(limit int) []util.TopPrefix {
+	if stats.largestPrefixes != nil {
+		return stats.largestPrefixes.sorted()
+	}
+	prefixes := make([]util.TopPrefix, 0, len(stats.prefixes))
+	for prefix, usage := range stats.prefixes {
+		prefixes = append(prefixes, util.TopPrefix{
+			Prefix:           prefix,
+			NbOfFiles:        usage.NbOfFiles,
+			Size:             float64(usage.Size),
+			StorageClassSize: maps.Clone(usage.StorageClassSize),
+			LastModified:     usage.LastModified,
+		})
+	}
+	util.SortTopPrefixes(prefixes)
+	return prefixes[:min(limit, len(prefixes))]
+}
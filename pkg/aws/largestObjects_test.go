@@ -0,0 +1,110 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"projet-devops-coveo/pkg/util"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLargestObjects(t *testing.T) {
+	date := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	stats := newBucketStats()
+	for i, size := range []int64{5, 1, 9, 3, 9, 7} {
+		stats.addLargestObject(largestObject{Key: string(rune('a' + i)), Size: size, StorageClass: "STANDARD", LastModified: date}, 3)
+	}
+	partial := newBucketStats()
+	partial.addLargestObject(largestObject{Key: "z", Size: 8, StorageClass: "GLACIER", LastModified: date}, 3)
+	stats.merge(partial)
+	assert.Equal(t, 3, len(stats.largestObjects.Objects))
+
+	data, err := json.Marshal(stats)
+	assert.Nil(t, err)
+	loaded := newBucketStats()
+	assert.Nil(t, json.Unmarshal(data, loaded))
+	// The objects of the same size are ordered by key
+	assert.Equal(t, []util.TopObject{
+		{Key: "c", Size: 9, StorageClass: "STANDARD", LastModified: date},
+		{Key: "e", Size: 9, StorageClass: "STANDARD", LastModified: date},
+		{Key: "z", Size: 8, StorageClass: "GLACIER", LastModified: date},
+	}, loaded.largestObjects.sorted())
+
+	// The limit is kept in the checkpoint
+	loaded.addLargestObject(largestObject{Key: "y", Size: 10, StorageClass: "STANDARD", LastModified: date}, 3)
+	assert.Equal(t, 3, len(loaded.largestObjects.Objects))
+	assert.Equal(t, "y", loaded.largestObjects.sorted()[0].Key)
+}
+
+func TestLargestPrefixes(t *testing.T) {
+	date := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	stats := newBucketStats()
+	stats.addLargestPrefix("big/a.bin", 1000, "STANDARD", date, 1)
+	for i := range 100 {
+		stats.addLargestPrefix(fmt.Sprintf("small-%d/a.txt", i), 1, "STANDARD", date, 1)
+	}
+	stats.addLargestPrefix("big/b.bin", 10, "GLACIER", date, 1)
+	stats.addLargestPrefix("root.txt", 10000, "STANDARD", date, 1)
+	// The memory is bounded whatever the number of prefixes
+	assert.Equal(t, LARGEST_PREFIXES_CAPACITY, len(stats.largestPrefixes.Prefixes))
+
+	partial := newBucketStats()
+	partial.addLargestPrefix("big/c.bin", 5, "STANDARD", date, 1)
+	stats.merge(partial)
+	data, err := json.Marshal(stats)
+	assert.Nil(t, err)
+	loaded := newBucketStats()
+	assert.Nil(t, json.Unmarshal(data, loaded))
+	assert.Equal(t, []util.TopPrefix{
+		{Prefix: "big/", NbOfFiles: 3, Size: 1015, StorageClassSize: util.StorageClassSizeMap{"STANDARD": 1005, "GLACIER": 10}, LastModified: date},
+	}, loaded.topPrefixes(1))
+
+	// The prefixes are still indexed once loaded
+	loaded.addLargestPrefix("big/d.bin", 1, "STANDARD", date, 1)
+	assert.Equal(t, LARGEST_PREFIXES_CAPACITY, len(loaded.largestPrefixes.Prefixes))
+	assert.Equal(t, int64(4), loaded.topPrefixes(1)[0].NbOfFiles)
+}
+
+func TestFetchBucketTop(t *testing.T) {
+	mock := &AwsClientMock{
+		Objects: map[string][]types.Object{
+			"poc-1": {
+				{Key: aws.String("root.txt"), Size: aws.Int64(100), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("logs/2024/a.log"), Size: aws.Int64(10), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("logs/2024/b.log"), Size: aws.Int64(20), StorageClass: "GLACIER", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("logs/c.log"), Size: aws.Int64(30), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("data/d.bin"), Size: aws.Int64(40), StorageClass: "STANDARD_IA", LastModified: aws.Time(timeMock)},
+			},
+		},
+		PageSize: 2,
+	}
+	fs := &S3{
+		session: mock,
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": map[string]float64{}},
+		},
+		options: util.CliOptions{PartitionDepth: 1, Threading: 2, OutputOptions: &util.OutputOptions{Top: 2}},
+		region:  "ca-central-1",
+	}
+	bucket := &util.BucketDTO{Name: "poc-1", Region: "ca-central-1"}
+	fs.GetObject(context.Background(), []util.CloudFilesystem{bucket}, nil)
+
+	keys := []string{}
+	for _, object := range bucket.LargestObjects {
+		keys = append(keys, object.Key)
+	}
+	assert.Equal(t, []string{"root.txt", "data/d.bin"}, keys)
+	prefixes := []string{}
+	for _, prefix := range bucket.LargestPrefixes {
+		prefixes = append(prefixes, prefix.Prefix)
+	}
+	// The top-level prefixes without --prefix-depth, which isn't in the output
+	assert.Equal(t, []string{"logs/", "data/"}, prefixes)
+	assert.Equal(t, util.StorageClassSizeMap{"STANDARD": 40, "GLACIER": 20}, bucket.LargestPrefixes[0].StorageClassSize)
+	assert.Nil(t, bucket.Prefixes)
+}
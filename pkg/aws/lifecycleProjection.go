@@ -0,0 +1,442 @@
+package aws
+
+import (
+	"context"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"projet-devops-coveo/pkg/util"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+)
+
+// Lifecycle rules of a bucket with --lifecycle-projection. The expired objects of a versioned bucket become noncurrent.
+type bucketLifecycle struct {
+	Rules     []util.LifecycleRule
+	Versioned bool
+}
+
+// Ages of the objects matched by lifecycle rules, by signature of the rules, storage class and day.
+// The objects that no rule applies to are not kept, their storage class doesn't change.
+type lifecycleAges map[string]map[string]map[int64]*modifiedDay
+
+func (ages lifecycleAges) add(signature string, storageClass string, day int64, nbOfFiles int64, size int64, padding int64) {
+	classes, ok := ages[signature]
+	if !ok {
+		classes = make(map[string]map[int64]*modifiedDay)
+		ages[signature] = classes
+	}
+	days, ok := classes[storageClass]
+	if !ok {
+		days = make(map[int64]*modifiedDay)
+		classes[storageClass] = days
+	}
+	modified, ok := days[day]
+	if !ok {
+		modified = &modifiedDay{}
+		days[day] = modified
+	}
+	modified.NbOfFiles += nbOfFiles
+	modified.Size += size
+	modified.Padding += padding
+}
+
+func (ages lifecycleAges) merge(partial lifecycleAges) {
+	for signature, classes := range partial {
+		for storageClass, days := range classes {
+			for day, modified := range days {
+				if modified != nil {
+					ages.add(signature, storageClass, day, modified.NbOfFiles, modified.Size, modified.Padding)
+				}
+			}
+		}
+	}
+}
+
+// Fetch the lifecycle rules and the versioning of a bucket. A bucket without lifecycle configuration has no rules.
+func (fs *S3) fetchLifecycle(ctx context.Context, bucketName string) (*bucketLifecycle, error) {
+	lifecycle := &bucketLifecycle{}
+	output, err := fs.session.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String(bucketName)})
+	if err != nil && !isConfigNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		for _, rule := range output.Rules {
+			lifecycle.Rules = append(lifecycle.Rules, toLifecycleRule(rule))
+		}
+	}
+	versioning, err := fs.session.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		return nil, err
+	}
+	lifecycle.Versioned = versioning.Status != ""
+	return lifecycle, nil
+}
+
+func (fs *S3) getLifecycle(bucketName string) *bucketLifecycle {
+	lifecycle, ok := fs.lifecycles.Load(bucketName)
+	if !ok {
+		return nil
+	}
+	return lifecycle.(*bucketLifecycle)
+}
+
+// Whether a rule applies to an object from its key and its size, the tags are matched separately.
+func matchLifecycleFilter(rule util.LifecycleRule, key string, size int64) bool {
+	return rule.Status == LIFECYCLE_STATUS_ENABLED &&
+		strings.HasPrefix(key, rule.Prefix) &&
+		(rule.ObjectSizeGreaterThan == 0 || size > rule.ObjectSizeGreaterThan) &&
+		(rule.ObjectSizeLessThan == 0 || size < rule.ObjectSizeLessThan)
+}
+
+// Signature of the rules that apply to an object or to a version, the indexes of the rules separated by commas.
+// A noncurrent version has a rank, the number of noncurrent versions newer than itself, and the rules that keep it
+// with their number of newer noncurrent versions are marked with a k. The tags of the object are only fetched when
+// a rule filtered by tags could apply. The signature is empty when no rule applies.
+func (fs *S3) lifecycleSignature(ctx context.Context, bucketName string, key string, versionId string, size int64, rank int) string {
+	lifecycle := fs.getLifecycle(bucketName)
+	if lifecycle == nil {
+		return ""
+	}
+	var signature []string
+	var tags map[string]string
+	for i, rule := range lifecycle.Rules {
+		if !matchLifecycleFilter(rule, key, size) {
+			continue
+		}
+		if len(rule.Tags) != 0 {
+			if tags == nil {
+				tags = fs.fetchObjectTags(ctx, bucketName, key, versionId)
+			}
+			if !matchTags(rule.Tags, tags) {
+				continue
+			}
+		}
+		index := strconv.Itoa(i)
+		if rank >= 0 && int32(rank) < rule.NewerNoncurrentVersions {
+			index += "k"
+		}
+		signature = append(signature, index)
+	}
+	return strings.Join(signature, ",")
+}
+
+// Get the tags of an object. An object whose tags can't be fetched has no tags.
+func (fs *S3) fetchObjectTags(ctx context.Context, bucketName string, key string, versionId string) map[string]string {
+	input := &s3.GetObjectTaggingInput{Bucket: aws.String(bucketName), Key: aws.String(key)}
+	if versionId != "" {
+		input.VersionId = aws.String(versionId)
+	}
+	output, err := fs.session.GetObjectTagging(ctx, input)
+	tags := make(map[string]string)
+	if err != nil {
+		logrus.Debug("Unable to get the tags of object ", key, " of bucket ", bucketName, ": ", err)
+		return tags
+	}
+	for _, tag := range output.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags
+}
+
+// The object must have all the tags of the rule.
+func matchTags(filter map[string]string, tags map[string]string) bool {
+	for k, v := range filter {
+		if value, ok := tags[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Add a current object to the ages of its rules with --lifecycle-projection.
+func (fs *S3) addLifecycleObject(ctx context.Context, stats *bucketStats, bucketName string, key string, size int64, storageClass string, lastModified time.Time) {
+	if !fs.options.GetLifecycleProjection() {
+		return
+	}
+	if signature := fs.lifecycleSignature(ctx, bucketName, key, "", size, -1); signature != "" {
+		stats.lifecycleCurrent.add(signature, storageClass, toDay(lastModified), 1, size, getPadding(size))
+	}
+}
+
+// Day a version became noncurrent, when the next newer version or delete marker of its key was created.
+// The version is taken as noncurrent since it was created when there's none.
+func noncurrentSince(lastModified time.Time, newer time.Time) time.Time {
+	if newer.IsZero() {
+		return lastModified
+	}
+	return newer
+}
+
+// Add a noncurrent version to the ages of its rules with --lifecycle-projection, from the day it became noncurrent.
+func (fs *S3) addLifecycleNoncurrentVersion(ctx context.Context, stats *bucketStats, bucketName string, key string, versionId string, size int64, storageClass string, noncurrent time.Time, rank int) {
+	if !fs.options.GetLifecycleProjection() {
+		return
+	}
+	if signature := fs.lifecycleSignature(ctx, bucketName, key, versionId, size, rank); signature != "" {
+		stats.lifecycleNoncurrent.add(signature, storageClass, toDay(noncurrent), 1, size, getPadding(size))
+	}
+}
+
+// Rules of a signature, with the rules that keep the noncurrent versions of the signature.
+func (lifecycle *bucketLifecycle) signatureRules(signature string) (rules []util.LifecycleRule, kept []bool) {
+	for _, index := range strings.Split(signature, ",") {
+		index, keep := strings.CutSuffix(index, "k")
+		i, err := strconv.Atoi(index)
+		// The rules changed since the scan was interrupted
+		if err != nil || i >= len(lifecycle.Rules) {
+			continue
+		}
+		rules = append(rules, lifecycle.Rules[i])
+		kept = append(kept, keep)
+	}
+	return rules, kept
+}
+
+// Days since an action applies to an object of an age at a date, negative before. An action at a date applies to
+// all the objects from that date.
+func actionAge(days int32, actionDate *time.Time, age int64, date time.Time) int64 {
+	if actionDate != nil {
+		return min(age, toDay(date)-toDay(*actionDate))
+	}
+	return age - int64(days)
+}
+
+// Cheapest storage class of the transitions that apply to an object, the objects only move down the waterfall.
+func transitionClass(storageClass string, transitions []util.LifecycleTransition, age int64, date time.Time) string {
+	rank, ok := lifecycleWaterfall[storageClass]
+	if !ok {
+		return storageClass
+	}
+	projected := storageClass
+	for _, transition := range transitions {
+		if actionAge(transition.Days, transition.Date, age, date) < 0 {
+			continue
+		}
+		if transitionRank, ok := lifecycleWaterfall[transition.StorageClass]; ok && transitionRank > rank {
+			rank, projected = transitionRank, transition.StorageClass
+		}
+	}
+	return projected
+}
+
+// Storage class of a current object of an age at a date under its rules. When it expired, the days since it expired
+// are returned instead, the expiration wins over the transitions.
+func projectCurrentObject(rules []util.LifecycleRule, storageClass string, age int64, date time.Time) (string, int64, bool) {
+	expiredAge := int64(-1)
+	var transitions []util.LifecycleTransition
+	for _, rule := range rules {
+		if rule.ExpirationDays > 0 || rule.ExpirationDate != nil {
+			expiredAge = max(expiredAge, actionAge(rule.ExpirationDays, rule.ExpirationDate, age, date))
+		}
+		transitions = append(transitions, rule.Transitions...)
+	}
+	if expiredAge >= 0 {
+		return "", expiredAge, true
+	}
+	return transitionClass(storageClass, transitions, age, date), 0, false
+}
+
+// Storage class of a noncurrent version that has been noncurrent for a number of days under its rules,
+// empty when it's deleted.
+func projectNoncurrentVersion(rules []util.LifecycleRule, kept []bool, storageClass string, age int64, date time.Time) string {
+	var transitions []util.LifecycleTransition
+	for i, rule := range rules {
+		if rule.NoncurrentExpirationDays > 0 && !kept[i] && age >= int64(rule.NoncurrentExpirationDays) {
+			return ""
+		}
+		transitions = append(transitions, rule.NoncurrentTransitions...)
+	}
+	return transitionClass(storageClass, transitions, age, date)
+}
+
+// Rules that abort all the incomplete multipart uploads of the bucket.
+func (lifecycle *bucketLifecycle) abortMultipartUploadDays() int64 {
+	var days int64
+	for _, rule := range lifecycle.Rules {
+		if rule.Status != LIFECYCLE_STATUS_ENABLED || rule.AbortIncompleteMultipartUploadDays <= 0 || rule.Prefix != "" || len(rule.Tags) != 0 {
+			continue
+		}
+		if days == 0 || int64(rule.AbortIncompleteMultipartUploadDays) < days {
+			days = int64(rule.AbortIncompleteMultipartUploadDays)
+		}
+	}
+	return days
+}
+
+// Sizes of the bucket by storage class after each number of months of util.PROJECTION_MONTHS, with the billable
+// sizes whose overheads follow the objects in the storage classes they transition to. The uploads are only aborted
+// by the rules of the whole bucket since their keys are not kept.
+func (stats *bucketStats) projectLifecycle(lifecycle *bucketLifecycle, now time.Time) []util.CostProjection {
+	projections := make([]util.CostProjection, 0, len(util.PROJECTION_MONTHS))
+	abortDays := lifecycle.abortMultipartUploadDays()
+	for _, months := range util.PROJECTION_MONTHS {
+		date := now.AddDate(0, 0, months*DAYS_PER_MONTH)
+		sizes := stats.totalStorageClassSize()
+		billable := stats.billableStorageClassSize()
+		if abortDays != 0 && int64(months*DAYS_PER_MONTH) >= abortDays {
+			for k, v := range stats.multipartStorageClassSize {
+				sizes[k] -= v
+				billable[k] -= v
+			}
+		}
+		move := func(storageClass string, projected string, modified *modifiedDay) {
+			sizes[storageClass] -= float64(modified.Size)
+			addBillableSize(billable, storageClass, modified, -1)
+			if projected != "" {
+				sizes[projected] += float64(modified.Size)
+				addBillableSize(billable, projected, modified, 1)
+			}
+		}
+		for signature, classes := range stats.lifecycleCurrent {
+			rules, _ := lifecycle.signatureRules(signature)
+			for storageClass, days := range classes {
+				for day, modified := range days {
+					projected, expiredAge, expired := projectCurrentObject(rules, storageClass, toDay(date)-day, date)
+					// The expired object becomes the newest noncurrent version
+					if expired && lifecycle.Versioned {
+						projected = projectNoncurrentVersion(rules, keptNewest(rules), storageClass, expiredAge, date)
+					}
+					move(storageClass, projected, modified)
+				}
+			}
+		}
+		for signature, classes := range stats.lifecycleNoncurrent {
+			rules, kept := lifecycle.signatureRules(signature)
+			for storageClass, days := range classes {
+				for day, modified := range days {
+					move(storageClass, projectNoncurrentVersion(rules, kept, storageClass, toDay(date)-day, date), modified)
+				}
+			}
+		}
+		projection := util.CostProjection{
+			Months:                   months,
+			StorageClassSize:         make(util.StorageClassSizeMap),
+			BillableStorageClassSize: make(util.StorageClassSizeMap),
+		}
+		for k, v := range sizes {
+			// Rounding of the sizes removed
+			if v >= 1 {
+				projection.StorageClassSize[k] = v
+				projection.Size += v
+			}
+		}
+		for k, v := range billable {
+			if v >= 1 {
+				projection.BillableStorageClassSize[k] = v
+			}
+		}
+		projections = append(projections, projection)
+	}
+	return projections
+}
+
+// The newest noncurrent version is kept by the rules that keep at least one newer noncurrent version.
+func keptNewest(rules []util.LifecycleRule) []bool {
+	kept := make([]bool, len(rules))
+	for i, rule := range rules {
+		kept[i] = rule.NewerNoncurrentVersions > 0
+	}
+	return kept
+}
+
+// Size of the current objects, the noncurrent versions and the incomplete multipart uploads by storage class.
+func (stats *bucketStats) totalStorageClassSize() util.StorageClassSizeMap {
+	sizes := maps.Clone(stats.storageClassSize)
+	for k, v := range stats.noncurrentStorageClassSize {
+		sizes[k] += v
+	}
+	for k, v := range stats.multipartStorageClassSize {
+		sizes[k] += v
+	}
+	return sizes
+}
+
+// Set the costs of the projections of the buckets. The prices of each number of months are the tiers of the totals
+// of the regions once the sizes of the buckets are replaced by their projected sizes. The storage classes that can't be
+// priced are returned as errors.
+func (fs *S3) SetProjectionCost(buckets []util.CloudFilesystem, priceList MasterPriceList) []util.ScanError {
+	var priceErrors []util.ScanError
+	for i := range util.PROJECTION_MONTHS {
+		projectedPrices, monthErrors := fs.projectedTierListPrices(buckets, priceList, func(bucket util.CloudFilesystem) (util.StorageClassSizeMap, util.StorageClassSizeMap) {
+			projections := bucket.GetLifecycleProjection()
+			if len(projections) <= i {
+				return nil, nil
+			}
+			return bucketBillableStorageClassSize(bucket), projections[i].BillableStorageClassSize
+		})
+		for _, bucket := range buckets {
+			projections := bucket.GetLifecycleProjection()
+			if len(projections) <= i {
+				continue
+			}
+			projections[i].Cost = storageCost(projections[i].BillableStorageClassSize, projectedPrices[bucket.GetRegion()])
+			// The requests and the transfers are not projected
+			projections[i].Savings = bucket.GetCostBreakdown().Storage - projections[i].Cost
+		}
+		// The storage classes that can't be priced are the same most months
+		priceErrors = util.AppendScanErrors(priceErrors, monthErrors...)
+	}
+	return priceErrors
+}
+
+// Size of the current objects, the noncurrent versions and the incomplete multipart uploads of a bucket by storage class.
+func bucketStorageClassSize(bucket util.CloudFilesystem) util.StorageClassSizeMap {
+	sizes := maps.Clone(bucket.GetStorageClass())
+	if sizes == nil {
+		sizes = make(util.StorageClassSizeMap)
+	}
+	for k, v := range bucket.GetNoncurrentStorageClass() {
+		sizes[k] += v
+	}
+	for k, v := range bucket.GetMultipartStorageClass() {
+		sizes[k] += v
+	}
+	return sizes
+}
+
+// Size billed for a bucket by storage class, its size when it wasn't scanned.
+func bucketBillableStorageClassSize(bucket util.CloudFilesystem) util.StorageClassSizeMap {
+	if billable := bucket.GetBillableStorageClass(); billable != nil {
+		return billable
+	}
+	return bucketStorageClassSize(bucket)
+}
+
+// Price per GB of the storage classes of each region once the sizes of some buckets are replaced by other sizes,
+// the buckets whose sizes are nil keep theirs.
+func (fs *S3) projectedTierListPrices(buckets []util.CloudFilesystem, priceList MasterPriceList, replace func(bucket util.CloudFilesystem) (current util.StorageClassSizeMap, projected util.StorageClassSizeMap)) (map[string]map[string]float64, []util.ScanError) {
+	projectedSizes := make(util.RegionsStorageMap)
+	for region, storageClassSize := range fs.totalStorageClassSize.SizeMap {
+		projectedSizes[region] = maps.Clone(storageClassSize)
+	}
+	for _, bucket := range buckets {
+		current, projected := replace(bucket)
+		if current == nil && projected == nil {
+			continue
+		}
+		sizes := projectedSizes[bucket.GetRegion()]
+		if sizes == nil {
+			sizes = make(map[string]float64)
+			projectedSizes[bucket.GetRegion()] = sizes
+		}
+		for k, v := range current {
+			sizes[k] -= v
+		}
+		for k, v := range projected {
+			sizes[k] += v
+		}
+	}
+	projectedPrices := make(map[string]map[string]float64)
+	var priceErrors []util.ScanError
+	for region, sizes := range projectedSizes {
+		projectedPrice, err := GetTierPriceList(sizes, priceList[region])
+		projectedPrices[region] = projectedPrice
+		priceErrors = appendRegionPriceErrors(priceErrors, priceList, region, err)
+	}
+	return projectedPrices, priceErrors
+}
@@ -0,0 +1,231 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"projet-devops-coveo/pkg/util"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchBucketLifecycleProjection(t *testing.T) {
+	now := time.Now()
+	daysAgo := func(days int) *time.Time {
+		return aws.Time(now.AddDate(0, 0, -days))
+	}
+	mock := &AwsClientMock{
+		ObjectVersions: map[string][]types.ObjectVersion{
+			"poc-1": {
+				{Key: aws.String("data/b.bin"), VersionId: aws.String("1"), IsLatest: aws.Bool(true), Size: aws.Int64(200), StorageClass: "STANDARD", LastModified: daysAgo(5)},
+				{Key: aws.String("data/c.bin"), VersionId: aws.String("2"), IsLatest: aws.Bool(true), Size: aws.Int64(300), StorageClass: "STANDARD", LastModified: daysAgo(5)},
+				{Key: aws.String("logs/a.log"), VersionId: aws.String("3"), IsLatest: aws.Bool(true), Size: aws.Int64(100), StorageClass: "STANDARD", LastModified: daysAgo(10)},
+				{Key: aws.String("logs/a.log"), VersionId: aws.String("4"), IsLatest: aws.Bool(false), Size: aws.Int64(50), StorageClass: "STANDARD", LastModified: daysAgo(40)},
+			},
+		},
+		ObjectTags: map[string]map[string]string{
+			"poc-1/data/b.bin": {"team": "data"},
+		},
+		Configs: map[string]*BucketConfigMock{
+			"poc-1": {
+				Versioning: &s3.GetBucketVersioningOutput{Status: types.BucketVersioningStatusEnabled},
+				Lifecycle: &s3.GetBucketLifecycleConfigurationOutput{Rules: []types.LifecycleRule{
+					{
+						ID:                          aws.String("logs"),
+						Status:                      types.ExpirationStatusEnabled,
+						Filter:                      &types.LifecycleRuleFilterMemberPrefix{Value: "logs/"},
+						Transitions:                 []types.Transition{{Days: aws.Int32(30), StorageClass: types.TransitionStorageClassGlacier}},
+						Expiration:                  &types.LifecycleExpiration{Days: aws.Int32(120)},
+						NoncurrentVersionExpiration: &types.NoncurrentVersionExpiration{NoncurrentDays: aws.Int32(30)},
+					},
+					{
+						ID:          aws.String("data"),
+						Status:      types.ExpirationStatusEnabled,
+						Filter:      &types.LifecycleRuleFilterMemberTag{Value: types.Tag{Key: aws.String("team"), Value: aws.String("data")}},
+						Transitions: []types.Transition{{Days: aws.Int32(30), StorageClass: types.TransitionStorageClassStandardIa}},
+					},
+				}},
+			},
+		},
+	}
+	fs := &S3{
+		session: mock,
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": map[string]float64{}},
+		},
+		options: util.CliOptions{IncludeVersions: true, Threading: 2, OutputOptions: &util.OutputOptions{LifecycleProjection: true}},
+		region:  "ca-central-1",
+	}
+	bucket := &util.BucketDTO{Name: "poc-1", Region: "ca-central-1"}
+	fs.GetObject(context.Background(), []util.CloudFilesystem{bucket}, nil)
+
+	// The log is in GLACIER after 3 months, then it expires and its noncurrent version is deleted 30 days later.
+	// Its previous version is noncurrent since the log was written. The billable sizes have the minimum billable
+	// size of the data in STANDARD_IA and the metadata of the log in GLACIER.
+	kb := float64(1 << 10)
+	assert.Equal(t, []util.CostProjection{
+		{
+			Months:                   3,
+			Size:                     600,
+			StorageClassSize:         util.StorageClassSizeMap{"STANDARD": 300, "STANDARD_IA": 200, "GLACIER": 100},
+			BillableStorageClassSize: util.StorageClassSizeMap{"STANDARD": 300 + 8*kb, "STANDARD_IA": 128 * kb, "GLACIER": 100 + 32*kb},
+		},
+		{
+			Months:                   6,
+			Size:                     500,
+			StorageClassSize:         util.StorageClassSizeMap{"STANDARD": 300, "STANDARD_IA": 200},
+			BillableStorageClassSize: util.StorageClassSizeMap{"STANDARD": 300, "STANDARD_IA": 128 * kb},
+		},
+		{
+			Months:                   12,
+			Size:                     500,
+			StorageClassSize:         util.StorageClassSizeMap{"STANDARD": 300, "STANDARD_IA": 200},
+			BillableStorageClassSize: util.StorageClassSizeMap{"STANDARD": 300, "STANDARD_IA": 128 * kb},
+		},
+	}, bucket.LifecycleProjection)
+}
+
+func TestFetchObjectVersionsAcrossPages(t *testing.T) {
+	now := time.Now()
+	daysAgo := func(days int) *time.Time {
+		return aws.Time(now.AddDate(0, 0, -days))
+	}
+	// Each version and delete marker is on its own page
+	mock := &AwsClientMock{
+		ObjectVersions: map[string][]types.ObjectVersion{
+			"poc-1": {
+				{Key: aws.String("a.log"), VersionId: aws.String("2"), IsLatest: aws.Bool(false), Size: aws.Int64(20), StorageClass: "STANDARD", LastModified: daysAgo(40)},
+				{Key: aws.String("a.log"), VersionId: aws.String("1"), IsLatest: aws.Bool(false), Size: aws.Int64(10), StorageClass: "STANDARD", LastModified: daysAgo(60)},
+			},
+		},
+		DeleteMarkers: map[string][]types.DeleteMarkerEntry{
+			"poc-1": {{Key: aws.String("a.log"), IsLatest: aws.Bool(true), LastModified: daysAgo(20)}},
+		},
+		PageSize: 1,
+	}
+	checkpoint, err := LoadCheckpoint(filepath.Join(t.TempDir(), "state.json"), util.CliOptions{})
+	assert.Nil(t, err)
+	checkpoint.saveInterval = 0
+	fs := &S3{
+		session:    mock,
+		options:    util.CliOptions{IncludeVersions: true, OutputOptions: &util.OutputOptions{LifecycleProjection: true}},
+		checkpoint: checkpoint,
+	}
+	fs.lifecycles.Store("poc-1", &bucketLifecycle{
+		Rules:     []util.LifecycleRule{{Status: LIFECYCLE_STATUS_ENABLED, NoncurrentExpirationDays: 30, NewerNoncurrentVersions: 1}},
+		Versioned: true,
+	})
+
+	// The newest version is noncurrent since the delete marker of the previous page and kept by the rule,
+	// the oldest one since the newest version.
+	expected := make(lifecycleAges)
+	expected.add("0k", "STANDARD", toDay(*daysAgo(20)), 1, 20, getPadding(20))
+	expected.add("0", "STANDARD", toDay(*daysAgo(40)), 1, 10, getPadding(10))
+	stats, err := fs.fetchObjectVersions(context.Background(), "poc-1")
+	assert.Nil(t, err)
+	assert.Equal(t, expected, stats.lifecycleNoncurrent)
+	assert.Equal(t, int64(1), stats.nbOfDeleteMarkers)
+
+	// The scan resumed after the newest version continues the versions of the key
+	progress := checkpoint.partition("poc-1", "")
+	progress.KeyMarker, progress.PreviousModified, progress.Rank, progress.Done = "2", aws.ToTime(daysAgo(40)), 1, false
+	progress.Stats = newBucketStats()
+	checkpoint.updatePartition("poc-1", "", progress)
+	assert.Nil(t, checkpoint.Save())
+	fs.checkpoint, err = LoadCheckpoint(checkpoint.path, util.CliOptions{})
+	assert.Nil(t, err)
+	resumed := make(lifecycleAges)
+	resumed.add("0", "STANDARD", toDay(*daysAgo(40)), 1, 10, getPadding(10))
+	stats, err = fs.fetchObjectVersions(context.Background(), "poc-1")
+	assert.Nil(t, err)
+	assert.Equal(t, resumed, stats.lifecycleNoncurrent)
+}
+
+func TestProjectCurrentObject(t *testing.T) {
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	archive := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	rules := []util.LifecycleRule{
+		{Transitions: []util.LifecycleTransition{{Days: 30, StorageClass: "STANDARD_IA"}, {Date: &archive, StorageClass: "DEEP_ARCHIVE"}}},
+		{Transitions: []util.LifecycleTransition{{Days: 60, StorageClass: "GLACIER"}}},
+	}
+	projected, _, expired := projectCurrentObject(rules, "STANDARD", 10, date)
+	assert.False(t, expired)
+	// The transition at a date applies to all the objects
+	assert.Equal(t, "DEEP_ARCHIVE", projected)
+	projected, _, _ = projectCurrentObject(rules[1:], "STANDARD", 90, date)
+	assert.Equal(t, "GLACIER", projected)
+	// The objects don't move up the waterfall
+	projected, _, _ = projectCurrentObject(rules[:1:1], "GLACIER", 40, archive.AddDate(0, 0, -1))
+	assert.Equal(t, "GLACIER", projected)
+
+	rules = append(rules, util.LifecycleRule{ExpirationDays: 100})
+	_, expiredAge, expired := projectCurrentObject(rules, "STANDARD", 130, date)
+	assert.True(t, expired)
+	assert.Equal(t, int64(30), expiredAge)
+}
+
+func TestProjectNoncurrentVersion(t *testing.T) {
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	rules := []util.LifecycleRule{
+		{NoncurrentTransitions: []util.LifecycleTransition{{Days: 30, StorageClass: "GLACIER_IR"}}, NoncurrentExpirationDays: 90, NewerNoncurrentVersions: 2},
+	}
+	assert.Equal(t, "STANDARD", projectNoncurrentVersion(rules, []bool{false}, "STANDARD", 10, date))
+	assert.Equal(t, "GLACIER_IR", projectNoncurrentVersion(rules, []bool{false}, "STANDARD", 60, date))
+	assert.Equal(t, "", projectNoncurrentVersion(rules, []bool{false}, "STANDARD", 90, date))
+	// One of the newer noncurrent versions kept by the rule
+	assert.Equal(t, "GLACIER_IR", projectNoncurrentVersion(rules, []bool{true}, "STANDARD", 90, date))
+}
+
+func TestNoncurrentSince(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, newer, noncurrentSince(created, newer))
+	assert.Equal(t, created, noncurrentSince(created, time.Time{}))
+}
+
+func TestSetProjectionCost(t *testing.T) {
+	gb := float64(1024 * 1024 * 1024)
+	fs := &S3{
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": {"STANDARD": 10 * gb}},
+		},
+	}
+	priceList := MasterPriceList{
+		"ca-central-1": flatPriceList(map[string]string{"Standard": "0.02", "Amazon Glacier": "0.004"}),
+	}
+	bucket := &util.BucketDTO{
+		Name:             "poc-1",
+		Region:           "ca-central-1",
+		Cost:             0.3,
+		CostBreakdown:    util.CostBreakdown{Storage: 0.2, Requests: 0.1},
+		StorageClassSize: util.StorageClassSizeMap{"STANDARD": 10 * gb},
+		LifecycleProjection: []util.CostProjection{
+			{Months: 3, BillableStorageClassSize: util.StorageClassSizeMap{"STANDARD": 5 * gb, "GLACIER": 5 * gb}},
+			{Months: 6, BillableStorageClassSize: util.StorageClassSizeMap{"GLACIER": 10 * gb}},
+			{Months: 12, BillableStorageClassSize: util.StorageClassSizeMap{}},
+		},
+	}
+	fs.SetProjectionCost([]util.CloudFilesystem{bucket}, priceList)
+	assert.InDelta(t, 0.12, bucket.LifecycleProjection[0].Cost, 1e-6)
+	assert.InDelta(t, 0.08, bucket.LifecycleProjection[0].Savings, 1e-6)
+	assert.InDelta(t, 0.04, bucket.LifecycleProjection[1].Cost, 1e-6)
+	assert.InDelta(t, 0.0, bucket.LifecycleProjection[2].Cost, 1e-9)
+	assert.InDelta(t, 0.2, bucket.LifecycleProjection[2].Savings, 1e-9)
+}
+
+func TestLifecycleAgesCheckpoint(t *testing.T) {
+	stats := newBucketStats()
+	stats.lifecycleCurrent.add("0,1", "STANDARD", 19800, 2, 300, 2*MINIMUM_BILLABLE_SIZE-300)
+	stats.lifecycleNoncurrent.add("0k", "GLACIER", 19700, 1, 50, MINIMUM_BILLABLE_SIZE-50)
+	data, err := json.Marshal(stats)
+	assert.Nil(t, err)
+	loaded := newBucketStats()
+	assert.Nil(t, json.Unmarshal(data, loaded))
+	assert.Equal(t, stats.lifecycleCurrent, loaded.lifecycleCurrent)
+	assert.Equal(t, stats.lifecycleNoncurrent, loaded.lifecycleNoncurrent)
+}
@@ -0,0 +1,220 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"projet-devops-coveo/pkg/util"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Lifecycle configuration in the format of the AWS CLI and of the API (aws s3api get-bucket-lifecycle-configuration).
+// The fields that aren't simulated, like TransitionDefaultMinimumObjectSize, are ignored.
+type awsLifecycleConfiguration struct {
+	Rules []struct {
+		ID     string
+		Status string
+		// Deprecated, replaced by the filter
+		Prefix string
+		Filter *struct {
+			Prefix                string
+			Tag                   *awsLifecycleTag
+			ObjectSizeGreaterThan int64
+			ObjectSizeLessThan    int64
+			And                   *struct {
+				Prefix                string
+				Tags                  []awsLifecycleTag
+				ObjectSizeGreaterThan int64
+				ObjectSizeLessThan    int64
+			}
+		}
+		Transitions []struct {
+			Days         int32
+			Date         *time.Time
+			StorageClass string
+		}
+		Expiration *struct {
+			Days                      int32
+			Date                      *time.Time
+			ExpiredObjectDeleteMarker bool
+		}
+		NoncurrentVersionTransitions []struct {
+			NoncurrentDays int32
+			StorageClass   string
+		}
+		NoncurrentVersionExpiration *struct {
+			NoncurrentDays          int32
+			NewerNoncurrentVersions int32
+		}
+		AbortIncompleteMultipartUpload *struct {
+			DaysAfterInitiation int32
+		}
+	}
+}
+
+type awsLifecycleTag struct {
+	Key   string
+	Value string
+}
+
+// Simple YAML format of the rules, they apply to the current objects of the whole bucket.
+//
+//	rules:
+//	  - id: archive
+//	    transitions:
+//	      - days: 30
+//	        storageClass: STANDARD_IA
+//	    expirationDays: 365
+type simpleLifecycleConfiguration struct {
+	Rules []struct {
+		ID          string `yaml:"id"`
+		Transitions []struct {
+			Days         int32  `yaml:"days"`
+			StorageClass string `yaml:"storageClass"`
+		} `yaml:"transitions"`
+		ExpirationDays int32 `yaml:"expirationDays"`
+	} `yaml:"rules"`
+}
+
+// Load a lifecycle rule set, either in the AWS JSON format or in the simple YAML format.
+func LoadLifecycleRules(path string) ([]util.LifecycleRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []util.LifecycleRule
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		rules, err = parseSimpleLifecycleRules(data)
+	default:
+		rules, err = parseAwsLifecycleRules(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid lifecycle rules %s: %w", path, err)
+	}
+	if err := validateSimulationRules(rules); err != nil {
+		return nil, fmt.Errorf("invalid lifecycle rules %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+func parseAwsLifecycleRules(data []byte) ([]util.LifecycleRule, error) {
+	var config awsLifecycleConfiguration
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	var rules []util.LifecycleRule
+	for _, awsRule := range config.Rules {
+		rule := util.LifecycleRule{ID: awsRule.ID, Status: awsRule.Status, Prefix: awsRule.Prefix}
+		if filter := awsRule.Filter; filter != nil {
+			rule.Prefix = filter.Prefix
+			rule.ObjectSizeGreaterThan = filter.ObjectSizeGreaterThan
+			rule.ObjectSizeLessThan = filter.ObjectSizeLessThan
+			if filter.Tag != nil {
+				rule.Tags = map[string]string{filter.Tag.Key: filter.Tag.Value}
+			}
+			if and := filter.And; and != nil {
+				rule.Prefix = and.Prefix
+				rule.ObjectSizeGreaterThan = and.ObjectSizeGreaterThan
+				rule.ObjectSizeLessThan = and.ObjectSizeLessThan
+				for _, tag := range and.Tags {
+					if rule.Tags == nil {
+						rule.Tags = make(map[string]string)
+					}
+					rule.Tags[tag.Key] = tag.Value
+				}
+			}
+		}
+		for _, transition := range awsRule.Transitions {
+			rule.Transitions = append(rule.Transitions, util.LifecycleTransition{Days: transition.Days, Date: transition.Date, StorageClass: transition.StorageClass})
+		}
+		if awsRule.Expiration != nil {
+			rule.ExpirationDays = awsRule.Expiration.Days
+			rule.ExpirationDate = awsRule.Expiration.Date
+			rule.ExpiredObjectDeleteMarker = awsRule.Expiration.ExpiredObjectDeleteMarker
+		}
+		for _, transition := range awsRule.NoncurrentVersionTransitions {
+			rule.NoncurrentTransitions = append(rule.NoncurrentTransitions, util.LifecycleTransition{Days: transition.NoncurrentDays, StorageClass: transition.StorageClass})
+		}
+		if awsRule.NoncurrentVersionExpiration != nil {
+			rule.NoncurrentExpirationDays = awsRule.NoncurrentVersionExpiration.NoncurrentDays
+			rule.NewerNoncurrentVersions = awsRule.NoncurrentVersionExpiration.NewerNoncurrentVersions
+		}
+		if awsRule.AbortIncompleteMultipartUpload != nil {
+			rule.AbortIncompleteMultipartUploadDays = awsRule.AbortIncompleteMultipartUpload.DaysAfterInitiation
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseSimpleLifecycleRules(data []byte) ([]util.LifecycleRule, error) {
+	var config simpleLifecycleConfiguration
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return nil, err
+	}
+	var rules []util.LifecycleRule
+	for _, simpleRule := range config.Rules {
+		rule := util.LifecycleRule{ID: simpleRule.ID, Status: LIFECYCLE_STATUS_ENABLED, ExpirationDays: simpleRule.ExpirationDays}
+		for _, transition := range simpleRule.Transitions {
+			rule.Transitions = append(rule.Transitions, util.LifecycleTransition{Days: transition.Days, StorageClass: transition.StorageClass})
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// The simulation works on the age of the current objects of the whole bucket, so the rules can't filter the objects
+// and the transitions must be in days. The noncurrent versions and the multipart uploads are not simulated.
+func validateSimulationRules(rules []util.LifecycleRule) error {
+	if len(rules) == 0 {
+		return fmt.Errorf("no rules")
+	}
+	for _, rule := range rules {
+		name := rule.ID
+		if name == "" {
+			name = "without ID"
+		}
+		if rule.Status != LIFECYCLE_STATUS_ENABLED && rule.Status != LIFECYCLE_STATUS_DISABLED {
+			return fmt.Errorf("rule %s: the status must be %s or %s", name, LIFECYCLE_STATUS_ENABLED, LIFECYCLE_STATUS_DISABLED)
+		}
+		if rule.Prefix != "" || len(rule.Tags) != 0 || rule.ObjectSizeGreaterThan != 0 || rule.ObjectSizeLessThan != 0 {
+			return fmt.Errorf("rule %s: only the rules of the whole bucket can be simulated, without prefix, tags or size filter", name)
+		}
+		if rule.ExpirationDate != nil {
+			return fmt.Errorf("rule %s: only the expirations in days can be simulated", name)
+		}
+		previous := S3_STORAGE_CLASS_STANDARD
+		var previousDays int32
+		for _, transition := range rule.Transitions {
+			if transition.Date != nil {
+				return fmt.Errorf("rule %s: only the transitions in days can be simulated", name)
+			}
+			if rank, ok := lifecycleWaterfall[transition.StorageClass]; !ok || rank == 0 {
+				return fmt.Errorf("rule %s: unsupported transition to %s", name, transition.StorageClass)
+			}
+			if minimum := minimumTransitionDays[transition.StorageClass]; transition.Days < minimum {
+				return fmt.Errorf("rule %s: the transition to %s must be after at least %d days", name, transition.StorageClass, minimum)
+			}
+			if lifecycleWaterfall[transition.StorageClass] <= lifecycleWaterfall[previous] || transition.Days <= previousDays && previousDays != 0 {
+				return fmt.Errorf("rule %s: the transition to %s must be after the transition to %s", name, transition.StorageClass, previous)
+			}
+			if rule.ExpirationDays != 0 && transition.Days >= rule.ExpirationDays {
+				return fmt.Errorf("rule %s: the transition to %s must be before the expiration", name, transition.StorageClass)
+			}
+			previous, previousDays = transition.StorageClass, transition.Days
+		}
+	}
+	if !slices.ContainsFunc(rules, func(rule util.LifecycleRule) bool { return rule.Status == LIFECYCLE_STATUS_ENABLED }) {
+		return fmt.Errorf("no enabled rules")
+	}
+	return nil
+}
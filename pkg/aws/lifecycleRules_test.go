@@ -0,0 +1,174 @@
+package aws
+
+import (
+	"os"
+	"path/filepath"
+	"projet-devops-coveo/pkg/util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadLifecycleRules(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name           string
+		file           string
+		content        string
+		expectedOutput []util.LifecycleRule
+	}{
+		{
+			name: "AWS JSON",
+			file: "rules.json",
+			content: `{
+				"Rules": [
+					{
+						"ID": "archive",
+						"Status": "Enabled",
+						"Filter": {},
+						"Transitions": [
+							{"Days": 30, "StorageClass": "STANDARD_IA"},
+							{"Days": 90, "StorageClass": "GLACIER"}
+						],
+						"Expiration": {"Days": 365}
+					},
+					{
+						"ID": "old",
+						"Status": "Disabled",
+						"Filter": {"Prefix": ""},
+						"Expiration": {"Days": 30}
+					}
+				]
+			}`,
+			expectedOutput: []util.LifecycleRule{
+				{
+					ID:     "archive",
+					Status: "Enabled",
+					Transitions: []util.LifecycleTransition{
+						{Days: 30, StorageClass: "STANDARD_IA"},
+						{Days: 90, StorageClass: "GLACIER"},
+					},
+					ExpirationDays: 365,
+				},
+				{ID: "old", Status: "Disabled", ExpirationDays: 30},
+			},
+		},
+		{
+			name: "Simple YAML",
+			file: "rules.yaml",
+			content: `
+rules:
+  - id: archive
+    transitions:
+      - days: 0
+        storageClass: INTELLIGENT_TIERING
+      - days: 180
+        storageClass: DEEP_ARCHIVE
+  - id: expire
+    expirationDays: 730
+`,
+			expectedOutput: []util.LifecycleRule{
+				{
+					ID:     "archive",
+					Status: "Enabled",
+					Transitions: []util.LifecycleTransition{
+						{Days: 0, StorageClass: "INTELLIGENT_TIERING"},
+						{Days: 180, StorageClass: "DEEP_ARCHIVE"},
+					},
+				},
+				{ID: "expire", Status: "Enabled", ExpirationDays: 730},
+			},
+		},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.file)
+		assert.Nil(t, os.WriteFile(path, []byte(test.content), 0o644), test.name)
+		rules, err := LoadLifecycleRules(path)
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, test.expectedOutput, rules, test.name)
+	}
+}
+
+// Output of aws s3api get-bucket-lifecycle-configuration
+func TestLoadLifecycleRulesCliExport(t *testing.T) {
+	rules, err := LoadLifecycleRules("testdata/lifecycle/get-bucket-lifecycle-configuration.json")
+	assert.Nil(t, err)
+	assert.Equal(t, []util.LifecycleRule{
+		{
+			ID:     "archive",
+			Status: "Enabled",
+			Transitions: []util.LifecycleTransition{
+				{Days: 30, StorageClass: "STANDARD_IA"},
+				{Days: 90, StorageClass: "GLACIER"},
+			},
+			ExpirationDays:                     365,
+			NoncurrentTransitions:              []util.LifecycleTransition{{Days: 30, StorageClass: "STANDARD_IA"}},
+			NoncurrentExpirationDays:           90,
+			NewerNoncurrentVersions:            2,
+			AbortIncompleteMultipartUploadDays: 7,
+		},
+	}, rules)
+}
+
+func TestLoadLifecycleRulesUnknownField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yml")
+	assert.Nil(t, os.WriteFile(path, []byte("rules:\n  - id: a\n    expiration: 30\n"), 0o644))
+	_, err := LoadLifecycleRules(path)
+	assert.NotNil(t, err)
+}
+
+func TestValidateSimulationRules(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		rules []util.LifecycleRule
+		valid bool
+	}{
+		{
+			name:  "Transitions and expiration",
+			rules: []util.LifecycleRule{{ID: "a", Status: "Enabled", Transitions: []util.LifecycleTransition{{Days: 30, StorageClass: "STANDARD_IA"}, {Days: 60, StorageClass: "GLACIER"}}, ExpirationDays: 365}},
+			valid: true,
+		},
+		{
+			name:  "No rules",
+			rules: nil,
+		},
+		{
+			name:  "Only disabled rules",
+			rules: []util.LifecycleRule{{ID: "a", Status: "Disabled", ExpirationDays: 30}},
+		},
+		{
+			name:  "Filtered by prefix",
+			rules: []util.LifecycleRule{{ID: "a", Status: "Enabled", Prefix: "logs/", ExpirationDays: 30}},
+		},
+		{
+			name:  "Filtered by tag",
+			rules: []util.LifecycleRule{{ID: "a", Status: "Enabled", Tags: map[string]string{"team": "data"}, ExpirationDays: 30}},
+		},
+		{
+			name:  "Transition at a date",
+			rules: []util.LifecycleRule{{ID: "a", Status: "Enabled", Transitions: []util.LifecycleTransition{{Date: &date, StorageClass: "GLACIER"}}}},
+		},
+		{
+			name:  "Transition to STANDARD_IA before 30 days",
+			rules: []util.LifecycleRule{{ID: "a", Status: "Enabled", Transitions: []util.LifecycleTransition{{Days: 10, StorageClass: "STANDARD_IA"}}}},
+		},
+		{
+			name:  "Transition to STANDARD",
+			rules: []util.LifecycleRule{{ID: "a", Status: "Enabled", Transitions: []util.LifecycleTransition{{Days: 10, StorageClass: "STANDARD"}}}},
+		},
+		{
+			name:  "Transitions against the waterfall",
+			rules: []util.LifecycleRule{{ID: "a", Status: "Enabled", Transitions: []util.LifecycleTransition{{Days: 30, StorageClass: "GLACIER"}, {Days: 60, StorageClass: "STANDARD_IA"}}}},
+		},
+		{
+			name:  "Transition after the expiration",
+			rules: []util.LifecycleRule{{ID: "a", Status: "Enabled", Transitions: []util.LifecycleTransition{{Days: 60, StorageClass: "GLACIER"}}, ExpirationDays: 30}},
+		},
+	}
+	for _, test := range tests {
+		err := validateSimulationRules(test.rules)
+		assert.Equal(t, test.valid, err == nil, test.name)
+	}
+}
@@ -0,0 +1,195 @@
+package aws
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"time"
+
+	"projet-devops-coveo/pkg/util"
+)
+
+// Rank of the storage classes in the lifecycle waterfall, an object can only be transitioned to a storage class
+// of a higher rank. The other storage classes can't be transitioned.
+var lifecycleWaterfall = map[string]int{
+	S3_STORAGE_CLASS_STANDARD:            0,
+	S3_STORAGE_CLASS_REDUCED_REDUNDANCY:  0,
+	S3_STORAGE_CLASS_STANDARD_IA:         1,
+	S3_STORAGE_CLASS_INTELLIGENT_TIERING: 2,
+	S3_STORAGE_CLASS_ONEZONE_IA:          3,
+	S3_STORAGE_CLASS_GLACIER_IR:          4,
+	S3_STORAGE_CLASS_GLACIER:             5,
+	S3_STORAGE_CLASS_DEEP_ARCHIVE:        6,
+}
+
+// Age of the objects before they can be transitioned to the storage class.
+var minimumTransitionDays = map[string]int32{
+	S3_STORAGE_CLASS_STANDARD_IA: 30,
+	S3_STORAGE_CLASS_ONEZONE_IA:  30,
+}
+
+// Minimum storage duration of the storage classes, an object deleted or transitioned before is billed for the days left.
+var minimumStorageDays = map[string]int64{
+	S3_STORAGE_CLASS_STANDARD_IA:  30,
+	S3_STORAGE_CLASS_ONEZONE_IA:   30,
+	S3_STORAGE_CLASS_GLACIER_IR:   90,
+	S3_STORAGE_CLASS_GLACIER:      90,
+	S3_STORAGE_CLASS_DEEP_ARCHIVE: 180,
+}
+
+// The minimum storage durations are billed by month of 30 days.
+const DAYS_PER_MONTH = 30
+
+// Storage class of the objects from an age, until the next stage or the expiration. The end is 0 for the last stage.
+type lifecycleStage struct {
+	StorageClass string
+	Start        int64
+	End          int64
+}
+
+// Stages of the objects of a bucket once all the enabled rules are combined. When several rules apply to an object,
+// the expiration wins over the transitions and the transition to the cheapest storage class wins over the others.
+type lifecyclePlan struct {
+	stages []lifecycleStage
+	// 0 without expiration
+	expiration int64
+}
+
+func newLifecyclePlan(rules []util.LifecycleRule) lifecyclePlan {
+	var plan lifecyclePlan
+	var transitions []util.LifecycleTransition
+	for _, rule := range rules {
+		if rule.Status != LIFECYCLE_STATUS_ENABLED {
+			continue
+		}
+		transitions = append(transitions, rule.Transitions...)
+		if days := int64(rule.ExpirationDays); days > 0 && (plan.expiration == 0 || days < plan.expiration) {
+			plan.expiration = days
+		}
+	}
+	slices.SortFunc(transitions, func(a, b util.LifecycleTransition) int {
+		return cmp.Or(cmp.Compare(a.Days, b.Days), cmp.Compare(lifecycleWaterfall[a.StorageClass], lifecycleWaterfall[b.StorageClass]))
+	})
+	for _, transition := range transitions {
+		start := int64(transition.Days)
+		if plan.expiration != 0 && start >= plan.expiration {
+			break
+		}
+		last := len(plan.stages) - 1
+		if last >= 0 && lifecycleWaterfall[transition.StorageClass] <= lifecycleWaterfall[plan.stages[last].StorageClass] {
+			continue
+		}
+		// A transition on the same day as the previous one replaces it
+		if last >= 0 && plan.stages[last].Start == start {
+			plan.stages = plan.stages[:last]
+		} else if last >= 0 {
+			plan.stages[last].End = start
+		}
+		plan.stages = append(plan.stages, lifecycleStage{StorageClass: transition.StorageClass, Start: start})
+	}
+	if last := len(plan.stages) - 1; last >= 0 {
+		plan.stages[last].End = plan.expiration
+	}
+	return plan
+}
+
+// Stage of the objects of an age, nil before the first transition.
+func (plan lifecyclePlan) stage(age int64) *lifecycleStage {
+	for i := len(plan.stages) - 1; i >= 0; i-- {
+		if plan.stages[i].Start <= age {
+			return &plan.stages[i]
+		}
+	}
+	return nil
+}
+
+// Project the rules on the current objects, from their age at the end of the scan. The overheads of the billable
+// sizes follow the objects in the storage classes they transition to. The costs are set once the projected totals
+// of the regions are known.
+func (stats *bucketStats) simulate(rules []util.LifecycleRule, now time.Time) *util.Simulation {
+	plan := newLifecyclePlan(rules)
+	simulation := &util.Simulation{
+		CurrentStorageClassSize:           maps.Clone(stats.storageClassSize),
+		ProjectedStorageClassSize:         make(util.StorageClassSizeMap),
+		CurrentBillableStorageClassSize:   make(util.StorageClassSizeMap),
+		ProjectedBillableStorageClassSize: make(util.StorageClassSizeMap),
+		NbOfTransitions:                   make(map[string]int64),
+		PenaltySize:                       make(util.StorageClassSizeMap),
+		MonthlyPenaltySize:                make(util.StorageClassSizeMap),
+	}
+	for storageClass, days := range stats.modifiedDays {
+		rank, transitionable := lifecycleWaterfall[storageClass]
+		for day, modified := range days {
+			age := toDay(now) - day
+			size := float64(modified.Size)
+			addBillableSize(simulation.CurrentBillableStorageClassSize, storageClass, modified, 1)
+			if plan.expiration != 0 && age >= plan.expiration {
+				simulation.NbOfExpirations += modified.NbOfFiles
+				simulation.ExpiredSize += size
+				addPenaltySize(simulation.PenaltySize, storageClass, billableSizeInClass(storageClass, modified), age)
+				continue
+			}
+			stage := plan.stage(age)
+			if stage == nil || !transitionable || lifecycleWaterfall[stage.StorageClass] < rank {
+				simulation.ProjectedStorageClassSize[storageClass] += size
+				addBillableSize(simulation.ProjectedBillableStorageClassSize, storageClass, modified, 1)
+				continue
+			}
+			if stage.StorageClass != storageClass {
+				simulation.NbOfTransitions[stage.StorageClass] += modified.NbOfFiles
+				addPenaltySize(simulation.PenaltySize, storageClass, billableSizeInClass(storageClass, modified), age)
+			}
+			simulation.ProjectedStorageClassSize[stage.StorageClass] += size
+			addBillableSize(simulation.ProjectedBillableStorageClassSize, stage.StorageClass, modified, 1)
+			// The objects leaving a stage shorter than its minimum storage duration next month are charged every month
+			if stage.End != 0 && age >= stage.End-DAYS_PER_MONTH {
+				addPenaltySize(simulation.MonthlyPenaltySize, stage.StorageClass, billableSizeInClass(stage.StorageClass, modified), stage.End-stage.Start)
+			}
+		}
+	}
+	return simulation
+}
+
+// Size of an object leaving its storage class at an age, multiplied by the months left of its minimum storage duration.
+func addPenaltySize(penaltySize util.StorageClassSizeMap, storageClass string, size float64, age int64) {
+	if daysLeft := minimumStorageDays[storageClass] - age; daysLeft > 0 {
+		penaltySize[storageClass] += size * float64(daysLeft) / DAYS_PER_MONTH
+	}
+}
+
+// Set the costs of the simulations of the buckets. The current prices are the tiers of the totals of the regions,
+// the projected prices the tiers of the totals once the rules are applied to the simulated buckets. The storage classes
+// that can't be priced are returned as errors.
+func (fs *S3) SetSimulationCost(buckets []util.CloudFilesystem, priceList MasterPriceList) []util.ScanError {
+	currentPrices, priceErrors := fs.GetTierListPrices(priceList)
+	projectedPrices, projectedErrors := fs.projectedTierListPrices(buckets, priceList, func(bucket util.CloudFilesystem) (util.StorageClassSizeMap, util.StorageClassSizeMap) {
+		if simulation := bucket.GetSimulation(); simulation != nil {
+			return simulation.CurrentBillableStorageClassSize, simulation.ProjectedBillableStorageClassSize
+		}
+		return nil, nil
+	})
+	for _, bucket := range buckets {
+		if simulation := bucket.GetSimulation(); simulation != nil {
+			setSimulationCost(simulation, currentPrices[bucket.GetRegion()], projectedPrices[bucket.GetRegion()], priceList[bucket.GetRegion()])
+		}
+	}
+	return util.AppendScanErrors(priceErrors, projectedErrors...)
+}
+
+func setSimulationCost(simulation *util.Simulation, currentPrice map[string]float64, projectedPrice map[string]float64, regionPriceList ProductPriceList) {
+	simulation.CurrentCost = storageCost(simulation.CurrentBillableStorageClassSize, currentPrice)
+	simulation.ProjectedCost = storageCost(simulation.ProjectedBillableStorageClassSize, projectedPrice)
+	simulation.MinimumDurationPenalties = storageCost(simulation.PenaltySize, currentPrice)
+	simulation.MonthlyMinimumDurationPenalties = storageCost(simulation.MonthlyPenaltySize, projectedPrice)
+	simulation.TransitionFees = getTransitionFees(simulation.NbOfTransitions, regionPriceList)
+	simulation.ComputeSavings()
+}
+
+// Monthly cost of sizes by storage class with prices per GB.
+func storageCost(storageClassSize util.StorageClassSizeMap, price map[string]float64) float64 {
+	var cost float64
+	for k, v := range storageClassSize {
+		cost += TransformSizeToGB(v) * price[k]
+	}
+	return cost
+}
@@ -0,0 +1,162 @@
+package aws
+
+import (
+	"context"
+	"projet-devops-coveo/pkg/util"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// Price list with a single tier per storage class.
+func flatPriceList(prices map[string]string) ProductPriceList {
+	priceList := make(ProductPriceList)
+	for volumeType, usd := range prices {
+		dimension := PriceDimension{BeginRange: "0", EndRange: "Inf"}
+		dimension.PricePerUnit.Usd = usd
+		var list PriceList
+		list.Terms.OnDemand = map[string]TermsAttributes{
+			"1234": {PriceDimensions: map[string]PriceDimension{"5678": dimension}},
+		}
+		priceList[volumeType] = list
+	}
+	return priceList
+}
+
+func TestNewLifecyclePlan(t *testing.T) {
+	rules := []util.LifecycleRule{
+		{ID: "ia", Status: "Enabled", Transitions: []util.LifecycleTransition{{Days: 30, StorageClass: "STANDARD_IA"}, {Days: 120, StorageClass: "GLACIER"}}},
+		// The cheapest storage class wins
+		{ID: "archive", Status: "Enabled", Transitions: []util.LifecycleTransition{{Days: 60, StorageClass: "GLACIER_IR"}, {Days: 90, StorageClass: "DEEP_ARCHIVE"}}, ExpirationDays: 400},
+		{ID: "expire", Status: "Enabled", ExpirationDays: 365},
+		{ID: "disabled", Status: "Disabled", ExpirationDays: 10},
+	}
+	plan := newLifecyclePlan(rules)
+	assert.Equal(t, int64(365), plan.expiration)
+	assert.Equal(t, []lifecycleStage{
+		{StorageClass: "STANDARD_IA", Start: 30, End: 60},
+		{StorageClass: "GLACIER_IR", Start: 60, End: 90},
+		{StorageClass: "DEEP_ARCHIVE", Start: 90, End: 365},
+	}, plan.stages)
+	assert.Nil(t, plan.stage(29))
+	assert.Equal(t, "GLACIER_IR", plan.stage(89).StorageClass)
+	assert.Equal(t, "DEEP_ARCHIVE", plan.stage(90).StorageClass)
+}
+
+func TestSimulate(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	stats := newBucketStats()
+	objects := []struct {
+		size         int64
+		storageClass string
+		age          int
+	}{
+		// Not old enough
+		{100, "STANDARD", 10},
+		// Leaves the stage of STANDARD_IA within the month after 15 days of the 30 days minimum
+		{200, "STANDARD", 40},
+		{300, "STANDARD_IA", 50},
+		// Already in the storage class of its stage, leaves it within the month after 55 days of the 90 days minimum
+		{400, "GLACIER", 80},
+		// Beyond the stage
+		{600, "DEEP_ARCHIVE", 50},
+		{500, "GLACIER", 200},
+		// Expired after 150 days of the 180 days minimum
+		{60, "DEEP_ARCHIVE", 150},
+	}
+	for _, object := range objects {
+		stats.addObject(object.size, object.storageClass, now.AddDate(0, 0, -object.age))
+	}
+	rules := []util.LifecycleRule{
+		{ID: "archive", Status: "Enabled", Transitions: []util.LifecycleTransition{{Days: 30, StorageClass: "STANDARD_IA"}, {Days: 45, StorageClass: "GLACIER"}}, ExpirationDays: 100},
+	}
+	simulation := stats.simulate(rules, now)
+	// The objects in STANDARD_IA are billed 128KB, the archived objects have 32KB of metadata in their storage class
+	// and 8KB in STANDARD. The overheads follow the objects that transition.
+	kb := float64(1 << 10)
+	assert.Equal(t, &util.Simulation{
+		CurrentStorageClassSize:           util.StorageClassSizeMap{"STANDARD": 300, "STANDARD_IA": 300, "GLACIER": 900, "DEEP_ARCHIVE": 660},
+		ProjectedStorageClassSize:         util.StorageClassSizeMap{"STANDARD": 100, "STANDARD_IA": 200, "GLACIER": 700, "DEEP_ARCHIVE": 600},
+		CurrentBillableStorageClassSize:   util.StorageClassSizeMap{"STANDARD": 300 + 4*8*kb, "STANDARD_IA": 128 * kb, "GLACIER": 900 + 2*32*kb, "DEEP_ARCHIVE": 660 + 2*32*kb},
+		ProjectedBillableStorageClassSize: util.StorageClassSizeMap{"STANDARD": 100 + 3*8*kb, "STANDARD_IA": 128 * kb, "GLACIER": 700 + 2*32*kb, "DEEP_ARCHIVE": 600 + 32*kb},
+		NbOfTransitions:                   map[string]int64{"STANDARD_IA": 1, "GLACIER": 1},
+		NbOfExpirations:                   2,
+		ExpiredSize:                       560,
+		PenaltySize:                       util.StorageClassSizeMap{"DEEP_ARCHIVE": 60 + 32*kb},
+		MonthlyPenaltySize:                util.StorageClassSizeMap{"STANDARD_IA": 128 * kb / 2, "GLACIER": (400 + 32*kb) * 35 / 30},
+	}, simulation)
+}
+
+func TestSetSimulationCost(t *testing.T) {
+	gb := float64(1024 * 1024 * 1024)
+	fs := &S3{
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": {"STANDARD": 20 * gb}},
+		},
+	}
+	priceList := MasterPriceList{
+		"ca-central-1": flatPriceList(map[string]string{"Standard": "0.02", "Standard - Infrequent Access": "0.01", "Requests-SIA-Tier1": "0.000011"}),
+	}
+	simulated := &util.BucketDTO{
+		Name:   "poc-1",
+		Region: "ca-central-1",
+		Simulation: &util.Simulation{
+			CurrentStorageClassSize:           util.StorageClassSizeMap{"STANDARD": 10 * gb},
+			ProjectedStorageClassSize:         util.StorageClassSizeMap{"STANDARD_IA": 10 * gb},
+			CurrentBillableStorageClassSize:   util.StorageClassSizeMap{"STANDARD": 10 * gb},
+			ProjectedBillableStorageClassSize: util.StorageClassSizeMap{"STANDARD_IA": 10 * gb},
+			NbOfTransitions:                   map[string]int64{"STANDARD_IA": 2000},
+			PenaltySize:                       util.StorageClassSizeMap{"STANDARD": gb},
+			MonthlyPenaltySize:                util.StorageClassSizeMap{"STANDARD_IA": gb},
+		},
+	}
+	other := &util.BucketDTO{Name: "poc-2", Region: "ca-central-1"}
+	fs.SetSimulationCost([]util.CloudFilesystem{simulated, other}, priceList)
+
+	simulation := simulated.Simulation
+	assert.InDelta(t, 0.2, simulation.CurrentCost, 1e-6)
+	assert.InDelta(t, 0.1, simulation.ProjectedCost, 1e-6)
+	// The transitions are priced in the region of the bucket
+	assert.InDelta(t, 0.022, simulation.TransitionFees, 1e-9)
+	assert.InDelta(t, 0.02, simulation.MinimumDurationPenalties, 1e-6)
+	assert.InDelta(t, 0.01, simulation.MonthlyMinimumDurationPenalties, 1e-6)
+	assert.InDelta(t, 0.09, simulation.MonthlySavings, 1e-6)
+	assert.InDelta(t, 0.042/0.09, simulation.PaybackMonths, 1e-6)
+	assert.Nil(t, other.Simulation)
+}
+
+// The simulation and the bucket are priced with the same billable sizes, so a simulation without any transition has no savings.
+func TestSimulationCostMatchesBucketCost(t *testing.T) {
+	mock := &AwsClientMock{
+		Objects: map[string][]types.Object{
+			"poc-1": {
+				{Key: aws.String("a.txt"), Size: aws.Int64(1000), StorageClass: "STANDARD_IA", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("b.txt"), Size: aws.Int64(2000), StorageClass: "GLACIER", LastModified: aws.Time(timeMock)},
+			},
+		},
+	}
+	fs := &S3{
+		session: mock,
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": map[string]float64{}},
+		},
+		options: util.CliOptions{Threading: 2, SimulationRules: []util.LifecycleRule{
+			{ID: "later", Status: "Enabled", Transitions: []util.LifecycleTransition{{Days: 36500, StorageClass: "DEEP_ARCHIVE"}}},
+		}},
+		region: "ca-central-1",
+	}
+	bucket := &util.BucketDTO{Name: "poc-1", Region: "ca-central-1"}
+	buckets := fs.GetObject(context.Background(), []util.CloudFilesystem{bucket}, nil)
+	priceList := MasterPriceList{
+		"ca-central-1": flatPriceList(map[string]string{"Standard": "0.025", "Standard - Infrequent Access": "0.0138", "Amazon Glacier": "0.0045"}),
+	}
+	fs.SetBucketCost(buckets, priceList)
+	fs.SetSimulationCost(buckets, priceList)
+
+	assert.InDelta(t, bucket.CostBreakdown.Storage, bucket.Simulation.CurrentCost, 1e-15)
+	assert.InDelta(t, bucket.CostBreakdown.Storage, bucket.Simulation.ProjectedCost, 1e-15)
+	assert.InDelta(t, 0, bucket.Simulation.MonthlySavings, 1e-15)
+}
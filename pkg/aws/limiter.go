@@ -0,0 +1,74 @@
+package aws
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/ratelimit"
+)
+
+const (
+	// Slowest pace of the limiter after the throttles: 4 calls per second
+	LIMITER_MAX_INTERVAL = 250 * time.Millisecond
+	// The interval is doubled at most once per window, a burst of throttled responses counts as one
+	LIMITER_THROTTLE_WINDOW = 100 * time.Millisecond
+)
+
+// Rate limiter shared by all the clients that slows down when AWS pushes back.
+// A throttled response doubles the interval between the calls, each success shortens it until the base rate is back.
+type AdaptiveLimiter struct {
+	limiter      ratelimit.Limiter
+	baseInterval time.Duration
+	mutex        sync.Mutex
+	interval     time.Duration
+	next         time.Time
+	lastThrottle time.Time
+}
+
+func NewAdaptiveLimiter(rate int) *AdaptiveLimiter {
+	return &AdaptiveLimiter{
+		limiter:      ratelimit.New(rate),
+		baseInterval: time.Second / time.Duration(max(rate, 1)),
+	}
+}
+
+// Implements ratelimit.Limiter
+func (l *AdaptiveLimiter) Take() time.Time {
+	now := l.limiter.Take()
+	l.mutex.Lock()
+	if l.interval == 0 {
+		l.mutex.Unlock()
+		return now
+	}
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mutex.Unlock()
+	time.Sleep(wait)
+	return now.Add(wait)
+}
+
+func (l *AdaptiveLimiter) throttled() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(l.lastThrottle) < LIMITER_THROTTLE_WINDOW {
+		return
+	}
+	l.lastThrottle = now
+	l.interval = min(max(l.interval*2, l.baseInterval*2), LIMITER_MAX_INTERVAL)
+}
+
+func (l *AdaptiveLimiter) succeeded() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.interval == 0 {
+		return
+	}
+	l.interval -= l.interval / 20
+	if l.interval <= l.baseInterval {
+		l.interval = 0
+	}
+}
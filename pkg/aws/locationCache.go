@@ -0,0 +1,114 @@
+package aws
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Locations of the buckets saved to a local file between the runs, so the buckets already known skip GetBucketLocation.
+// The region of a bucket never changes, but a bucket can be deleted and created again elsewhere with the same name,
+// so an entry is only used for the same creation date and until it expires.
+// A nil cache is valid and does nothing.
+type LocationCache struct {
+	Locations map[string]*cachedLocation `json:"locations"`
+	path      string
+	ttl       time.Duration
+	changed   bool
+	mutex     sync.Mutex
+}
+
+type cachedLocation struct {
+	Region       string    `json:"region"`
+	CreationDate time.Time `json:"creationDate"`
+	Fetched      time.Time `json:"fetched"`
+}
+
+// Load the cache file. The cache is disabled without path or with a TTL of 0.
+// A missing or unreadable file gives an empty cache, it's written again once the locations are fetched.
+func LoadLocationCache(path string, ttl time.Duration) *LocationCache {
+	if path == "" || ttl <= 0 {
+		return nil
+	}
+	cache := &LocationCache{
+		Locations: make(map[string]*cachedLocation),
+		path:      path,
+		ttl:       ttl,
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache
+	}
+	saved := &LocationCache{}
+	if err == nil {
+		err = json.Unmarshal(data, saved)
+	}
+	if err != nil {
+		logrus.Warn("Ignoring the location cache ", path, ": ", err)
+		return cache
+	}
+	for name, location := range saved.Locations {
+		if location != nil && time.Since(location.Fetched) < ttl {
+			cache.Locations[name] = location
+		}
+	}
+	// The expired entries are removed from the file
+	cache.changed = len(cache.Locations) != len(saved.Locations)
+	return cache
+}
+
+// Region of a bucket, if it's known and not expired.
+func (c *LocationCache) get(bucketName string, creationDate time.Time) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	location, ok := c.Locations[bucketName]
+	if !ok || !location.CreationDate.Equal(creationDate) || time.Since(location.Fetched) >= c.ttl {
+		return "", false
+	}
+	return location.Region, true
+}
+
+func (c *LocationCache) set(bucketName string, creationDate time.Time, region string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Locations[bucketName] = &cachedLocation{Region: region, CreationDate: creationDate, Fetched: time.Now()}
+	c.changed = true
+}
+
+// Write the cache file if new locations were fetched.
+func (c *LocationCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.changed {
+		return nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return err
+	}
+	c.changed = false
+	return nil
+}
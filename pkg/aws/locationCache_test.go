@@ -0,0 +1,73 @@
+package aws
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"projet-devops-coveo/pkg/util"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadLocationCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "locations.json")
+	assert.Nil(t, LoadLocationCache("", time.Hour))
+	assert.Nil(t, LoadLocationCache(path, 0))
+
+	cache := LoadLocationCache(path, time.Hour)
+	cache.set("poc-1", timeMock, "ca-central-1")
+	cache.set("poc-2", timeMock, "us-east-1")
+	cache.Locations["poc-2"].Fetched = time.Now().Add(-2 * time.Hour)
+	assert.Nil(t, cache.Save())
+
+	cache = LoadLocationCache(path, time.Hour)
+	region, ok := cache.get("poc-1", timeMock)
+	assert.True(t, ok)
+	assert.Equal(t, "ca-central-1", region)
+	// Expired
+	_, ok = cache.get("poc-2", timeMock)
+	assert.False(t, ok)
+	// Created again with the same name
+	_, ok = cache.get("poc-1", timeMock.Add(time.Hour))
+	assert.False(t, ok)
+
+	// An invalid file is ignored
+	assert.Nil(t, os.WriteFile(path, []byte("{"), 0600))
+	cache = LoadLocationCache(path, time.Hour)
+	assert.NotNil(t, cache)
+	assert.Empty(t, cache.Locations)
+}
+
+func TestFilterBucketsLocationCache(t *testing.T) {
+	mock := &AwsClientMock{
+		Buckets: []types.Bucket{
+			{Name: aws.String("poc-1"), CreationDate: aws.Time(timeMock)},
+			{Name: aws.String("poc-2"), CreationDate: aws.Time(timeMock)},
+			{Name: aws.String("poc-3"), CreationDate: aws.Time(timeMock)},
+		},
+		BucketLocations: map[string]string{"poc-1": "ca-central-1", "poc-3": "us-east-1"},
+	}
+	fs := &S3{
+		session: mock,
+		options: util.CliOptions{Regions: []string{"ca-central-1", "us-east-1"}, Threading: 2},
+	}
+	path := filepath.Join(t.TempDir(), "locations.json")
+	cache := LoadLocationCache(path, time.Hour)
+	// poc-2 can't be located by the mock, its location is only in the cache
+	cache.set("poc-2", timeMock, "ca-central-1")
+	buckets, err := fs.GetBucketsFiltered(context.Background(), cache)
+	assert.Nil(t, err)
+	assert.Nil(t, cache.Save())
+
+	assert.Equal(t, 3, len(buckets))
+	for i, region := range []string{"ca-central-1", "ca-central-1", "us-east-1"} {
+		assert.Equal(t, region, buckets[i].GetRegion())
+		assert.Equal(t, "", buckets[i].GetStatus())
+	}
+	cache = LoadLocationCache(path, time.Hour)
+	assert.Equal(t, 3, len(cache.Locations))
+}
@@ -0,0 +1,173 @@
+package aws
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"projet-devops-coveo/pkg/util"
+)
+
+const S3_URI_SCHEME = "s3://"
+
+// Filter on the objects considered in the calculation (s3:// patterns, globs and regular expressions)
+type ObjectFilter struct {
+	patterns []s3Pattern
+	globs    []string
+	regexes  []*regexp.Regexp
+}
+
+// Pattern given by the user in the form s3://bucket/prefix*
+type s3Pattern struct {
+	bucket string
+	key    string
+}
+
+// Build the object filter from the options given by the user. Returns an error if a pattern is invalid.
+func NewObjectFilter(options util.CliOptions) (*ObjectFilter, error) {
+	filter := &ObjectFilter{}
+	for _, value := range options.FilterByPrefix {
+		pattern, err := parseS3Pattern(value)
+		if err != nil {
+			return nil, err
+		}
+		filter.patterns = append(filter.patterns, pattern)
+	}
+	for _, glob := range options.FilterByGlob {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", glob, err)
+		}
+		filter.globs = append(filter.globs, glob)
+	}
+	for _, expr := range options.FilterByRegex {
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", expr, err)
+		}
+		filter.regexes = append(filter.regexes, regex)
+	}
+	return filter, nil
+}
+
+// Parse a pattern in the form s3://bucket/key. The bucket and the key can contain glob characters.
+func parseS3Pattern(value string) (s3Pattern, error) {
+	if !strings.HasPrefix(value, S3_URI_SCHEME) {
+		return s3Pattern{}, fmt.Errorf("invalid pattern %q: must start with %s", value, S3_URI_SCHEME)
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(value, S3_URI_SCHEME), "/")
+	if bucket == "" {
+		return s3Pattern{}, fmt.Errorf("invalid pattern %q: missing bucket name", value)
+	}
+	if _, err := path.Match(bucket, ""); err != nil {
+		return s3Pattern{}, fmt.Errorf("invalid pattern %q: %w", value, err)
+	}
+	if _, err := path.Match(key, ""); err != nil {
+		return s3Pattern{}, fmt.Errorf("invalid pattern %q: %w", value, err)
+	}
+	return s3Pattern{bucket: bucket, key: key}, nil
+}
+
+// Returns true if the bucket is targeted by the s3:// patterns, or if there's no pattern.
+func (f *ObjectFilter) MatchBucket(bucketName string) bool {
+	if f == nil || len(f.patterns) == 0 {
+		return true
+	}
+	return len(f.bucketPatterns(bucketName)) != 0
+}
+
+// Returns the prefix that can be sent to S3 to list only the objects that could match the patterns of the bucket.
+func (f *ObjectFilter) ListPrefix(bucketName string) string {
+	if f == nil {
+		return ""
+	}
+	patterns := f.bucketPatterns(bucketName)
+	if len(patterns) == 0 {
+		return ""
+	}
+	prefix := literalPrefix(patterns[0].key)
+	for _, pattern := range patterns[1:] {
+		prefix = commonPrefix(prefix, literalPrefix(pattern.key))
+	}
+	return prefix
+}
+
+// Returns true if the object must be considered in the calculation.
+func (f *ObjectFilter) MatchKey(bucketName string, key string) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.patterns) != 0 && !matchAnyPattern(f.bucketPatterns(bucketName), key) {
+		return false
+	}
+	if len(f.globs) != 0 && !matchAnyGlob(f.globs, key) {
+		return false
+	}
+	if len(f.regexes) != 0 && !matchAnyRegex(f.regexes, key) {
+		return false
+	}
+	return true
+}
+
+func (f *ObjectFilter) bucketPatterns(bucketName string) (patterns []s3Pattern) {
+	for _, pattern := range f.patterns {
+		if ok, _ := path.Match(pattern.bucket, bucketName); ok {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// A key pattern without glob characters is a prefix. Otherwise, it's matched as a glob.
+func matchAnyPattern(patterns []s3Pattern, key string) bool {
+	for _, pattern := range patterns {
+		if !hasGlobMeta(pattern.key) {
+			if strings.HasPrefix(key, pattern.key) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern.key, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyGlob(globs []string, key string) bool {
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyRegex(regexes []*regexp.Regexp, key string) bool {
+	for _, regex := range regexes {
+		if regex.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// Part of the pattern before the first glob character.
+func literalPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+func commonPrefix(a string, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
@@ -0,0 +1,152 @@
+package aws
+
+import (
+	"projet-devops-coveo/pkg/util"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewObjectFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     util.CliOptions
+		expectError bool
+	}{
+		{
+			name: "Valid patterns",
+			options: util.CliOptions{
+				FilterByPrefix: []string{"s3://mybucket/Folder/SubFolder/log*", "s3://other"},
+				FilterByGlob:   []string{"*.log"},
+				FilterByRegex:  []string{"^logs/[0-9]+$"},
+			},
+			expectError: false,
+		},
+		{
+			name: "Missing scheme",
+			options: util.CliOptions{
+				FilterByPrefix: []string{"mybucket/Folder"},
+			},
+			expectError: true,
+		},
+		{
+			name: "Missing bucket",
+			options: util.CliOptions{
+				FilterByPrefix: []string{"s3:///Folder"},
+			},
+			expectError: true,
+		},
+		{
+			name: "Invalid glob",
+			options: util.CliOptions{
+				FilterByGlob: []string{"[a-"},
+			},
+			expectError: true,
+		},
+		{
+			name: "Invalid regex",
+			options: util.CliOptions{
+				FilterByRegex: []string{"(logs"},
+			},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		_, err := NewObjectFilter(test.options)
+		assert.Equal(t, test.expectError, err != nil, test.name)
+	}
+}
+
+func TestObjectFilterListPrefix(t *testing.T) {
+	tests := []struct {
+		name           string
+		prefixes       []string
+		bucketName     string
+		expectedOutput string
+	}{
+		{
+			name:           "No pattern",
+			prefixes:       nil,
+			bucketName:     "poc-1",
+			expectedOutput: "",
+		},
+		{
+			name:           "Glob pattern",
+			prefixes:       []string{"s3://poc-1/Folder/SubFolder/log*"},
+			bucketName:     "poc-1",
+			expectedOutput: "Folder/SubFolder/log",
+		},
+		{
+			name:           "Multiple patterns on the same bucket",
+			prefixes:       []string{"s3://poc-1/Folder/SubFolder/log*", "s3://poc-1/Folder/Other/", "s3://poc-2/Data"},
+			bucketName:     "poc-1",
+			expectedOutput: "Folder/",
+		},
+		{
+			name:           "Bucket glob",
+			prefixes:       []string{"s3://poc-*/Data/"},
+			bucketName:     "poc-2",
+			expectedOutput: "Data/",
+		},
+	}
+	for _, test := range tests {
+		filter, err := NewObjectFilter(util.CliOptions{FilterByPrefix: test.prefixes})
+		assert.Nil(t, err)
+		assert.Equal(t, test.expectedOutput, filter.ListPrefix(test.bucketName), test.name)
+	}
+}
+
+func TestObjectFilterMatch(t *testing.T) {
+	filter, err := NewObjectFilter(util.CliOptions{
+		FilterByPrefix: []string{"s3://poc-1/Folder/SubFolder/log*", "s3://poc-2/Data/"},
+		FilterByRegex:  []string{`\.txt$`},
+	})
+	assert.Nil(t, err)
+	tests := []struct {
+		name           string
+		bucketName     string
+		key            string
+		expectedBucket bool
+		expectedKey    bool
+	}{
+		{
+			name:           "Matches glob and regex",
+			bucketName:     "poc-1",
+			key:            "Folder/SubFolder/log1.txt",
+			expectedBucket: true,
+			expectedKey:    true,
+		},
+		{
+			name:           "Glob does not cross delimiter",
+			bucketName:     "poc-1",
+			key:            "Folder/SubFolder/logs/a.txt",
+			expectedBucket: true,
+			expectedKey:    false,
+		},
+		{
+			name:           "Fails regex",
+			bucketName:     "poc-1",
+			key:            "Folder/SubFolder/log1.csv",
+			expectedBucket: true,
+			expectedKey:    false,
+		},
+		{
+			name:           "Literal prefix",
+			bucketName:     "poc-2",
+			key:            "Data/2024/01/file.txt",
+			expectedBucket: true,
+			expectedKey:    true,
+		},
+		{
+			name:           "Bucket not targeted",
+			bucketName:     "poc-3",
+			key:            "Data/file.txt",
+			expectedBucket: false,
+			expectedKey:    false,
+		},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expectedBucket, filter.MatchBucket(test.bucketName), test.name)
+		assert.Equal(t, test.expectedKey, filter.MatchKey(test.bucketName, test.key), test.name)
+	}
+}
@@ -0,0 +1,134 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	awsretry "github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	RETRY_BASE_DELAY = 100 * time.Millisecond
+	RETRY_MAX_DELAY  = 20 * time.Second
+)
+
+var (
+	throttleChecks  = awsretry.IsErrorThrottles(awsretry.DefaultThrottles)
+	retryableChecks = awsretry.IsErrorRetryables(awsretry.DefaultRetryables)
+)
+
+// All the calls to AWS go through the retryer. It takes a token from the rate limiter before each attempt,
+// and retries the throttled and transient errors with a jittered exponential backoff.
+// The retries of a bucket are limited by the budget of its context, see WithRetryBudget.
+type Retryer struct {
+	limiter     *AdaptiveLimiter
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	retries     atomic.Int64
+	throttles   atomic.Int64
+	exhausted   atomic.Int64
+}
+
+type retryBudgetKey struct{}
+
+type retryBudget struct {
+	remaining atomic.Int64
+}
+
+func NewRetryer(limiter *AdaptiveLimiter, maxRetries int) *Retryer {
+	return &Retryer{
+		limiter:     limiter,
+		maxAttempts: max(maxRetries, 0) + 1,
+		baseDelay:   RETRY_BASE_DELAY,
+		maxDelay:    RETRY_MAX_DELAY,
+	}
+}
+
+// Limit the number of retries of all the calls made with the context. A budget of 0 or less is unlimited.
+func WithRetryBudget(ctx context.Context, budget int) context.Context {
+	if budget <= 0 {
+		return ctx
+	}
+	b := &retryBudget{}
+	b.remaining.Store(int64(budget))
+	return context.WithValue(ctx, retryBudgetKey{}, b)
+}
+
+func takeRetryBudget(ctx context.Context) bool {
+	b, ok := ctx.Value(retryBudgetKey{}).(*retryBudget)
+	if !ok {
+		return true
+	}
+	return b.remaining.Add(-1) >= 0
+}
+
+// Call AWS with the retries. Functions can't have type parameters as methods.
+func retryCall[T any](ctx context.Context, r *Retryer, call func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		r.limiter.Take()
+		output, err := call()
+		if err == nil {
+			r.limiter.succeeded()
+			return output, nil
+		}
+		throttled := isThrottleError(err)
+		if throttled {
+			r.throttles.Add(1)
+			r.limiter.throttled()
+		}
+		if attempt >= r.maxAttempts || !(throttled || retryableChecks.IsErrorRetryable(err).Bool()) {
+			return output, err
+		}
+		if !takeRetryBudget(ctx) {
+			r.exhausted.Add(1)
+			return output, fmt.Errorf("retry budget of the bucket exhausted: %w", err)
+		}
+		r.retries.Add(1)
+		if err := sleepContext(ctx, r.backoff(attempt)); err != nil {
+			return output, err
+		}
+	}
+}
+
+// S3 answers 503 SlowDown when the request rate of a prefix is too high.
+func isThrottleError(err error) bool {
+	if throttleChecks.IsErrorThrottle(err).Bool() {
+		return true
+	}
+	var response interface{ HTTPStatusCode() int }
+	if errors.As(err, &response) {
+		return response.HTTPStatusCode() == 429 || response.HTTPStatusCode() == 503
+	}
+	return false
+}
+
+// Full jitter: a random delay between 0 and the exponential backoff of the attempt.
+func (r *Retryer) backoff(attempt int) time.Duration {
+	delay := r.maxDelay
+	if attempt < 32 {
+		delay = min(r.baseDelay<<attempt, r.maxDelay)
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Log the number of retries and throttled responses of the run.
+func (r *Retryer) LogSummary() {
+	logrus.Info("Retries: ", r.retries.Load(), ", throttled responses: ", r.throttles.Load(), ", exhausted retry budgets: ", r.exhausted.Load())
+}
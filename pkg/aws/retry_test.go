@@ -0,0 +1,94 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRetryer(maxRetries int) *Retryer {
+	retryer := NewRetryer(NewAdaptiveLimiter(1000000), maxRetries)
+	retryer.baseDelay = time.Microsecond
+	retryer.maxDelay = time.Millisecond
+	return retryer
+}
+
+// Returns the errors in order, then succeeds.
+func failingCall(errs ...error) (func() (string, error), *int) {
+	calls := 0
+	return func() (string, error) {
+		calls++
+		if calls <= len(errs) {
+			return "", errs[calls-1]
+		}
+		return "ok", nil
+	}, &calls
+}
+
+func TestRetryCall(t *testing.T) {
+	slowDown := &smithy.GenericAPIError{Code: "SlowDown", Message: "Please reduce your request rate."}
+	accessDenied := &smithy.GenericAPIError{Code: "AccessDenied"}
+	tests := []struct {
+		name              string
+		errs              []error
+		maxRetries        int
+		budget            int
+		expectedErr       bool
+		expectedCalls     int
+		expectedRetries   int64
+		expectedThrottles int64
+		expectedExhausted int64
+	}{
+		{name: "Success", maxRetries: 3, expectedCalls: 1},
+		{name: "Throttled then success", errs: []error{slowDown, slowDown}, maxRetries: 3, expectedCalls: 3, expectedRetries: 2, expectedThrottles: 2},
+		{name: "Too many throttles", errs: []error{slowDown, slowDown, slowDown}, maxRetries: 2, expectedErr: true, expectedCalls: 3, expectedRetries: 2, expectedThrottles: 3},
+		{name: "Not retryable", errs: []error{accessDenied}, maxRetries: 3, expectedErr: true, expectedCalls: 1},
+		{name: "Budget exhausted", errs: []error{slowDown, slowDown}, maxRetries: 3, budget: 1, expectedErr: true, expectedCalls: 2, expectedRetries: 1, expectedThrottles: 2, expectedExhausted: 1},
+	}
+	for _, test := range tests {
+		retryer := newTestRetryer(test.maxRetries)
+		call, calls := failingCall(test.errs...)
+		output, err := retryCall(WithRetryBudget(context.Background(), test.budget), retryer, call)
+		assert.Equal(t, test.expectedErr, err != nil, test.name)
+		if !test.expectedErr {
+			assert.Equal(t, "ok", output, test.name)
+		}
+		assert.Equal(t, test.expectedCalls, *calls, test.name)
+		assert.Equal(t, test.expectedRetries, retryer.retries.Load(), test.name)
+		assert.Equal(t, test.expectedThrottles, retryer.throttles.Load(), test.name)
+		assert.Equal(t, test.expectedExhausted, retryer.exhausted.Load(), test.name)
+	}
+}
+
+func TestRetryCallCancelled(t *testing.T) {
+	retryer := newTestRetryer(3)
+	retryer.baseDelay = time.Hour
+	retryer.maxDelay = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	call, calls := failingCall(&smithy.GenericAPIError{Code: "SlowDown"})
+	go cancel()
+	_, err := retryCall(ctx, retryer, call)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 1, *calls)
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	limiter := NewAdaptiveLimiter(1000)
+	limiter.throttled()
+	assert.Equal(t, 2*time.Millisecond, limiter.interval)
+	// A burst of throttled responses only slows down once
+	limiter.throttled()
+	assert.Equal(t, 2*time.Millisecond, limiter.interval)
+	limiter.lastThrottle = time.Time{}
+	limiter.throttled()
+	assert.Equal(t, 4*time.Millisecond, limiter.interval)
+
+	for i := 0; i < 100 && limiter.interval != 0; i++ {
+		limiter.succeeded()
+	}
+	assert.Equal(t, time.Duration(0), limiter.interval)
+}
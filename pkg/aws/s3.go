@@ -1,17 +1,18 @@
 package aws
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"slices"
 	"sync"
 	"time"
 
 	"projet-devops-coveo/pkg/util"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/sirupsen/logrus"
-	"go.uber.org/ratelimit"
 )
 
 type S3 struct {
@@ -19,11 +20,21 @@ type S3 struct {
 	totalStorageClassSize *util.StorageClassSize
 	region                string
 	options               util.CliOptions
+	objectFilter          *ObjectFilter
+	inventories           map[string]*InventoryManifest
+	checkpoint            *Checkpoint
+	duplicates            *DuplicateIndex
+	// Lifecycle of the buckets by name with --lifecycle-projection, fetched before their scan
+	lifecycles sync.Map
 }
 
 // Establish connection with S3 services
-func InitConnection(region string, options util.CliOptions, globalStorageClass *util.StorageClassSize, limiter ratelimit.Limiter) (*S3, error) {
-	awsClient, err := NewAwsClient(region, limiter)
+func InitConnection(ctx context.Context, region string, options util.CliOptions, globalStorageClass *util.StorageClassSize, retryer *Retryer, checkpoint *Checkpoint, duplicates *DuplicateIndex, inventories map[string]*InventoryManifest) (*S3, error) {
+	awsClient, err := NewAwsClient(ctx, region, retryer)
+	if err != nil {
+		return nil, err
+	}
+	objectFilter, err := NewObjectFilter(options)
 	if err != nil {
 		return nil, err
 	}
@@ -33,29 +44,44 @@ func InitConnection(region string, options util.CliOptions, globalStorageClass *
 		totalStorageClassSize: globalStorageClass,
 		region:                region,
 		options:               options,
+		objectFilter:          objectFilter,
+		inventories:           inventories,
+		checkpoint:            checkpoint,
+		duplicates:            duplicates,
 	}, nil
 }
 
 // List All buckets and  returns a filtered list based on filters (name, region)
-func (fs *S3) GetBucketsFiltered() (bucketList []util.CloudFilesystem) {
-	output, err := fs.session.ListBuckets(&s3.ListBucketsInput{})
+func (fs *S3) GetBucketsFiltered(ctx context.Context, locations *LocationCache) ([]util.CloudFilesystem, error) {
+	output, err := fs.session.ListBuckets(ctx, &s3.ListBucketsInput{})
 	if err != nil {
-		fmt.Println(err.Error())
+		return nil, err
 	}
 
-	return fs.FilterBuckets(output.Buckets)
+	return fs.FilterBuckets(ctx, output.Buckets, locations), nil
 }
 
-// Fetch location of bucket and filter if not in wanted region or not included by name
-func (fs *S3) FilterBuckets(buckets []types.Bucket) (bucketList []util.CloudFilesystem) {
-	for _, bucket := range buckets {
-		location, err := fs.session.GetBucketLocation(&s3.GetBucketLocationInput{
-			Bucket: bucket.Name,
-		})
-		if err != nil {
-			logrus.Error(err)
+// Fetch location of bucket and filter if not in wanted region or not included by name.
+// The locations are fetched concurrently, the buckets keep the order of the list.
+func (fs *S3) FilterBuckets(ctx context.Context, buckets []types.Bucket, locations *LocationCache) (bucketList []util.CloudFilesystem) {
+	filtered := make([]util.CloudFilesystem, len(buckets))
+	concurrencyThrottle := make(chan int, max(fs.options.Threading, 1))
+	wg := new(sync.WaitGroup)
+	for i, bucket := range buckets {
+		// The location of a bucket excluded by name isn't needed
+		if !fs.isBucketIncluded(*bucket.Name) {
+			continue
 		}
-		bucket := fs.filterbucket(location, *bucket.Name, *bucket.CreationDate)
+		wg.Add(1)
+		concurrencyThrottle <- 1
+		go func() {
+			defer wg.Done()
+			filtered[i] = fs.locateBucket(ctx, bucket, locations)
+			<-concurrencyThrottle
+		}()
+	}
+	wg.Wait()
+	for _, bucket := range filtered {
 		if bucket != nil {
 			bucketList = append(bucketList, bucket)
 		}
@@ -63,44 +89,75 @@ func (fs *S3) FilterBuckets(buckets []types.Bucket) (bucketList []util.CloudFile
 	return bucketList
 }
 
-// Filter Bucket For region and name
-func (fs *S3) filterbucket(location string, bucketName string, bucketCreationDate time.Time) util.CloudFilesystem {
-	if slices.Contains(fs.options.Regions, location) {
-		// Filter by names if there's a filter activated
-		if fs.options.FilterByName != nil && len(fs.options.FilterByName) != 0 && !slices.Contains(fs.options.FilterByName, bucketName) {
-			return nil
+// Get the location of the bucket from the cache or from AWS, then filter it by region.
+// A bucket whose location can't be fetched (Ex.: AccessDenied) is kept without region and marked failed,
+// so it isn't dropped silently from the output.
+func (fs *S3) locateBucket(ctx context.Context, bucket types.Bucket, locations *LocationCache) util.CloudFilesystem {
+	location, ok := locations.get(*bucket.Name, *bucket.CreationDate)
+	if !ok {
+		var err error
+		location, err = fs.session.GetBucketLocation(ctx, &s3.GetBucketLocationInput{
+			Bucket: bucket.Name,
+		})
+		if err != nil {
+			logrus.Error("Unable to get the location of bucket ", *bucket.Name, ": ", err)
+			failed := newBucket(*bucket.Name, "", *bucket.CreationDate)
+			failed.SetStatus(util.BUCKET_STATUS_FAILED, err.Error())
+			return failed
 		}
-		bucket := util.NewCloudFileSystem("S3")
-		bucket.SetName(bucketName)
-		bucket.SetRegion(location)
-		bucket.SetCreationDate(bucketCreationDate)
-		return bucket
+		locations.set(*bucket.Name, *bucket.CreationDate, location)
+	}
+	return fs.filterbucket(location, *bucket.Name, *bucket.CreationDate)
+}
+
+// Filter Bucket For region and name. All the regions are kept when they are discovered.
+func (fs *S3) filterbucket(location string, bucketName string, bucketCreationDate time.Time) util.CloudFilesystem {
+	if (fs.options.AllRegions || slices.Contains(fs.options.Regions, location)) && fs.isBucketIncluded(bucketName) {
+		return newBucket(bucketName, location, bucketCreationDate)
 	}
 	return nil
 }
 
+// Filter Bucket by name
+func (fs *S3) isBucketIncluded(bucketName string) bool {
+	// Filter by names if there's a filter activated
+	if fs.options.FilterByName != nil && len(fs.options.FilterByName) != 0 && !slices.Contains(fs.options.FilterByName, bucketName) {
+		return false
+	}
+	// Filter by s3:// patterns if there's a filter activated
+	return fs.objectFilter.MatchBucket(bucketName)
+}
+
+func newBucket(bucketName string, location string, bucketCreationDate time.Time) util.CloudFilesystem {
+	bucket := util.NewCloudFileSystem("S3")
+	bucket.SetName(bucketName)
+	bucket.SetRegion(location)
+	bucket.SetCreationDate(bucketCreationDate)
+	return bucket
+}
+
 // List all objects in a specific region
-func (fs *S3) ListObjectsInBucket(regionBucket []util.CloudFilesystem, region string, priceList MasterPriceList, wg *sync.WaitGroup, bucketChan chan ([]util.CloudFilesystem)) {
+func (fs *S3) ListObjectsInBucket(ctx context.Context, regionBucket []util.CloudFilesystem, region string, priceList MasterPriceList, wg *sync.WaitGroup, bucketChan chan ([]util.CloudFilesystem)) {
 	defer wg.Done()
-	DTOBuckets := fs.GetObject(regionBucket, priceList)
+	DTOBuckets := fs.GetObject(ctx, regionBucket, priceList)
 	bucketChan <- DTOBuckets
 }
 
 // Get objects of a buckets
-func (fs *S3) GetObject(directories []util.CloudFilesystem, priceList MasterPriceList) (buckets []util.CloudFilesystem) {
+func (fs *S3) GetObject(ctx context.Context, directories []util.CloudFilesystem, priceList MasterPriceList) (buckets []util.CloudFilesystem) {
 	bucketChan := make(chan util.CloudFilesystem, len(directories))
 	concurrencyThrottle := make(chan int, fs.options.Threading)
 	wg := new(sync.WaitGroup)
 	for _, bucket := range directories {
 		wg.Add(1)
 		concurrencyThrottle <- 1
-		go fs.FetchBucket(bucket, bucketChan, wg, concurrencyThrottle)
+		go fs.FetchBucket(ctx, bucket, bucketChan, wg, concurrencyThrottle)
 	}
 	wg.Wait()
 	close(bucketChan)
 	for bucket := range bucketChan {
-		//Filter empty bucket if flag is false
-		if fs.options.OmitEmpty && bucket.GetNbOfFiles() == 0 {
+		//Filter empty bucket if flag is false. Buckets that failed are always kept.
+		if fs.options.OmitEmpty && bucket.GetNbOfFiles() == 0 && bucket.GetStatus() == util.BUCKET_STATUS_COMPLETE {
 			continue
 		}
 		buckets = append(buckets, bucket)
@@ -109,7 +166,7 @@ func (fs *S3) GetObject(directories []util.CloudFilesystem, priceList MasterPric
 }
 
 // Get all object of a bucket
-func (fs *S3) FetchBucket(bucket util.CloudFilesystem, bucketChan chan (util.CloudFilesystem), wg *sync.WaitGroup, concurrencyThrottle chan (int)) {
+func (fs *S3) FetchBucket(ctx context.Context, bucket util.CloudFilesystem, bucketChan chan (util.CloudFilesystem), wg *sync.WaitGroup, concurrencyThrottle chan (int)) {
 	defer wg.Done()
 	defer func() {
 		<-concurrencyThrottle
@@ -117,63 +174,461 @@ func (fs *S3) FetchBucket(bucket util.CloudFilesystem, bucketChan chan (util.Clo
 	if bucket.GetRegion() != fs.region {
 		return
 	}
+	ctx = WithRetryBudget(ctx, fs.options.RetryBudget)
+	// The bucket timeout only stops the scan of this bucket
+	if fs.options.BucketTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fs.options.BucketTimeout)
+		defer cancel()
+	}
 
-	storageClassSize := make(util.StorageClassSizeMap)
-	var totalSize int64
-	var nbOfFiles int64
-	var lastModifiedBucket time.Time
-	loc, _ := time.LoadLocation("Local")
-	//Recursively, list objects in a bucket and build the bucket metadata at the same time.
-	paginator := fs.session.NewListObjectsV2Paginator(bucket.GetName())
+	// The default encryption and the tags are fetched first so the buckets filtered out are not scanned
+	encryption, kmsKeyId, err := fs.fetchDefaultEncryption(ctx, bucket.GetName())
+	if err != nil {
+		logrus.Warn("Unable to get the default encryption of bucket ", bucket.GetName(), ": ", err)
+	}
+	if !fs.isEncryptionIncluded(encryption) {
+		return
+	}
+	bucket.SetEncryption(encryption, kmsKeyId)
+	// A bucket whose tags can't be fetched is kept so it isn't dropped silently
+	tags, err := fs.fetchTags(ctx, bucket.GetName())
+	if err != nil {
+		logrus.Warn("Unable to get the tags of bucket ", bucket.GetName(), ": ", err)
+	} else if !fs.isTagIncluded(tags) {
+		return
+	}
+	bucket.SetTags(tags)
+
+	// The rules are needed to scan the objects, a bucket whose lifecycle can't be fetched isn't projected
+	if fs.options.GetLifecycleProjection() {
+		lifecycle, err := fs.fetchLifecycle(ctx, bucket.GetName())
+		if err != nil {
+			logrus.Warn("Unable to get the lifecycle of bucket ", bucket.GetName(), ": ", err)
+		} else {
+			fs.lifecycles.Store(bucket.GetName(), lifecycle)
+		}
+	}
+
+	// Buckets scanned before the scan was interrupted are taken from the checkpoint
+	stats, done := fs.checkpoint.finishedBucket(bucket.GetName())
+	bucket.SetStatus(util.BUCKET_STATUS_COMPLETE, "")
+	if !done {
+		var err error
+		stats, err = fs.fetchBucketStats(ctx, bucket.GetName(), concurrencyThrottle)
+		// Storage metrics already include the parts of incomplete multipart uploads
+		if fs.options.IncludeMultipartUploads && fs.options.Source != util.SOURCE_CLOUDWATCH {
+			err = errors.Join(err, fs.fetchMultipartUploads(ctx, bucket.GetName(), stats))
+		}
+		// An interrupted or failed bucket keeps the progress of its prefixes in the checkpoint so it can be resumed
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			logrus.Warn("The scan of bucket ", bucket.GetName(), " is incomplete: ", ctxErr)
+			bucket.SetStatus(util.BUCKET_STATUS_INCOMPLETE, "scan interrupted: "+ctxErr.Error())
+		} else if err != nil {
+			logrus.Error("The scan of bucket ", bucket.GetName(), " failed: ", err)
+			bucket.SetStatus(util.BUCKET_STATUS_FAILED, err.Error())
+		} else {
+			fs.checkpoint.finishBucket(bucket.GetName(), stats)
+		}
+	}
+	fs.addToRegionTotal(stats)
+	stats.applyTo(bucket)
+	fs.applyReports(bucket, stats)
+	bucket.SetUsage(fs.getBucketUsage(ctx, bucket.GetName(), stats))
+	// The configuration isn't saved in the checkpoint, it's fetched again when the scan is resumed
+	if fs.options.IncludeConfig && ctx.Err() == nil {
+		bucket.SetConfig(fs.fetchBucketConfig(ctx, bucket.GetName()))
+	}
+
+	bucketChan <- bucket
+}
+
+// Build the metadata of a bucket from its storage metrics or its inventory report if there's one, otherwise by listing its objects.
+func (fs *S3) fetchBucketStats(ctx context.Context, bucketName string, concurrencyThrottle chan (int)) (*bucketStats, error) {
+	if fs.options.Source == util.SOURCE_CLOUDWATCH {
+		stats, err := fs.fetchMetrics(ctx, bucketName)
+		if err != nil {
+			return newBucketStats(), err
+		}
+		return stats, nil
+	}
+	if manifest, ok := fs.inventories[bucketName]; ok {
+		stats, err := fs.fetchInventory(ctx, bucketName, manifest)
+		if err == nil {
+			return stats, nil
+		}
+		logrus.Error(err)
+		logrus.Info("Listing the objects of bucket ", bucketName, " instead of reading its inventory")
+	}
+	if fs.options.IncludeVersions {
+		return fs.fetchObjectVersions(ctx, bucketName)
+	}
+	return fs.fetchObjects(ctx, bucketName, concurrencyThrottle)
+}
+
+// Build the metadata of a bucket from an S3 Inventory report.
+func (fs *S3) fetchInventory(ctx context.Context, bucketName string, manifest *InventoryManifest) (*bucketStats, error) {
+	stats := newBucketStats()
+	err := manifest.ReadRecords(ctx, func(record inventoryRecord) {
+		if record.isDeleteMarker {
+			if fs.options.IncludeVersions && fs.objectFilter.MatchKey(bucketName, record.key) {
+				stats.addDeleteMarker()
+			}
+			return
+		}
+		if record.storageClass == "" {
+			record.storageClass = S3_STORAGE_CLASS_STANDARD
+		}
+		if !fs.isObjectIncluded(bucketName, record.key, record.storageClass) {
+			return
+		}
+		if record.isLatest {
+			fs.addObject(ctx, stats, bucketName, record.key, record.eTag, record.size, record.storageClass, record.lastModified)
+			if record.encryptionStatus != "" {
+				stats.addObjectEncryption(getInventoryEncryption(record.encryptionStatus), record.size, 1)
+			}
+		} else if fs.options.IncludeVersions {
+			stats.addNoncurrentVersion(record.size, record.storageClass)
+			// The records of a key are not ordered, the version is taken as the newest noncurrent one since it was created
+			fs.addLifecycleNoncurrentVersion(ctx, stats, bucketName, record.key, record.versionId, record.size, record.storageClass, record.lastModified, 0)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// List the current objects of a bucket and build the bucket metadata at the same time.
+// The prefix tree is discovered with the delimiter first, then the prefixes are listed concurrently.
+func (fs *S3) fetchObjects(ctx context.Context, bucketName string, concurrencyThrottle chan (int)) (*bucketStats, error) {
+	stats, partitions, discovered := fs.checkpoint.discoveredPrefixes(bucketName)
+	if !discovered {
+		stats = newBucketStats()
+		//The literal part of the s3:// patterns is sent to S3 so only the objects that could match are listed.
+		prefix := fs.objectFilter.ListPrefix(bucketName)
+		partitions = []string{prefix}
+		if fs.options.PartitionDepth > 0 {
+			partial := newBucketStats()
+			prefixes, err := fs.discoverPrefixes(ctx, bucketName, prefix, partial)
+			if err != nil {
+				// The objects found so far are dropped, the whole prefix is listed instead
+				logrus.Error(err)
+			} else {
+				partitions = prefixes
+				stats = partial
+				fs.checkpoint.setDiscoveredPrefixes(bucketName, stats, partitions)
+			}
+		}
+	}
+	err := fs.listPartitions(ctx, bucketName, partitions, stats, concurrencyThrottle)
+	return stats, err
+}
+
+// List the objects under a prefix with a delimiter and add them to the stats. Returns the common prefixes.
+func (fs *S3) listObjects(ctx context.Context, bucketName string, prefix string, delimiter string, stats *bucketStats) (commonPrefixes []string, err error) {
+	paginator := fs.session.NewListObjectsV2Paginator(&s3.ListObjectsV2Input{
+		Bucket:    aws.String(bucketName),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String(delimiter),
+	})
 
 	// Iterate through pages
 	for fs.session.HasMorePages(paginator) {
 		// Get the next page of objects
-		resp, err := fs.session.NextPage(paginator)
+		resp, err := fs.session.NextPage(ctx, paginator)
 		if err != nil {
-			logrus.Error(err)
+			return nil, err
 		}
 
-		// Process objects
-		for _, obj := range resp.Contents {
-			if fs.options.FilterByStorageClass != nil {
-				if len(fs.options.FilterByStorageClass) != 0 && fs.options.FilterByStorageClass != nil &&
-					!slices.Contains(fs.options.FilterByStorageClass, GetStorageClassConstant(obj.StorageClass)) {
-					continue
+		if err := fs.addObjects(ctx, bucketName, resp.Contents, stats); err != nil {
+			return nil, err
+		}
+		for _, commonPrefix := range resp.CommonPrefixes {
+			commonPrefixes = append(commonPrefixes, *commonPrefix.Prefix)
+		}
+	}
+	return commonPrefixes, nil
+}
+
+// List all the objects under a prefix, from where the checkpoint stopped. The progress is saved after each page.
+func (fs *S3) listPartition(ctx context.Context, bucketName string, prefix string) (*bucketStats, error) {
+	progress := fs.checkpoint.partition(bucketName, prefix)
+	if progress.Done {
+		return progress.Stats, nil
+	}
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(prefix),
+	}
+	if progress.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(progress.ContinuationToken)
+	}
+	paginator := fs.session.NewListObjectsV2Paginator(input)
+
+	for fs.session.HasMorePages(paginator) {
+		resp, err := fs.session.NextPage(ctx, paginator)
+		if err != nil {
+			return progress.Stats, err
+		}
+
+		// The page isn't saved if it's interrupted, it's listed again when the scan is resumed
+		if err := fs.addObjects(ctx, bucketName, resp.Contents, progress.Stats); err != nil {
+			return progress.Stats, err
+		}
+		progress.ContinuationToken = aws.ToString(resp.NextContinuationToken)
+		fs.checkpoint.updatePartition(bucketName, prefix, progress)
+	}
+	progress.Done = true
+	fs.checkpoint.updatePartition(bucketName, prefix, progress)
+	return progress.Stats, nil
+}
+
+// Add a current object to the stats, to the usage of its prefixes with --prefix-depth, to the largest objects with --top,
+// to the duplicates index with --duplicates and to the ages of its lifecycle rules with --lifecycle-projection.
+// The largest prefixes are the top-level ones of a bounded sketch when --top is used without --prefix-depth.
+func (fs *S3) addObject(ctx context.Context, stats *bucketStats, bucketName string, key string, eTag string, size int64, storageClass string, lastModified time.Time) {
+	stats.addObject(size, storageClass, lastModified)
+	fs.addLifecycleObject(ctx, stats, bucketName, key, size, storageClass, lastModified)
+	fs.duplicates.add(bucketName, fs.region, key, eTag, size, storageClass)
+	top := fs.options.GetTop()
+	if depth := fs.options.PrefixDepth; depth > 0 {
+		stats.addPrefixes(key, size, storageClass, lastModified, depth)
+	} else if top > 0 {
+		stats.addLargestPrefix(key, size, storageClass, lastModified, top)
+	}
+	if top > 0 {
+		stats.addLargestObject(largestObject{Key: key, Size: size, StorageClass: storageClass, LastModified: lastModified}, top)
+	}
+}
+
+// Set the reports of the output options on the bucket.
+func (fs *S3) applyReports(bucket util.CloudFilesystem, stats *bucketStats) {
+	if fs.options.PrefixDepth > 0 && len(stats.prefixes) != 0 {
+		bucket.SetPrefixes(stats.prefixTree())
+	}
+	if lifecycle := fs.getLifecycle(bucket.GetName()); lifecycle != nil {
+		bucket.SetLifecycleProjection(stats.projectLifecycle(lifecycle, time.Now()))
+	}
+	if len(fs.options.SimulationRules) != 0 {
+		bucket.SetSimulation(stats.simulate(fs.options.SimulationRules, time.Now()))
+	}
+	if fs.options.OutputOptions == nil {
+		return
+	}
+	if fs.options.OutputOptions.StaleDays > 0 {
+		bucket.SetStaleSize(float64(stats.sizeOlderThan(time.Now(), fs.options.OutputOptions.StaleDays)))
+	}
+	if top := fs.options.OutputOptions.Top; top > 0 && stats.largestObjects != nil {
+		bucket.SetLargest(stats.largestObjects.sorted(), stats.topPrefixes(top))
+	}
+}
+
+// Add the objects of a page to the stats. The sampled objects are headed to get their encryption.
+func (fs *S3) addObjects(ctx context.Context, bucketName string, objects []types.Object, stats *bucketStats) error {
+	for _, obj := range objects {
+		storageClass := GetStorageClassConstant(obj.StorageClass)
+		if !fs.isObjectIncluded(bucketName, *obj.Key, storageClass) {
+			continue
+		}
+		fs.addObject(ctx, stats, bucketName, *obj.Key, aws.ToString(obj.ETag), *obj.Size, storageClass, *obj.LastModified)
+		if fs.isObjectSampled(*obj.Key) {
+			encryption := fs.headObjectEncryption(ctx, bucketName, *obj.Key)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			stats.addObjectEncryption(encryption, *obj.Size, 1/min(fs.options.EncryptionSample, 1))
+		}
+	}
+	return nil
+}
+
+// List all the versions of the objects of a bucket. Noncurrent versions and delete markers are accounted separately.
+// The progress is saved after each page like for the current objects.
+func (fs *S3) fetchObjectVersions(ctx context.Context, bucketName string) (*bucketStats, error) {
+	prefix := fs.objectFilter.ListPrefix(bucketName)
+	progress := fs.checkpoint.partition(bucketName, prefix)
+	if progress.Done {
+		return progress.Stats, nil
+	}
+	stats := progress.Stats
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(prefix),
+	}
+	if progress.KeyMarker != "" {
+		input.KeyMarker = aws.String(progress.KeyMarker)
+		input.VersionIdMarker = aws.String(progress.VersionIdMarker)
+	}
+	paginator := fs.session.NewListObjectVersionsPaginator(input)
+
+	for fs.session.HasMoreVersionsPages(paginator) {
+		resp, err := fs.session.NextVersionsPage(ctx, paginator)
+		if err != nil {
+			return stats, err
+		}
+
+		// The versions and the delete markers of a key are listed from the newest, a version became noncurrent when
+		// the next newer one was created. The key being listed is saved so it continues on the next page.
+		versions, markers := resp.Versions, resp.DeleteMarkers
+		for len(versions) != 0 || len(markers) != 0 {
+			if len(markers) != 0 && (len(versions) == 0 || isListedBefore(*markers[0].Key, aws.ToTime(markers[0].LastModified), *versions[0].Key, aws.ToTime(versions[0].LastModified))) {
+				marker := markers[0]
+				markers = markers[1:]
+				if fs.objectFilter.MatchKey(bucketName, *marker.Key) {
+					stats.addDeleteMarker()
 				}
+				progress.listVersionsOf(*marker.Key)
+				progress.PreviousModified = aws.ToTime(marker.LastModified)
+				continue
 			}
-			nbOfFiles += 1
-			totalSize += *obj.Size
-			storageClassSize[GetStorageClassConstant(obj.StorageClass)] += (float64(*obj.Size))
+			version := versions[0]
+			versions = versions[1:]
+			progress.listVersionsOf(*version.Key)
+			storageClass := GetStorageClassConstant(types.ObjectStorageClass(version.StorageClass))
+			if fs.isObjectIncluded(bucketName, *version.Key, storageClass) {
+				if aws.ToBool(version.IsLatest) {
+					fs.addObject(ctx, stats, bucketName, *version.Key, aws.ToString(version.ETag), *version.Size, storageClass, *version.LastModified)
+				} else {
+					stats.addNoncurrentVersion(*version.Size, storageClass)
+					noncurrent := noncurrentSince(aws.ToTime(version.LastModified), progress.PreviousModified)
+					fs.addLifecycleNoncurrentVersion(ctx, stats, bucketName, *version.Key, aws.ToString(version.VersionId), *version.Size, storageClass, noncurrent, progress.Rank)
+				}
+			}
+			if !aws.ToBool(version.IsLatest) {
+				progress.Rank++
+			}
+			progress.PreviousModified = aws.ToTime(version.LastModified)
+		}
+		progress.KeyMarker = aws.ToString(resp.NextKeyMarker)
+		progress.VersionIdMarker = aws.ToString(resp.NextVersionIdMarker)
+		fs.checkpoint.updatePartition(bucketName, prefix, progress)
+	}
+	progress.Done = true
+	fs.checkpoint.updatePartition(bucketName, prefix, progress)
+	return stats, nil
+}
 
-			fs.totalStorageClassSize.Mutex.Lock()
-			fs.totalStorageClassSize.SizeMap[fs.region][GetStorageClassConstant(obj.StorageClass)] += float64(*obj.Size)
-			fs.totalStorageClassSize.Mutex.Unlock()
+// List the incomplete multipart uploads of a bucket. Their parts are billed but never listed with the objects.
+func (fs *S3) fetchMultipartUploads(ctx context.Context, bucketName string, stats *bucketStats) error {
+	paginator := fs.session.NewListMultipartUploadsPaginator(&s3.ListMultipartUploadsInput{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(fs.objectFilter.ListPrefix(bucketName)),
+	})
 
-			if lastModifiedBucket.Before(*obj.LastModified) {
-				currentTime := obj.LastModified.In(loc)
-				lastModifiedBucket = currentTime
+	var errs []error
+	for fs.session.HasMoreMultipartUploadsPages(paginator) {
+		resp, err := fs.session.NextMultipartUploadsPage(ctx, paginator)
+		if err != nil {
+			return err
+		}
+
+		for _, upload := range resp.Uploads {
+			storageClass := GetStorageClassConstant(types.ObjectStorageClass(upload.StorageClass))
+			if storageClass == "" {
+				storageClass = S3_STORAGE_CLASS_STANDARD
+			}
+			if !fs.isObjectIncluded(bucketName, *upload.Key, storageClass) {
+				continue
 			}
+			size, err := fs.getMultipartUploadSize(ctx, bucketName, upload)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			stats.addMultipartUpload(size, storageClass, aws.ToTime(upload.Initiated))
 		}
 	}
+	return errors.Join(errs...)
+}
 
-	bucket.SetNbOfFiles(nbOfFiles)
-	bucket.SetSizeOfBucket(float64(totalSize))
-	bucket.SetStorageClass(storageClassSize)
-	bucket.SetLastUpdateDate(lastModifiedBucket)
+// Versions and delete markers are listed by key and from the newest.
+func isListedBefore(key string, lastModified time.Time, otherKey string, otherLastModified time.Time) bool {
+	if key != otherKey {
+		return key < otherKey
+	}
+	return lastModified.After(otherLastModified)
+}
 
-	bucketChan <- bucket
+// Get the total size of the parts already uploaded for a multipart upload.
+func (fs *S3) getMultipartUploadSize(ctx context.Context, bucketName string, upload types.MultipartUpload) (size int64, err error) {
+	paginator := fs.session.NewListPartsPaginator(&s3.ListPartsInput{
+		Bucket:   aws.String(bucketName),
+		Key:      upload.Key,
+		UploadId: upload.UploadId,
+	})
+
+	for fs.session.HasMorePartsPages(paginator) {
+		resp, err := fs.session.NextPartsPage(ctx, paginator)
+		if err != nil {
+			return 0, err
+		}
+		for _, part := range resp.Parts {
+			size += aws.ToInt64(part.Size)
+		}
+	}
+	return size, nil
+}
+
+// Returns true if the object passes the storage class and key filters given by the user.
+func (fs *S3) isObjectIncluded(bucketName string, key string, storageClass string) bool {
+	if len(fs.options.FilterByStorageClass) != 0 && !slices.Contains(fs.options.FilterByStorageClass, storageClass) {
+		return false
+	}
+	return fs.objectFilter.MatchKey(bucketName, key)
 }
 
-// Set the bucket cost based on total cost of S3 Service
-func (fs *S3) SetBucketCost(buckets []util.CloudFilesystem, priceList MasterPriceList) {
-	tierListPrice := GetTierPriceList(fs.totalStorageClassSize.SizeMap[fs.region], priceList[fs.region])
+// Add the billable size of the bucket per storage class to the total of the region.
+// Noncurrent versions and parts of incomplete multipart uploads are billed like current objects.
+func (fs *S3) addToRegionTotal(stats *bucketStats) {
+	fs.totalStorageClassSize.Mutex.Lock()
+	defer fs.totalStorageClassSize.Mutex.Unlock()
+	for k, v := range stats.billableStorageClassSize() {
+		fs.totalStorageClassSize.SizeMap[fs.region][k] += v
+	}
+}
+
+// Set the bucket cost based on total cost of S3 Service in the region of the bucket.
+// The requests, the retrievals, the monitoring and the transfers are added to the storage with their usage.
+// The storage classes that can't be priced are returned as errors.
+func (fs *S3) SetBucketCost(buckets []util.CloudFilesystem, priceList MasterPriceList) []util.ScanError {
+	tierListPrices, priceErrors := fs.GetTierListPrices(priceList)
+	feeListPrices := GetFeeListPrices(buckets, priceList)
 	for _, bucket := range buckets {
-		var total float64
-		for k, v := range bucket.GetStorageClass() {
-			totalSize := float64(fs.totalStorageClassSize.SizeMap[fs.region][k])
-			total += (TransformSizeToGB(v) / TransformSizeToGB(totalSize)) * (tierListPrice[k] * TransformSizeToGB(totalSize))
+		region := bucket.GetRegion()
+		tierListPrice := tierListPrices[region]
+		var cost util.CostBreakdown
+		// The scanned buckets are billed by their billable size. Noncurrent versions and incomplete multipart uploads
+		// are billed at the same rate as the current objects of their storage class.
+		cost.Storage = storageCost(bucketBillableStorageClassSize(bucket), tierListPrice)
+		bucket.SetMinimumDurationCost(storageCost(bucket.GetMinimumDurationSize(), tierListPrice))
+		addFeeCost(&cost, getFeeVolumes(bucket), feeListPrices[region])
+		bucket.SetCost(cost)
+		setPrefixesCost(bucket.GetPrefixes(), tierListPrice)
+	}
+	return priceErrors
+}
+
+// Price per GB of the storage classes of each region, the tier is the one of the total size of the storage class in the region.
+// The storage classes that can't be priced are returned as errors of their region.
+func (fs *S3) GetTierListPrices(priceList MasterPriceList) (map[string]map[string]float64, []util.ScanError) {
+	tierListPrices := make(map[string]map[string]float64)
+	var priceErrors []util.ScanError
+	for region, storageClassSize := range fs.totalStorageClassSize.SizeMap {
+		tierListPrice, err := GetTierPriceList(storageClassSize, priceList[region])
+		tierListPrices[region] = tierListPrice
+		priceErrors = appendRegionPriceErrors(priceErrors, priceList, region, err)
+	}
+	return tierListPrices, priceErrors
+}
+
+// The prefixes are billed at the same rate as their bucket.
+func setPrefixesCost(prefixes []*util.PrefixUsage, tierListPrice map[string]float64) {
+	for _, prefix := range prefixes {
+		prefix.Cost = 0
+		for k, v := range prefix.StorageClassSize {
+			prefix.Cost += TransformSizeToGB(v) * tierListPrice[k]
 		}
-		bucket.SetCost(total)
+		setPrefixesCost(prefix.Prefixes, tierListPrice)
 	}
 }
@@ -0,0 +1,65 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+const S3_DELIMITER = "/"
+
+// Discover the prefixes of a bucket with the delimiter, up to the partition depth.
+// Objects found directly at a visited level are added to the stats. Returns the prefixes left to list.
+func (fs *S3) discoverPrefixes(ctx context.Context, bucketName string, prefix string, stats *bucketStats) ([]string, error) {
+	partitions := []string{prefix}
+	for depth := 0; depth < fs.options.PartitionDepth; depth++ {
+		var next []string
+		for _, partition := range partitions {
+			commonPrefixes, err := fs.listObjects(ctx, bucketName, partition, S3_DELIMITER, stats)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, commonPrefixes...)
+		}
+		partitions = next
+		if len(partitions) == 0 {
+			break
+		}
+	}
+	return partitions, nil
+}
+
+// List the prefixes concurrently and merge their partial stats.
+// A prefix is listed in a new task only if there's room in the concurrency throttle, otherwise it's listed
+// by the task of the bucket. This way, the throttle is never exceeded and the bucket tasks can't deadlock.
+func (fs *S3) listPartitions(ctx context.Context, bucketName string, partitions []string, stats *bucketStats, concurrencyThrottle chan (int)) error {
+	mutex := new(sync.Mutex)
+	wg := new(sync.WaitGroup)
+	var errs []error
+	listPartition := func(partition string) {
+		partial, err := fs.listPartition(ctx, bucketName, partition)
+		mutex.Lock()
+		stats.merge(partial)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		mutex.Unlock()
+	}
+	for _, partition := range partitions {
+		select {
+		case concurrencyThrottle <- 1:
+			wg.Add(1)
+			go func(partition string) {
+				defer wg.Done()
+				defer func() {
+					<-concurrencyThrottle
+				}()
+				listPartition(partition)
+			}(partition)
+		default:
+			listPartition(partition)
+		}
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
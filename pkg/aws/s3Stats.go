@@ -0,0 +1,576 @@
+package aws
+
+import (
+	"encoding/json"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"projet-devops-coveo/pkg/util"
+)
+
+// Metadata of a bucket built while paging through its objects.
+type bucketStats struct {
+	nbOfFiles                  int64
+	totalSize                  int64
+	storageClassSize           util.StorageClassSizeMap
+	lastModified               time.Time
+	nbOfNoncurrentFiles        int64
+	noncurrentSize             int64
+	noncurrentStorageClassSize util.StorageClassSizeMap
+	nbOfDeleteMarkers          int64
+	nbOfMultipartUploads       int64
+	multipartSize              int64
+	multipartStorageClassSize  util.StorageClassSizeMap
+	oldestMultipartUpload      time.Time
+	// Size billed beyond the size of the current objects and the noncurrent versions by storage class:
+	// the minimum billable sizes and the metadata of the archived objects
+	billableOverhead util.StorageClassSizeMap
+	// Estimated from the sample when the objects are sampled
+	encryptionNbOfFiles util.StorageClassSizeMap
+	encryptionSize      util.StorageClassSizeMap
+	// By storage class
+	sizeHistograms map[string]*histogram
+	// By storage class and by day of last modification, the ages are computed when the stats are applied
+	// so they are relative to the end of the scan, even when it was resumed.
+	modifiedDays   map[string]map[int64]*modifiedDay
+	oldestModified time.Time
+	// Usage of the current objects by key prefix with --prefix-depth, a prefix ends with the delimiter
+	prefixes map[string]*prefixUsage
+	// With --top, created with the first object
+	largestObjects *largestObjects
+	// With --top without --prefix-depth, created with the first object in a prefix
+	largestPrefixes *largestPrefixes
+	// With --lifecycle-projection, the noncurrent versions are by day they became noncurrent
+	lifecycleCurrent    lifecycleAges
+	lifecycleNoncurrent lifecycleAges
+}
+
+// Number and size of the objects per bin, the bins are delimited by their upper bounds.
+type histogram struct {
+	NbOfFiles []int64 `json:"nbOfFiles"`
+	Size      []int64 `json:"size"`
+}
+
+func newHistogram(nbOfBins int) *histogram {
+	return &histogram{
+		NbOfFiles: make([]int64, nbOfBins),
+		Size:      make([]int64, nbOfBins),
+	}
+}
+
+// Index of the bin of a value, the bound is excluded from its bin and the last bin has the values above the last bound.
+func getBin(bounds []int64, value int64) int {
+	bin, _ := slices.BinarySearchFunc(bounds, value, func(bound int64, value int64) int {
+		if bound <= value {
+			return -1
+		}
+		return 1
+	})
+	return bin
+}
+
+func (h *histogram) add(bin int, nbOfFiles int64, size int64) {
+	h.NbOfFiles[bin] += nbOfFiles
+	h.Size[bin] += size
+}
+
+func (h *histogram) merge(partial *histogram) {
+	for i := range partial.NbOfFiles {
+		h.add(i, partial.NbOfFiles[i], partial.Size[i])
+	}
+}
+
+func (h *histogram) bins(names []string) []util.HistogramBin {
+	bins := make([]util.HistogramBin, len(names))
+	for i, name := range names {
+		bins[i] = util.HistogramBin{Bin: name, NbOfFiles: h.NbOfFiles[i], Size: float64(h.Size[i])}
+	}
+	return bins
+}
+
+// Number and size of the objects last modified on a day, with their padding to the minimum billable size.
+type modifiedDay struct {
+	NbOfFiles int64 `json:"nbOfFiles"`
+	Size      int64 `json:"size"`
+	Padding   int64 `json:"padding,omitempty"`
+}
+
+// Number and size of the current objects under a prefix, including its sub-prefixes.
+type prefixUsage struct {
+	NbOfFiles        int64                    `json:"nbOfFiles"`
+	Size             int64                    `json:"size"`
+	StorageClassSize util.StorageClassSizeMap `json:"storageClassSize"`
+	LastModified     time.Time                `json:"lastModified"`
+}
+
+func (usage *prefixUsage) add(partial *prefixUsage) {
+	usage.NbOfFiles += partial.NbOfFiles
+	usage.Size += partial.Size
+	for k, v := range partial.StorageClassSize {
+		usage.StorageClassSize[k] += v
+	}
+	if usage.LastModified.Before(partial.LastModified) {
+		usage.LastModified = partial.LastModified
+	}
+}
+
+// Days since the epoch, the ages of the objects are counted in days.
+func toDay(date time.Time) int64 {
+	return int64(math.Floor(float64(date.Unix()) / 86400))
+}
+
+func newBucketStats() *bucketStats {
+	return &bucketStats{
+		storageClassSize:           make(util.StorageClassSizeMap),
+		noncurrentStorageClassSize: make(util.StorageClassSizeMap),
+		multipartStorageClassSize:  make(util.StorageClassSizeMap),
+		billableOverhead:           make(util.StorageClassSizeMap),
+		encryptionNbOfFiles:        make(util.StorageClassSizeMap),
+		encryptionSize:             make(util.StorageClassSizeMap),
+		sizeHistograms:             make(map[string]*histogram),
+		modifiedDays:               make(map[string]map[int64]*modifiedDay),
+		prefixes:                   make(map[string]*prefixUsage),
+		lifecycleCurrent:           make(lifecycleAges),
+		lifecycleNoncurrent:        make(lifecycleAges),
+	}
+}
+
+// Add a current object (or the latest version of an object) to the stats.
+func (stats *bucketStats) addObject(size int64, storageClass string, lastModified time.Time) {
+	stats.nbOfFiles += 1
+	stats.totalSize += size
+	stats.storageClassSize[storageClass] += float64(size)
+	addBillableOverhead(stats.billableOverhead, size, storageClass)
+	if stats.lastModified.Before(lastModified) {
+		stats.lastModified = lastModified
+	}
+	if stats.oldestModified.IsZero() || lastModified.Before(stats.oldestModified) {
+		stats.oldestModified = lastModified
+	}
+	stats.sizeHistogram(storageClass).add(getBin(util.SIZE_HISTOGRAM_BOUNDS, size), 1, size)
+	stats.addModifiedDay(storageClass, toDay(lastModified), 1, size, getPadding(size))
+}
+
+func (stats *bucketStats) sizeHistogram(storageClass string) *histogram {
+	h, ok := stats.sizeHistograms[storageClass]
+	if !ok {
+		h = newHistogram(len(util.SIZE_HISTOGRAM_BINS))
+		stats.sizeHistograms[storageClass] = h
+	}
+	return h
+}
+
+func (stats *bucketStats) addModifiedDay(storageClass string, day int64, nbOfFiles int64, size int64, padding int64) {
+	days, ok := stats.modifiedDays[storageClass]
+	if !ok {
+		days = make(map[int64]*modifiedDay)
+		stats.modifiedDays[storageClass] = days
+	}
+	modified, ok := days[day]
+	if !ok {
+		modified = &modifiedDay{}
+		days[day] = modified
+	}
+	modified.NbOfFiles += nbOfFiles
+	modified.Size += size
+	modified.Padding += padding
+}
+
+// Add a current object to the usage of its prefixes, up to the number of delimiters.
+func (stats *bucketStats) addPrefixes(key string, size int64, storageClass string, lastModified time.Time, depth int) {
+	end := 0
+	for i := 0; i < depth; i++ {
+		index := strings.Index(key[end:], util.PREFIX_DELIMITER)
+		if index < 0 {
+			return
+		}
+		end += index + len(util.PREFIX_DELIMITER)
+		stats.addPrefix(key[:end], &prefixUsage{NbOfFiles: 1, Size: size, StorageClassSize: util.StorageClassSizeMap{storageClass: float64(size)}, LastModified: lastModified})
+	}
+}
+
+func (stats *bucketStats) addPrefix(prefix string, partial *prefixUsage) {
+	usage, ok := stats.prefixes[prefix]
+	if !ok {
+		usage = &prefixUsage{StorageClassSize: make(util.StorageClassSizeMap)}
+		stats.prefixes[prefix] = usage
+	}
+	usage.add(partial)
+}
+
+func (stats *bucketStats) addLargestObject(object largestObject, limit int) {
+	if stats.largestObjects == nil {
+		stats.largestObjects = newLargestObjects(limit)
+	}
+	stats.largestObjects.add(object)
+}
+
+// Add a current object to the usage of its top-level prefix in the sketch of the largest prefixes.
+func (stats *bucketStats) addLargestPrefix(key string, size int64, storageClass string, lastModified time.Time, limit int) {
+	index := strings.Index(key, util.PREFIX_DELIMITER)
+	if index < 0 {
+		return
+	}
+	if stats.largestPrefixes == nil {
+		stats.largestPrefixes = newLargestPrefixes(limit)
+	}
+	usage := &prefixUsage{NbOfFiles: 1, Size: size, StorageClassSize: util.StorageClassSizeMap{storageClass: float64(size)}, LastModified: lastModified}
+	stats.largestPrefixes.add(key[:index+len(util.PREFIX_DELIMITER)], usage, 0)
+}
+
+// Tree of the prefixes, the sub-prefixes are sorted by size, the largest first.
+func (stats *bucketStats) prefixTree() []*util.PrefixUsage {
+	nodes := make(map[string]*util.PrefixUsage)
+	for prefix, usage := range stats.prefixes {
+		nodes[prefix] = &util.PrefixUsage{
+			Prefix:           prefix,
+			NbOfFiles:        usage.NbOfFiles,
+			Size:             float64(usage.Size),
+			StorageClassSize: maps.Clone(usage.StorageClassSize),
+			LastModified:     usage.LastModified,
+		}
+	}
+	roots := []*util.PrefixUsage{}
+	for prefix, node := range nodes {
+		parent := prefix[:strings.LastIndex(strings.TrimSuffix(prefix, util.PREFIX_DELIMITER), util.PREFIX_DELIMITER)+1]
+		if parentNode, ok := nodes[parent]; ok && parent != "" {
+			parentNode.Prefixes = append(parentNode.Prefixes, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	for _, node := range nodes {
+		util.SortPrefixes(node.Prefixes)
+	}
+	util.SortPrefixes(roots)
+	return roots
+}
+
+// Size of the current objects last modified at least the number of days ago.
+func (stats *bucketStats) sizeOlderThan(now time.Time, nbOfDays int64) int64 {
+	var size int64
+	for _, days := range stats.modifiedDays {
+		for day, modified := range days {
+			if toDay(now)-day >= nbOfDays {
+				size += modified.Size
+			}
+		}
+	}
+	return size
+}
+
+// Day of last modification of the median object, by number of objects.
+func (stats *bucketStats) medianModified() time.Time {
+	nbOfFiles := make(map[int64]int64)
+	var total int64
+	for _, days := range stats.modifiedDays {
+		for day, modified := range days {
+			nbOfFiles[day] += modified.NbOfFiles
+			total += modified.NbOfFiles
+		}
+	}
+	if total == 0 {
+		return time.Time{}
+	}
+	days := make([]int64, 0, len(nbOfFiles))
+	for day := range nbOfFiles {
+		days = append(days, day)
+	}
+	slices.Sort(days)
+	var count int64
+	for _, day := range days {
+		count += nbOfFiles[day]
+		if 2*count >= total {
+			return time.Unix(day*86400, 0).UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// Histograms of the ages of the current objects, for the bucket and by storage class.
+func (stats *bucketStats) ageHistograms(now time.Time) ([]util.HistogramBin, map[string][]util.HistogramBin) {
+	total := newHistogram(len(util.AGE_HISTOGRAM_BINS))
+	storageClass := make(map[string][]util.HistogramBin)
+	for k, days := range stats.modifiedDays {
+		h := newHistogram(len(util.AGE_HISTOGRAM_BINS))
+		for day, modified := range days {
+			h.add(getBin(util.AGE_HISTOGRAM_BOUNDS, toDay(now)-day), modified.NbOfFiles, modified.Size)
+		}
+		total.merge(h)
+		storageClass[k] = h.bins(util.AGE_HISTOGRAM_BINS)
+	}
+	return total.bins(util.AGE_HISTOGRAM_BINS), storageClass
+}
+
+// Add a noncurrent version of an object to the stats.
+func (stats *bucketStats) addNoncurrentVersion(size int64, storageClass string) {
+	stats.nbOfNoncurrentFiles += 1
+	stats.noncurrentSize += size
+	stats.noncurrentStorageClassSize[storageClass] += float64(size)
+	addBillableOverhead(stats.billableOverhead, size, storageClass)
+}
+
+func (stats *bucketStats) addDeleteMarker() {
+	stats.nbOfDeleteMarkers += 1
+}
+
+// Add an incomplete multipart upload with the total size of its parts to the stats.
+func (stats *bucketStats) addMultipartUpload(size int64, storageClass string, initiated time.Time) {
+	stats.nbOfMultipartUploads += 1
+	stats.multipartSize += size
+	stats.multipartStorageClassSize[storageClass] += float64(size)
+	if stats.oldestMultipartUpload.IsZero() || initiated.Before(stats.oldestMultipartUpload) {
+		stats.oldestMultipartUpload = initiated
+	}
+}
+
+// Add the encryption of a current object. An object sampled with a ratio of 1/n counts for n objects.
+func (stats *bucketStats) addObjectEncryption(encryption string, size int64, weight float64) {
+	stats.encryptionNbOfFiles[encryption] += weight
+	stats.encryptionSize[encryption] += float64(size) * weight
+}
+
+// Merge the partial stats of a part of the bucket.
+func (stats *bucketStats) merge(partial *bucketStats) {
+	stats.nbOfFiles += partial.nbOfFiles
+	stats.totalSize += partial.totalSize
+	for k, v := range partial.storageClassSize {
+		stats.storageClassSize[k] += v
+	}
+	if stats.lastModified.Before(partial.lastModified) {
+		stats.lastModified = partial.lastModified
+	}
+	stats.nbOfNoncurrentFiles += partial.nbOfNoncurrentFiles
+	stats.noncurrentSize += partial.noncurrentSize
+	for k, v := range partial.noncurrentStorageClassSize {
+		stats.noncurrentStorageClassSize[k] += v
+	}
+	stats.nbOfDeleteMarkers += partial.nbOfDeleteMarkers
+	stats.nbOfMultipartUploads += partial.nbOfMultipartUploads
+	stats.multipartSize += partial.multipartSize
+	for k, v := range partial.multipartStorageClassSize {
+		stats.multipartStorageClassSize[k] += v
+	}
+	if !partial.oldestMultipartUpload.IsZero() && (stats.oldestMultipartUpload.IsZero() || partial.oldestMultipartUpload.Before(stats.oldestMultipartUpload)) {
+		stats.oldestMultipartUpload = partial.oldestMultipartUpload
+	}
+	for k, v := range partial.billableOverhead {
+		stats.billableOverhead[k] += v
+	}
+	for k, v := range partial.encryptionNbOfFiles {
+		stats.encryptionNbOfFiles[k] += v
+	}
+	for k, v := range partial.encryptionSize {
+		stats.encryptionSize[k] += v
+	}
+	for k, v := range partial.sizeHistograms {
+		stats.sizeHistogram(k).merge(v)
+	}
+	for k, days := range partial.modifiedDays {
+		for day, modified := range days {
+			stats.addModifiedDay(k, day, modified.NbOfFiles, modified.Size, modified.Padding)
+		}
+	}
+	if stats.oldestModified.IsZero() || (!partial.oldestModified.IsZero() && partial.oldestModified.Before(stats.oldestModified)) {
+		stats.oldestModified = partial.oldestModified
+	}
+	for k, v := range partial.prefixes {
+		stats.addPrefix(k, v)
+	}
+	if partial.largestObjects != nil {
+		if stats.largestObjects == nil {
+			stats.largestObjects = newLargestObjects(partial.largestObjects.Limit)
+		}
+		stats.largestObjects.merge(partial.largestObjects)
+	}
+	if partial.largestPrefixes != nil {
+		if stats.largestPrefixes == nil {
+			stats.largestPrefixes = newLargestPrefixes(partial.largestPrefixes.Limit)
+		}
+		stats.largestPrefixes.merge(partial.largestPrefixes)
+	}
+	stats.lifecycleCurrent.merge(partial.lifecycleCurrent)
+	stats.lifecycleNoncurrent.merge(partial.lifecycleNoncurrent)
+}
+
+func (stats *bucketStats) copy() *bucketStats {
+	clone := newBucketStats()
+	clone.merge(stats)
+	return clone
+}
+
+// Form of the stats saved in the state file of a scan.
+type bucketStatsState struct {
+	NbOfFiles                  int64                             `json:"nbOfFiles"`
+	TotalSize                  int64                             `json:"totalSize"`
+	StorageClassSize           util.StorageClassSizeMap          `json:"storageClassSize,omitempty"`
+	LastModified               time.Time                         `json:"lastModified"`
+	NbOfNoncurrentFiles        int64                             `json:"nbOfNoncurrentFiles,omitempty"`
+	NoncurrentSize             int64                             `json:"noncurrentSize,omitempty"`
+	NoncurrentStorageClassSize util.StorageClassSizeMap          `json:"noncurrentStorageClassSize,omitempty"`
+	NbOfDeleteMarkers          int64                             `json:"nbOfDeleteMarkers,omitempty"`
+	NbOfMultipartUploads       int64                             `json:"nbOfMultipartUploads,omitempty"`
+	MultipartSize              int64                             `json:"multipartSize,omitempty"`
+	MultipartStorageClassSize  util.StorageClassSizeMap          `json:"multipartStorageClassSize,omitempty"`
+	OldestMultipartUpload      time.Time                         `json:"oldestMultipartUpload"`
+	BillableOverhead           util.StorageClassSizeMap          `json:"billableOverhead,omitempty"`
+	EncryptionNbOfFiles        util.StorageClassSizeMap          `json:"encryptionNbOfFiles,omitempty"`
+	EncryptionSize             util.StorageClassSizeMap          `json:"encryptionSize,omitempty"`
+	SizeHistograms             map[string]*histogram             `json:"sizeHistograms,omitempty"`
+	ModifiedDays               map[string]map[int64]*modifiedDay `json:"modifiedDays,omitempty"`
+	OldestModified             time.Time                         `json:"oldestModified"`
+	Prefixes                   map[string]*prefixUsage           `json:"prefixes,omitempty"`
+	LargestObjects             *largestObjects                   `json:"largestObjects,omitempty"`
+	LargestPrefixes            *largestPrefixes                  `json:"largestPrefixes,omitempty"`
+	LifecycleCurrent           lifecycleAges                     `json:"lifecycleCurrent,omitempty"`
+	LifecycleNoncurrent        lifecycleAges                     `json:"lifecycleNoncurrent,omitempty"`
+}
+
+func (stats *bucketStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bucketStatsState{
+		NbOfFiles:                  stats.nbOfFiles,
+		TotalSize:                  stats.totalSize,
+		StorageClassSize:           stats.storageClassSize,
+		LastModified:               stats.lastModified,
+		NbOfNoncurrentFiles:        stats.nbOfNoncurrentFiles,
+		NoncurrentSize:             stats.noncurrentSize,
+		NoncurrentStorageClassSize: stats.noncurrentStorageClassSize,
+		NbOfDeleteMarkers:          stats.nbOfDeleteMarkers,
+		NbOfMultipartUploads:       stats.nbOfMultipartUploads,
+		MultipartSize:              stats.multipartSize,
+		MultipartStorageClassSize:  stats.multipartStorageClassSize,
+		OldestMultipartUpload:      stats.oldestMultipartUpload,
+		BillableOverhead:           stats.billableOverhead,
+		EncryptionNbOfFiles:        stats.encryptionNbOfFiles,
+		EncryptionSize:             stats.encryptionSize,
+		SizeHistograms:             stats.sizeHistograms,
+		ModifiedDays:               stats.modifiedDays,
+		OldestModified:             stats.oldestModified,
+		Prefixes:                   stats.prefixes,
+		LargestObjects:             stats.largestObjects,
+		LargestPrefixes:            stats.largestPrefixes,
+		LifecycleCurrent:           stats.lifecycleCurrent,
+		LifecycleNoncurrent:        stats.lifecycleNoncurrent,
+	})
+}
+
+func (stats *bucketStats) UnmarshalJSON(data []byte) error {
+	var state bucketStatsState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	*stats = *newBucketStats()
+	stats.nbOfFiles = state.NbOfFiles
+	stats.totalSize = state.TotalSize
+	stats.lastModified = state.LastModified
+	stats.nbOfNoncurrentFiles = state.NbOfNoncurrentFiles
+	stats.noncurrentSize = state.NoncurrentSize
+	stats.nbOfDeleteMarkers = state.NbOfDeleteMarkers
+	stats.nbOfMultipartUploads = state.NbOfMultipartUploads
+	stats.multipartSize = state.MultipartSize
+	stats.oldestMultipartUpload = state.OldestMultipartUpload
+	stats.oldestModified = state.OldestModified
+	for k, v := range state.StorageClassSize {
+		stats.storageClassSize[k] = v
+	}
+	for k, v := range state.NoncurrentStorageClassSize {
+		stats.noncurrentStorageClassSize[k] = v
+	}
+	for k, v := range state.MultipartStorageClassSize {
+		stats.multipartStorageClassSize[k] = v
+	}
+	for k, v := range state.BillableOverhead {
+		stats.billableOverhead[k] = v
+	}
+	for k, v := range state.EncryptionNbOfFiles {
+		stats.encryptionNbOfFiles[k] = v
+	}
+	for k, v := range state.EncryptionSize {
+		stats.encryptionSize[k] = v
+	}
+	for k, v := range state.SizeHistograms {
+		if v != nil && len(v.NbOfFiles) == len(util.SIZE_HISTOGRAM_BINS) && len(v.Size) == len(util.SIZE_HISTOGRAM_BINS) {
+			stats.sizeHistograms[k] = v
+		}
+	}
+	for k, days := range state.ModifiedDays {
+		for day, modified := range days {
+			if modified != nil {
+				stats.addModifiedDay(k, day, modified.NbOfFiles, modified.Size, modified.Padding)
+			}
+		}
+	}
+	for k, v := range state.Prefixes {
+		if v != nil {
+			stats.addPrefix(k, v)
+		}
+	}
+	if state.LargestObjects != nil {
+		stats.largestObjects = newLargestObjects(state.LargestObjects.Limit)
+		stats.largestObjects.merge(state.LargestObjects)
+	}
+	if state.LargestPrefixes != nil {
+		stats.largestPrefixes = newLargestPrefixes(state.LargestPrefixes.Limit)
+		stats.largestPrefixes.merge(state.LargestPrefixes)
+	}
+	stats.lifecycleCurrent.merge(state.LifecycleCurrent)
+	stats.lifecycleNoncurrent.merge(state.LifecycleNoncurrent)
+	return nil
+}
+
+// Set the metadata of the bucket with the stats gathered.
+func (stats *bucketStats) applyTo(bucket util.CloudFilesystem) {
+	lastModified := stats.lastModified
+	if !lastModified.IsZero() {
+		loc, _ := time.LoadLocation("Local")
+		lastModified = lastModified.In(loc)
+	}
+	bucket.SetNbOfFiles(stats.nbOfFiles)
+	bucket.SetSizeOfBucket(float64(stats.totalSize))
+	bucket.SetStorageClass(stats.storageClassSize)
+	bucket.SetLastUpdateDate(lastModified)
+	bucket.SetNoncurrentVersions(stats.nbOfNoncurrentFiles, float64(stats.noncurrentSize), stats.noncurrentStorageClassSize)
+	bucket.SetNbOfDeleteMarkers(stats.nbOfDeleteMarkers)
+	var oldestUploadAge int64
+	if !stats.oldestMultipartUpload.IsZero() {
+		oldestUploadAge = int64(time.Since(stats.oldestMultipartUpload).Hours() / 24)
+	}
+	bucket.SetMultipartUploads(stats.nbOfMultipartUploads, float64(stats.multipartSize), stats.multipartStorageClassSize, oldestUploadAge)
+	billable := stats.billableStorageClassSize()
+	var billableSize float64
+	for _, v := range billable {
+		billableSize += v
+	}
+	bucket.SetBillableSize(billableSize, billable)
+	if minimumDurationSize := stats.minimumDurationSize(time.Now()); len(minimumDurationSize) != 0 {
+		bucket.SetMinimumDurationSize(minimumDurationSize)
+	}
+	if len(stats.sizeHistograms) != 0 {
+		total := newHistogram(len(util.SIZE_HISTOGRAM_BINS))
+		storageClass := make(map[string][]util.HistogramBin)
+		for k, v := range stats.sizeHistograms {
+			total.merge(v)
+			storageClass[k] = v.bins(util.SIZE_HISTOGRAM_BINS)
+		}
+		bucket.SetSizeHistogram(total.bins(util.SIZE_HISTOGRAM_BINS), storageClass)
+	}
+	if len(stats.modifiedDays) != 0 {
+		oldestModified := stats.oldestModified
+		if !oldestModified.IsZero() {
+			loc, _ := time.LoadLocation("Local")
+			oldestModified = oldestModified.In(loc)
+		}
+		total, storageClass := stats.ageHistograms(time.Now())
+		bucket.SetObjectAges(oldestModified, stats.medianModified(), total, storageClass)
+	}
+	if len(stats.encryptionNbOfFiles) != 0 {
+		nbOfFiles := make(map[string]int64)
+		for k, v := range stats.encryptionNbOfFiles {
+			nbOfFiles[k] = int64(math.Round(v))
+		}
+		bucket.SetObjectEncryption(nbOfFiles, stats.encryptionSize)
+	}
+}
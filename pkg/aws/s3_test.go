@@ -1,10 +1,16 @@
 package aws
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
 	"projet-devops-coveo/pkg/util"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,6 +22,7 @@ func TestFilterbucket(t *testing.T) {
 		expectedOutput     util.CloudFilesystem
 		filterByName       []string
 		regions            []string
+		allRegions         bool
 		bucketLocation     string
 		bucketName         string
 		bucketCreationDate time.Time
@@ -64,11 +71,26 @@ func TestFilterbucket(t *testing.T) {
 				Region:       "us-east-1",
 			},
 		},
+		{
+			name:               "Test All Regions",
+			filterByName:       []string{"poc-1", "poc-3"},
+			regions:            []string{"ca-central-1"},
+			allRegions:         true,
+			bucketLocation:     "eu-west-1",
+			bucketName:         "poc-3",
+			bucketCreationDate: timeMock,
+			expectedOutput: &util.BucketDTO{
+				Name:         "poc-3",
+				CreationDate: timeMock,
+				Region:       "eu-west-1",
+			},
+		},
 	}
 	for _, test := range tests {
 		fs := &S3{
 			options: util.CliOptions{
 				Regions:      test.regions,
+				AllRegions:   test.allRegions,
 				FilterByName: test.filterByName,
 			},
 		}
@@ -106,8 +128,9 @@ func TestSetBucketCost(t *testing.T) {
 					StorageClassSize: util.StorageClassSizeMap{
 						"STANDARD": float64(50000000),
 					},
-					Region: "ca-central-1",
-					Cost:   0.011641532182693481,
+					Region:        "ca-central-1",
+					Cost:          0.011641532182693481,
+					CostBreakdown: util.CostBreakdown{Storage: 0.011641532182693481},
 				},
 			},
 		},
@@ -141,8 +164,9 @@ func TestSetBucketCost(t *testing.T) {
 					StorageClassSize: util.StorageClassSizeMap{
 						"STANDARD": float64(50000000),
 					},
-					Region: "ca-central-1",
-					Cost:   0.011641532182693481,
+					Region:        "ca-central-1",
+					Cost:          0.011641532182693481,
+					CostBreakdown: util.CostBreakdown{Storage: 0.011641532182693481},
 				},
 				&util.BucketDTO{
 					Name:         "Poc-2",
@@ -150,8 +174,9 @@ func TestSetBucketCost(t *testing.T) {
 					StorageClassSize: util.StorageClassSizeMap{
 						"STANDARD": float64(5000033),
 					},
-					Region: "ca-central-1",
-					Cost:   0.0011641609016805887,
+					Region:        "ca-central-1",
+					Cost:          0.0011641609016805887,
+					CostBreakdown: util.CostBreakdown{Storage: 0.0011641609016805887},
 				},
 			},
 		},
@@ -177,3 +202,377 @@ func TestSetBucketCost(t *testing.T) {
 		assert.Equal(t, test.expectedOutput, test.buckets)
 	}
 }
+
+// A storage class with only empty objects, like folder markers, has no price per GB.
+func TestSetBucketCostWithEmptyStorageClass(t *testing.T) {
+	fs := &S3{
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": {S3_STORAGE_CLASS_STANDARD: 50000000, S3_STORAGE_CLASS_GLACIER: 0}},
+		},
+	}
+	bucket := &util.BucketDTO{
+		Name:             "poc-1",
+		Region:           "ca-central-1",
+		StorageClassSize: util.StorageClassSizeMap{S3_STORAGE_CLASS_STANDARD: 50000000, S3_STORAGE_CLASS_GLACIER: 0},
+	}
+	assert.Empty(t, fs.SetBucketCost([]util.CloudFilesystem{bucket}, MasterPriceList{"ca-central-1": MockProductPriceList}))
+	assert.Equal(t, 0.011641532182693481, bucket.Cost)
+	_, err := json.Marshal(bucket)
+	assert.Nil(t, err)
+}
+
+func TestFetchBucketVersions(t *testing.T) {
+	mock := &AwsClientMock{
+		ObjectVersions: map[string][]types.ObjectVersion{
+			"poc-1": {
+				{Key: aws.String("a.txt"), IsLatest: aws.Bool(true), Size: aws.Int64(100), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("a.txt"), IsLatest: aws.Bool(false), Size: aws.Int64(80), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("a.txt"), IsLatest: aws.Bool(false), Size: aws.Int64(60), StorageClass: "GLACIER", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("b.txt"), IsLatest: aws.Bool(false), Size: aws.Int64(40), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+			},
+		},
+		DeleteMarkers: map[string][]types.DeleteMarkerEntry{
+			"poc-1": {
+				{Key: aws.String("b.txt"), IsLatest: aws.Bool(true)},
+			},
+		},
+		PageSize: 2,
+	}
+	fs := &S3{
+		session: mock,
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": map[string]float64{}},
+		},
+		options: util.CliOptions{IncludeVersions: true, Threading: 1},
+		region:  "ca-central-1",
+	}
+	bucket := &util.BucketDTO{Name: "poc-1", Region: "ca-central-1"}
+	buckets := fs.GetObject(context.Background(), []util.CloudFilesystem{bucket}, nil)
+
+	assert.Equal(t, 1, len(buckets))
+	assert.Equal(t, int64(1), bucket.NbOfFiles)
+	assert.Equal(t, float64(100), bucket.SizeOfBucket)
+	assert.Equal(t, int64(3), bucket.NbOfNoncurrentFiles)
+	assert.Equal(t, float64(180), bucket.NoncurrentSize)
+	assert.Equal(t, util.StorageClassSizeMap{"STANDARD": 120, "GLACIER": 60}, bucket.NoncurrentStorageClassSize)
+	assert.Equal(t, int64(1), bucket.NbOfDeleteMarkers)
+	// The region is billed for the metadata of the archived version
+	assert.Equal(t, map[string]float64{"STANDARD": 220 + 8<<10, "GLACIER": 60 + 32<<10}, fs.totalStorageClassSize.SizeMap["ca-central-1"])
+}
+
+func TestSetBucketCostWithNoncurrentVersions(t *testing.T) {
+	fs := &S3{
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{
+				"ca-central-1": map[string]float64{
+					S3_STORAGE_CLASS_STANDARD: 2 * 1024 * 1024 * 1024,
+				},
+			},
+		},
+		region: "ca-central-1",
+	}
+	bucket := &util.BucketDTO{
+		Name:                       "Poc-1",
+		StorageClassSize:           util.StorageClassSizeMap{S3_STORAGE_CLASS_STANDARD: 1024 * 1024 * 1024},
+		NoncurrentStorageClassSize: util.StorageClassSizeMap{S3_STORAGE_CLASS_STANDARD: 1024 * 1024 * 1024},
+		Region:                     "ca-central-1",
+	}
+	fs.SetBucketCost([]util.CloudFilesystem{bucket}, MasterPriceList{"ca-central-1": MockProductPriceList})
+	assert.Equal(t, 0.5, bucket.Cost)
+}
+
+func TestFetchBucketMultipartUploads(t *testing.T) {
+	initiated := time.Now().Add(-72 * time.Hour)
+	mock := &AwsClientMock{
+		Objects: map[string][]types.Object{
+			"poc-1": {
+				{Key: aws.String("a.txt"), Size: aws.Int64(100), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+			},
+		},
+		Uploads: map[string][]types.MultipartUpload{
+			"poc-1": {
+				{Key: aws.String("big.bin"), UploadId: aws.String("upload-1"), StorageClass: "STANDARD", Initiated: aws.Time(initiated)},
+				{Key: aws.String("other.bin"), UploadId: aws.String("upload-2"), StorageClass: "GLACIER", Initiated: aws.Time(time.Now())},
+			},
+		},
+		Parts: map[string][]types.Part{
+			"upload-1": {{Size: aws.Int64(500)}, {Size: aws.Int64(500)}, {Size: aws.Int64(200)}},
+			"upload-2": {{Size: aws.Int64(300)}},
+		},
+		PageSize: 2,
+	}
+	fs := &S3{
+		session: mock,
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": map[string]float64{}},
+		},
+		options: util.CliOptions{IncludeMultipartUploads: true, Threading: 1},
+		region:  "ca-central-1",
+	}
+	bucket := &util.BucketDTO{Name: "poc-1", Region: "ca-central-1"}
+	fs.GetObject(context.Background(), []util.CloudFilesystem{bucket}, nil)
+
+	assert.Equal(t, int64(1), bucket.NbOfFiles)
+	assert.Equal(t, int64(2), bucket.NbOfMultipartUploads)
+	assert.Equal(t, float64(1500), bucket.MultipartUploadsSize)
+	assert.Equal(t, util.StorageClassSizeMap{"STANDARD": 1200, "GLACIER": 300}, bucket.MultipartStorageClassSize)
+	assert.Equal(t, int64(3), bucket.OldestMultipartUploadAge)
+	assert.Equal(t, map[string]float64{"STANDARD": 1300, "GLACIER": 300}, fs.totalStorageClassSize.SizeMap["ca-central-1"])
+}
+
+func TestFetchBucketPartitioned(t *testing.T) {
+	mock := &AwsClientMock{
+		Objects: map[string][]types.Object{
+			"poc-1": {
+				{Key: aws.String("root.txt"), Size: aws.Int64(1), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("logs/2024/a.log"), Size: aws.Int64(10), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("logs/2024/b.log"), Size: aws.Int64(20), StorageClass: "GLACIER", LastModified: aws.Time(timeMock.Add(time.Hour))},
+				{Key: aws.String("logs/c.log"), Size: aws.Int64(30), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("data/d.bin"), Size: aws.Int64(40), StorageClass: "STANDARD_IA", LastModified: aws.Time(timeMock)},
+			},
+		},
+		PageSize: 2,
+	}
+	for _, depth := range []int{0, 1, 2, 3} {
+		fs := &S3{
+			session: mock,
+			totalStorageClassSize: &util.StorageClassSize{
+				SizeMap: util.RegionsStorageMap{"ca-central-1": map[string]float64{}},
+			},
+			options: util.CliOptions{PartitionDepth: depth, Threading: 2},
+			region:  "ca-central-1",
+		}
+		bucket := &util.BucketDTO{Name: "poc-1", Region: "ca-central-1"}
+		fs.GetObject(context.Background(), []util.CloudFilesystem{bucket}, nil)
+
+		assert.Equal(t, int64(5), bucket.NbOfFiles, depth)
+		assert.Equal(t, float64(101), bucket.SizeOfBucket, depth)
+		assert.Equal(t, util.StorageClassSizeMap{"STANDARD": 41, "GLACIER": 20, "STANDARD_IA": 40}, bucket.StorageClassSize, depth)
+		assert.True(t, timeMock.Add(time.Hour).Equal(bucket.LastUpdateDate), depth)
+	}
+}
+
+func TestSizeHistogram(t *testing.T) {
+	stats := newBucketStats()
+	for _, size := range []int64{0, 1023, 1024, 128 << 10, 5<<30 - 1, 5 << 30} {
+		stats.addObject(size, "STANDARD", timeMock)
+	}
+	partial := newBucketStats()
+	partial.addObject(100<<20, "GLACIER", timeMock)
+	partial.addObject(10, "STANDARD", timeMock)
+	stats.merge(partial)
+
+	// The histograms are saved in the checkpoint
+	data, err := json.Marshal(stats)
+	assert.Nil(t, err)
+	loaded := newBucketStats()
+	assert.Nil(t, json.Unmarshal(data, loaded))
+
+	bucket := &util.BucketDTO{}
+	loaded.applyTo(bucket)
+	counts := func(bins []util.HistogramBin) []int64 {
+		output := []int64{}
+		for i, bin := range bins {
+			assert.Equal(t, util.SIZE_HISTOGRAM_BINS[i], bin.Bin)
+			output = append(output, bin.NbOfFiles)
+		}
+		return output
+	}
+	assert.Equal(t, []int64{3, 1, 1, 0, 1, 0, 1, 1}, counts(bucket.SizeHistogram))
+	assert.Equal(t, []int64{3, 1, 1, 0, 0, 0, 1, 1}, counts(bucket.StorageClassSizeHistogram["STANDARD"]))
+	assert.Equal(t, []int64{0, 0, 0, 0, 1, 0, 0, 0}, counts(bucket.StorageClassSizeHistogram["GLACIER"]))
+	assert.Equal(t, float64(1033), bucket.SizeHistogram[0].Size)
+	assert.Equal(t, float64(100<<20), bucket.StorageClassSizeHistogram["GLACIER"][4].Size)
+
+	bucket.ApplySizeConversion(util.SIZE_CONV_KB)
+	assert.Equal(t, float64(100<<10), bucket.StorageClassSizeHistogram["GLACIER"][4].Size)
+}
+
+func TestObjectAges(t *testing.T) {
+	now := time.Now()
+	stats := newBucketStats()
+	stats.addObject(10, "STANDARD", now)
+	stats.addObject(20, "STANDARD", now.AddDate(0, 0, -40))
+	partial := newBucketStats()
+	partial.addObject(30, "GLACIER", now.AddDate(0, 0, -100))
+	partial.addObject(40, "GLACIER", now.AddDate(-2, 0, 0))
+	partial.addObject(50, "GLACIER", now.AddDate(-3, 0, 0))
+	stats.merge(partial)
+
+	data, err := json.Marshal(stats)
+	assert.Nil(t, err)
+	loaded := newBucketStats()
+	assert.Nil(t, json.Unmarshal(data, loaded))
+
+	assert.Equal(t, int64(120), loaded.sizeOlderThan(now, 90))
+	assert.Equal(t, int64(0), loaded.sizeOlderThan(now, 5*365))
+	bucket := &util.BucketDTO{}
+	loaded.applyTo(bucket)
+	assert.True(t, now.AddDate(-3, 0, 0).Equal(bucket.OldestUpdateDate))
+	assert.Equal(t, time.Unix(toDay(now.AddDate(0, 0, -100))*86400, 0).UTC(), bucket.MedianUpdateDate)
+	sizes := func(bins []util.HistogramBin) []float64 {
+		output := []float64{}
+		for i, bin := range bins {
+			assert.Equal(t, util.AGE_HISTOGRAM_BINS[i], bin.Bin)
+			output = append(output, bin.Size)
+		}
+		return output
+	}
+	assert.Equal(t, []float64{10, 20, 30, 90}, sizes(bucket.AgeHistogram))
+	assert.Equal(t, []float64{10, 20, 0, 0}, sizes(bucket.StorageClassAgeHistogram["STANDARD"]))
+	assert.Equal(t, []float64{0, 0, 30, 90}, sizes(bucket.StorageClassAgeHistogram["GLACIER"]))
+	assert.Equal(t, int64(2), bucket.AgeHistogram[3].NbOfFiles)
+}
+
+func TestFetchBucketPrefixes(t *testing.T) {
+	mock := &AwsClientMock{
+		Objects: map[string][]types.Object{
+			"poc-1": {
+				{Key: aws.String("root.txt"), Size: aws.Int64(1), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("logs/2024/a.log"), Size: aws.Int64(10), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("logs/2024/01/b.log"), Size: aws.Int64(20), StorageClass: "GLACIER", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("logs/c.log"), Size: aws.Int64(30), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("data/d.bin"), Size: aws.Int64(40), StorageClass: "STANDARD_IA", LastModified: aws.Time(timeMock)},
+			},
+		},
+		PageSize: 2,
+	}
+	for _, partitionDepth := range []int{0, 2} {
+		fs := &S3{
+			session: mock,
+			totalStorageClassSize: &util.StorageClassSize{
+				SizeMap: util.RegionsStorageMap{"ca-central-1": map[string]float64{}},
+			},
+			options: util.CliOptions{PrefixDepth: 2, PartitionDepth: partitionDepth, Threading: 2},
+			region:  "ca-central-1",
+		}
+		bucket := &util.BucketDTO{Name: "poc-1", Region: "ca-central-1"}
+		fs.GetObject(context.Background(), []util.CloudFilesystem{bucket}, nil)
+
+		var checkLastModified func(prefixes []*util.PrefixUsage)
+		checkLastModified = func(prefixes []*util.PrefixUsage) {
+			for _, prefix := range prefixes {
+				assert.True(t, timeMock.Equal(prefix.LastModified), prefix.Prefix)
+				prefix.LastModified = time.Time{}
+				checkLastModified(prefix.Prefixes)
+			}
+		}
+		checkLastModified(bucket.Prefixes)
+		assert.Equal(t, []*util.PrefixUsage{
+			{
+				Prefix: "logs/", NbOfFiles: 3, Size: 60, StorageClassSize: util.StorageClassSizeMap{"STANDARD": 40, "GLACIER": 20},
+				Prefixes: []*util.PrefixUsage{
+					{Prefix: "logs/2024/", NbOfFiles: 2, Size: 30, StorageClassSize: util.StorageClassSizeMap{"STANDARD": 10, "GLACIER": 20}},
+				},
+			},
+			{Prefix: "data/", NbOfFiles: 1, Size: 40, StorageClassSize: util.StorageClassSizeMap{"STANDARD_IA": 40}},
+		}, bucket.Prefixes, partitionDepth)
+	}
+
+	// The prefixes are saved in the checkpoint
+	stats := newBucketStats()
+	stats.addPrefixes("a/b/c/d.txt", 10, "STANDARD", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), 2)
+	data, err := json.Marshal(stats)
+	assert.Nil(t, err)
+	loaded := newBucketStats()
+	assert.Nil(t, json.Unmarshal(data, loaded))
+	assert.Equal(t, stats.prefixes, loaded.prefixes)
+
+	prefixes := loaded.prefixTree()
+	setPrefixesCost(prefixes, map[string]float64{"STANDARD": 2})
+	assert.Equal(t, TransformSizeToGB(10)*2, prefixes[0].Cost)
+	assert.Equal(t, TransformSizeToGB(10)*2, prefixes[0].Prefixes[0].Cost)
+}
+
+func TestFetchBucketCancelled(t *testing.T) {
+	mock := &AwsClientMock{
+		Objects: map[string][]types.Object{
+			"poc-1": {
+				{Key: aws.String("a/a.txt"), Size: aws.Int64(1), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+				{Key: aws.String("b/b.txt"), Size: aws.Int64(2), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+			},
+		},
+	}
+	checkpoint, err := LoadCheckpoint(filepath.Join(t.TempDir(), "state.json"), util.CliOptions{})
+	assert.Nil(t, err)
+	fs := &S3{
+		session: mock,
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": map[string]float64{}},
+		},
+		options:    util.CliOptions{PartitionDepth: 1, Threading: 2, IncludeMultipartUploads: true},
+		region:     "ca-central-1",
+		checkpoint: checkpoint,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bucket := &util.BucketDTO{Name: "poc-1", Region: "ca-central-1"}
+	buckets := fs.GetObject(ctx, []util.CloudFilesystem{bucket}, nil)
+
+	assert.Equal(t, 1, len(buckets))
+	assert.Equal(t, util.BUCKET_STATUS_INCOMPLETE, bucket.Status)
+	assert.Equal(t, int64(0), bucket.NbOfFiles)
+	// The bucket is scanned again when the scan is resumed
+	assert.Equal(t, 0, checkpoint.NbOfBucketsDone())
+
+	bucket = &util.BucketDTO{Name: "poc-1", Region: "ca-central-1"}
+	fs.GetObject(context.Background(), []util.CloudFilesystem{bucket}, nil)
+	assert.Equal(t, util.BUCKET_STATUS_COMPLETE, bucket.Status)
+	assert.Equal(t, int64(2), bucket.NbOfFiles)
+	assert.Equal(t, 1, checkpoint.NbOfBucketsDone())
+}
+
+func TestFilterBucketsLocationError(t *testing.T) {
+	mock := &AwsClientMock{
+		Buckets: []types.Bucket{
+			{Name: aws.String("poc-1"), CreationDate: aws.Time(timeMock)},
+			{Name: aws.String("poc-2"), CreationDate: aws.Time(timeMock)},
+			{Name: aws.String("poc-3"), CreationDate: aws.Time(timeMock)},
+		},
+		BucketLocations: map[string]string{"poc-1": "ca-central-1"},
+	}
+	fs := &S3{
+		session: mock,
+		options: util.CliOptions{Regions: []string{"ca-central-1"}, FilterByName: []string{"poc-1", "poc-2"}},
+	}
+	buckets, err := fs.GetBucketsFiltered(context.Background(), nil)
+	assert.Nil(t, err)
+
+	// poc-2 is kept as failed since its location is unknown, poc-3 is filtered by name
+	assert.Equal(t, 2, len(buckets))
+	assert.Equal(t, "poc-1", buckets[0].GetName())
+	assert.Equal(t, "", buckets[0].GetStatus())
+	assert.Equal(t, "poc-2", buckets[1].GetName())
+	assert.Equal(t, "", buckets[1].GetRegion())
+	assert.Equal(t, util.BUCKET_STATUS_FAILED, buckets[1].GetStatus())
+	assert.Equal(t, "NoSuchBucket: poc-2", buckets[1].GetError())
+}
+
+func TestFetchBucketFailed(t *testing.T) {
+	mock := &AwsClientMock{
+		Objects: map[string][]types.Object{
+			"poc-1": {
+				{Key: aws.String("a.txt"), Size: aws.Int64(1), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+			},
+		},
+		ListErrors: map[string]error{"poc-2": fmt.Errorf("AccessDenied: poc-2")},
+	}
+	fs := &S3{
+		session: mock,
+		totalStorageClassSize: &util.StorageClassSize{
+			SizeMap: util.RegionsStorageMap{"ca-central-1": map[string]float64{}},
+		},
+		options: util.CliOptions{PartitionDepth: 1, Threading: 2, OmitEmpty: true},
+		region:  "ca-central-1",
+	}
+	ok := &util.BucketDTO{Name: "poc-1", Region: "ca-central-1"}
+	failed := &util.BucketDTO{Name: "poc-2", Region: "ca-central-1"}
+	buckets := fs.GetObject(context.Background(), []util.CloudFilesystem{ok, failed}, nil)
+
+	// The failed bucket is empty but it isn't omitted
+	assert.Equal(t, 2, len(buckets))
+	assert.Equal(t, util.BUCKET_STATUS_COMPLETE, ok.Status)
+	assert.Equal(t, "", ok.Error)
+	assert.Equal(t, util.BUCKET_STATUS_FAILED, failed.Status)
+	assert.Contains(t, failed.Error, "AccessDenied: poc-2")
+}
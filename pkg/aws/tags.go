@@ -0,0 +1,43 @@
+package aws
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Get the tags of a bucket. A bucket without tags has no tag set.
+func (fs *S3) fetchTags(ctx context.Context, bucketName string) (map[string]string, error) {
+	output, err := fs.session.GetBucketTagging(ctx, &s3.GetBucketTaggingInput{Bucket: aws.String(bucketName)})
+	if isConfigNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string)
+	for _, tag := range output.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
+// Filter Bucket by tags given as key=value. The bucket must have all the keys,
+// the values given for the same key are alternatives.
+func (fs *S3) isTagIncluded(tags map[string]string) bool {
+	filters := make(map[string][]string)
+	for _, filter := range fs.options.FilterByTag {
+		key, value, _ := strings.Cut(filter, "=")
+		filters[key] = append(filters[key], value)
+	}
+	for key, values := range filters {
+		value, ok := tags[key]
+		if !ok || !slices.Contains(values, value) {
+			return false
+		}
+	}
+	return true
+}
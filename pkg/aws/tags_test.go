@@ -0,0 +1,76 @@
+package aws
+
+import (
+	"context"
+	"projet-devops-coveo/pkg/util"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchBucketTags(t *testing.T) {
+	mock := &AwsClientMock{
+		Objects: map[string][]types.Object{
+			"poc-1": {
+				{Key: aws.String("a.txt"), Size: aws.Int64(1), StorageClass: "STANDARD", LastModified: aws.Time(timeMock)},
+			},
+		},
+		Tags: map[string]map[string]string{
+			"poc-1": {"team": "data", "cost-center": "42"},
+			"poc-2": {"team": "web"},
+		},
+	}
+	tests := []struct {
+		name           string
+		filterByTag    []string
+		expectedOutput map[string]map[string]string
+	}{
+		{
+			name:        "No filter",
+			filterByTag: nil,
+			expectedOutput: map[string]map[string]string{
+				"poc-1": {"team": "data", "cost-center": "42"},
+				"poc-2": {"team": "web"},
+				"poc-3": nil,
+			},
+		},
+		{
+			name:        "Filter by tag",
+			filterByTag: []string{"team=data", "team=web"},
+			expectedOutput: map[string]map[string]string{
+				"poc-1": {"team": "data", "cost-center": "42"},
+				"poc-2": {"team": "web"},
+			},
+		},
+		{
+			name:        "Filter by all the tags",
+			filterByTag: []string{"team=data", "cost-center=42"},
+			expectedOutput: map[string]map[string]string{
+				"poc-1": {"team": "data", "cost-center": "42"},
+			},
+		},
+	}
+	for _, test := range tests {
+		fs := &S3{
+			session: mock,
+			totalStorageClassSize: &util.StorageClassSize{
+				SizeMap: util.RegionsStorageMap{"ca-central-1": map[string]float64{}},
+			},
+			options: util.CliOptions{Threading: 2, FilterByTag: test.filterByTag},
+			region:  "ca-central-1",
+		}
+		buckets := fs.GetObject(context.Background(), []util.CloudFilesystem{
+			&util.BucketDTO{Name: "poc-1", Region: "ca-central-1"},
+			&util.BucketDTO{Name: "poc-2", Region: "ca-central-1"},
+			&util.BucketDTO{Name: "poc-3", Region: "ca-central-1"},
+		}, nil)
+
+		tags := make(map[string]map[string]string)
+		for _, bucket := range buckets {
+			tags[bucket.GetName()] = bucket.GetTags()
+		}
+		assert.Equal(t, test.expectedOutput, tags, test.name)
+	}
+}
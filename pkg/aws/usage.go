@@ -0,0 +1,191 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"projet-devops-coveo/pkg/util"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	CLOUDWATCH_DIMENSION_FILTER = "FilterId"
+	// The request metrics are summed over the last 30 days for a month of usage
+	CLOUDWATCH_USAGE_LOOKBACK = 30 * 24 * time.Hour
+	// Intelligent-Tiering objects smaller than this are not monitored
+	S3_MONITORING_MINIMUM_SIZE = 128 << 10
+)
+
+// Request metrics of a bucket and the tier of the requests they count, 0 for the bytes downloaded.
+var requestMetrics = []struct {
+	metricName string
+	tier       int
+}{
+	{"PutRequests", 1},
+	{"PostRequests", 1},
+	{"ListRequests", 1},
+	{"GetRequests", 2},
+	{"HeadRequests", 2},
+	{"SelectRequests", 2},
+	{"BytesDownloaded", 0},
+}
+
+// Monthly volumes of a bucket in the usage file.
+//
+//	"*":
+//	  tier1Requests: 10000
+//	my-bucket:
+//	  tier2Requests: 5000000
+//	  retrievalGB: 20
+//	  egressGB: 50
+type usageVolumes struct {
+	Tier1Requests float64 `json:"tier1Requests" yaml:"tier1Requests"`
+	Tier2Requests float64 `json:"tier2Requests" yaml:"tier2Requests"`
+	RetrievalGB   float64 `json:"retrievalGB" yaml:"retrievalGB"`
+	EgressGB      float64 `json:"egressGB" yaml:"egressGB"`
+}
+
+// Load the monthly volumes of the buckets by name, in JSON or in YAML (.yaml or .yml).
+func LoadBucketUsage(path string) (map[string]util.BucketUsage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var volumes map[string]usageVolumes
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&volumes)
+	default:
+		decoder := json.NewDecoder(strings.NewReader(string(data)))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&volumes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid usage %s: %w", path, err)
+	}
+	usage := make(map[string]util.BucketUsage)
+	for bucketName, volume := range volumes {
+		if volume.Tier1Requests < 0 || volume.Tier2Requests < 0 || volume.RetrievalGB < 0 || volume.EgressGB < 0 {
+			return nil, fmt.Errorf("invalid usage %s: the volumes of %s must be positive", path, bucketName)
+		}
+		usage[bucketName] = util.BucketUsage{
+			Tier1Requests: volume.Tier1Requests,
+			Tier2Requests: volume.Tier2Requests,
+			RetrievalGB:   volume.RetrievalGB,
+			EgressGB:      volume.EgressGB,
+			Source:        util.USAGE_SOURCE_FILE,
+		}
+	}
+	return usage, nil
+}
+
+// Monthly volumes of a bucket from the usage file, or from its request metrics with --usage-from-metrics.
+// The monitored objects are counted by the scan. Nil without usage file, metrics or monitored objects.
+func (fs *S3) getBucketUsage(ctx context.Context, bucketName string, stats *bucketStats) *util.BucketUsage {
+	usage, ok := util.GetBucketUsage(fs.options.Usage, bucketName)
+	if !ok && fs.options.UsageFromMetrics && ctx.Err() == nil {
+		metricsUsage, err := fs.fetchRequestMetrics(ctx, bucketName)
+		if err != nil {
+			logrus.Warn("Unable to get the request metrics of bucket ", bucketName, ": ", err)
+		} else {
+			usage = *metricsUsage
+		}
+	}
+	usage.MonitoredObjects = stats.nbOfMonitoredObjects()
+	if usage == (util.BucketUsage{}) {
+		return nil
+	}
+	return &usage
+}
+
+// Estimate the monthly volumes of a bucket with the request metrics of the last 30 days. The request metrics are
+// only published for the buckets with a request metrics configuration. All the data downloaded is accounted as
+// retrieved and as transferred to the internet, so the transfer is an upper bound.
+func (fs *S3) fetchRequestMetrics(ctx context.Context, bucketName string) (*util.BucketUsage, error) {
+	queries := make([]cwtypes.MetricDataQuery, len(requestMetrics))
+	for i, metric := range requestMetrics {
+		queries[i] = newRequestMetricQuery(fmt.Sprintf("request%d", i), bucketName, metric.metricName, fs.options.RequestMetricsFilter)
+	}
+	end := time.Now()
+	input := &cloudwatch.GetMetricDataInput{
+		MetricDataQueries: queries,
+		StartTime:         aws.Time(end.Add(-CLOUDWATCH_USAGE_LOOKBACK)),
+		EndTime:           aws.Time(end),
+	}
+	usage := &util.BucketUsage{Source: util.USAGE_SOURCE_METRICS}
+	var downloaded float64
+	for {
+		output, err := fs.session.GetMetricData(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range output.MetricDataResults {
+			var i int
+			if _, err := fmt.Sscanf(aws.ToString(result.Id), "request%d", &i); err != nil || i >= len(requestMetrics) {
+				continue
+			}
+			var sum float64
+			for _, value := range result.Values {
+				sum += value
+			}
+			switch requestMetrics[i].tier {
+			case 1:
+				usage.Tier1Requests += sum
+			case 2:
+				usage.Tier2Requests += sum
+			default:
+				downloaded += sum
+			}
+		}
+		if output.NextToken == nil {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+	usage.RetrievalGB = TransformSizeToGB(downloaded)
+	usage.EgressGB = usage.RetrievalGB
+	return usage, nil
+}
+
+func newRequestMetricQuery(id string, bucketName string, metricName string, filterId string) cwtypes.MetricDataQuery {
+	return cwtypes.MetricDataQuery{
+		Id: aws.String(id),
+		MetricStat: &cwtypes.MetricStat{
+			Metric: &cwtypes.Metric{
+				Namespace:  aws.String(CLOUDWATCH_NAMESPACE),
+				MetricName: aws.String(metricName),
+				Dimensions: []cwtypes.Dimension{
+					{Name: aws.String(CLOUDWATCH_DIMENSION_BUCKET), Value: aws.String(bucketName)},
+					{Name: aws.String(CLOUDWATCH_DIMENSION_FILTER), Value: aws.String(filterId)},
+				},
+			},
+			Period: aws.Int32(CLOUDWATCH_PERIOD),
+			Stat:   aws.String("Sum"),
+		},
+	}
+}
+
+// Number of Intelligent-Tiering objects billed for monitoring, from the size histogram of the storage class.
+func (stats *bucketStats) nbOfMonitoredObjects() int64 {
+	h, ok := stats.sizeHistograms[S3_STORAGE_CLASS_INTELLIGENT_TIERING]
+	if !ok {
+		return 0
+	}
+	var nbOfFiles int64
+	for _, n := range h.NbOfFiles[getBin(util.SIZE_HISTOGRAM_BOUNDS, S3_MONITORING_MINIMUM_SIZE):] {
+		nbOfFiles += n
+	}
+	return nbOfFiles
+}
@@ -0,0 +1,78 @@
+package aws
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"projet-devops-coveo/pkg/util"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadBucketUsage(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "usage.yaml")
+	assert.Nil(t, os.WriteFile(yamlPath, []byte("\"*\":\n  tier1Requests: 100\npoc-1:\n  tier2Requests: 5000\n  retrievalGB: 2\n  egressGB: 3\n"), 0o600))
+	usage, err := LoadBucketUsage(yamlPath)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]util.BucketUsage{
+		"*":     {Tier1Requests: 100, Source: util.USAGE_SOURCE_FILE},
+		"poc-1": {Tier2Requests: 5000, RetrievalGB: 2, EgressGB: 3, Source: util.USAGE_SOURCE_FILE},
+	}, usage)
+
+	jsonPath := filepath.Join(dir, "usage.json")
+	assert.Nil(t, os.WriteFile(jsonPath, []byte(`{"poc-1": {"egressGB": 3}}`), 0o600))
+	usage, err = LoadBucketUsage(jsonPath)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]util.BucketUsage{"poc-1": {EgressGB: 3, Source: util.USAGE_SOURCE_FILE}}, usage)
+
+	assert.Nil(t, os.WriteFile(jsonPath, []byte(`{"poc-1": {"egress": 3}}`), 0o600))
+	_, err = LoadBucketUsage(jsonPath)
+	assert.ErrorContains(t, err, "unknown field")
+
+	assert.Nil(t, os.WriteFile(jsonPath, []byte(`{"poc-1": {"tier1Requests": -3}}`), 0o600))
+	_, err = LoadBucketUsage(jsonPath)
+	assert.ErrorContains(t, err, "must be positive")
+}
+
+func TestGetBucketUsage(t *testing.T) {
+	mock := &AwsClientMock{
+		Metrics: map[string]map[string]float64{
+			"poc-2": {
+				"PutRequests/EntireBucket":     100,
+				"ListRequests/EntireBucket":    20,
+				"GetRequests/EntireBucket":     3000,
+				"HeadRequests/EntireBucket":    400,
+				"BytesDownloaded/EntireBucket": 2 * 1024 * 1024 * 1024,
+			},
+		},
+	}
+	fs := &S3{
+		session: mock,
+		options: util.CliOptions{
+			Usage:                map[string]util.BucketUsage{"poc-1": {EgressGB: 5, Source: util.USAGE_SOURCE_FILE}},
+			UsageFromMetrics:     true,
+			RequestMetricsFilter: "EntireBucket",
+		},
+	}
+	stats := newBucketStats()
+	stats.addObject(100, "INTELLIGENT_TIERING", time.Now())
+	stats.addObject(256<<10, "INTELLIGENT_TIERING", time.Now())
+	stats.addObject(1<<20, "STANDARD", time.Now())
+
+	// The usage file wins over the metrics, the objects smaller than 128KB are not monitored
+	assert.Equal(t, &util.BucketUsage{EgressGB: 5, MonitoredObjects: 1, Source: util.USAGE_SOURCE_FILE}, fs.getBucketUsage(context.Background(), "poc-1", stats))
+	assert.Equal(t, &util.BucketUsage{
+		Tier1Requests: 120,
+		Tier2Requests: 3400,
+		RetrievalGB:   2,
+		EgressGB:      2,
+		Source:        util.USAGE_SOURCE_METRICS,
+	}, fs.getBucketUsage(context.Background(), "poc-2", newBucketStats()))
+	// Nothing billed without metrics
+	assert.Equal(t, &util.BucketUsage{Source: util.USAGE_SOURCE_METRICS}, fs.getBucketUsage(context.Background(), "poc-3", newBucketStats()))
+	fs.options.UsageFromMetrics = false
+	assert.Nil(t, fs.getBucketUsage(context.Background(), "poc-3", newBucketStats()))
+}
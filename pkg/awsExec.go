@@ -1,40 +1,93 @@
 package pkg
 
 import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"projet-devops-coveo/pkg/aws"
 	"projet-devops-coveo/pkg/util"
 
 	"github.com/sirupsen/logrus"
-	"go.uber.org/ratelimit"
 )
 
-func RunS3Command(options *util.CliOptions) error {
-	//Start the ratelimiter
-	limiter := ratelimit.New(options.RateLimit)
-	awsClient, err := aws.NewAwsClient(options.Regions[0], limiter)
-	//Fetching the price of the day.
-	logrus.Info("Fetching prices as of today...")
-	priceList, err := fetchPrices(awsClient, *options)
+func RunS3Command(ctx context.Context, options *util.CliOptions) error {
+	// Ctrl-C or the timeout stop the scan, the buckets scanned so far are still printed.
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+	go func() {
+		// A second Ctrl-C kills the program
+		<-ctx.Done()
+		stop()
+	}()
+
+	//Start the ratelimiter. It slows down when AWS throttles the calls.
+	limiter := aws.NewAdaptiveLimiter(options.RateLimit)
+	retryer := aws.NewRetryer(limiter, options.MaxRetries)
+	awsClient, err := aws.NewAwsClient(ctx, options.Regions[0], retryer)
+	// Errors that are not tied to a bucket, they are printed with the errors of the buckets.
+	var runErrors []util.ScanError
+
+	checkpoint, err := loadCheckpoint(*options)
 	if err != nil {
-		logrus.Error(err)
+		return err
+	}
+
+	duplicates, err := newDuplicateIndex(*options)
+	if err != nil {
+		return err
+	}
+	defer duplicates.Close()
+
+	inventories, err := loadInventories(ctx, awsClient, retryer, *options)
+	if err != nil {
+		return err
 	}
-	logrus.Info("Price fetched Successfully!")
 
 	logrus.Info("Starting the scrapping of S3 Buckets")
 	start := time.Now()
-	// Init GlobalStorageMap to use it on all s3 regions
-	globalStorageClassSize := initRegionStorageMap(options.Regions)
+	// GlobalStorageMap is used on all s3 regions, the regions are added once they are known
+	globalStorageClassSize := &util.StorageClassSize{
+		SizeMap: make(util.RegionsStorageMap),
+	}
 	var allBuckets []util.CloudFilesystem
 	// Create initial connection for scrapping of all the buckets since this call is regionless
-	fs, err := aws.InitConnection(options.Regions[0], *options, globalStorageClassSize, limiter)
+	fs, err := aws.InitConnection(ctx, options.Regions[0], *options, globalStorageClassSize, retryer, checkpoint, duplicates, inventories)
 	if err != nil {
-		logrus.Error(err)
+		return err
 	}
 	// Filter buckets with the filter given by user (Filter by name and Filter by region)
-	buckets := fs.GetBucketsFiltered()
+	locations := aws.LoadLocationCache(options.LocationCache, options.LocationCacheTTL)
+	buckets, err := fs.GetBucketsFiltered(ctx, locations)
+	if err != nil {
+		return err
+	}
+	if err := locations.Save(); err != nil {
+		logrus.Warn("Unable to save the location cache: ", err)
+	}
+	// The regions are the locations of the buckets found, the clients and the prices are only created for them
+	if options.AllRegions {
+		options.Regions = aws.GetRegionsOfBuckets(buckets)
+		logrus.Info("Buckets found in ", len(options.Regions), " regions: ", options.Regions)
+	}
+	initRegionStorageMap(globalStorageClassSize, options.Regions)
+	//Fetching the price of the day.
+	logrus.Info("Fetching prices as of today...")
+	priceList, priceErrors := fetchPrices(ctx, awsClient, options.Regions)
+	runErrors = append(runErrors, priceErrors...)
+	if len(priceErrors) == 0 {
+		logrus.Info("Price fetched Successfully!")
+	}
 	// We have to sort the list of buckets for increase performance for search functions
 	aws.SortListBasedOnRegion(buckets)
 	//Since the sdk of Go doesn't let you scrap a bucket which is not in the region of the config,
@@ -42,19 +95,25 @@ func RunS3Command(options *util.CliOptions) error {
 	bucketChan := make(chan []util.CloudFilesystem, len(buckets))
 	wg := new(sync.WaitGroup)
 	for _, region := range options.Regions {
-		wg.Add(1)
+		//Return all the bucket in the region
+		regionBucket := aws.GetBucketsOfRegion(buckets, region)
+		//Using the sorted lists from earlier, the search is way faster to find the index of the buckets
+		buckets = aws.RemoveScrappedBucketFromList(regionBucket, buckets)
 		//Init a new connection with the region
-		fs, err := aws.InitConnection(region, *options, globalStorageClassSize, limiter)
+		fs, err := aws.InitConnection(ctx, region, *options, globalStorageClassSize, retryer, checkpoint, duplicates, inventories)
 		if err != nil {
+			// The buckets of the region are still printed so they are not dropped silently
 			logrus.Error(err)
+			runErrors = append(runErrors, util.ScanError{Region: region, Error: err.Error()})
+			for _, bucket := range regionBucket {
+				bucket.SetStatus(util.BUCKET_STATUS_FAILED, "unable to connect to the region: "+err.Error())
+			}
+			allBuckets = append(allBuckets, regionBucket...)
 			continue
 		}
-		//Return all the bucket in the region
-		regionBucket := aws.GetBucketsOfRegion(buckets, region)
-		//Using the sorted lists from earlier, the search is way faster to find the index of the buckets
-		buckets = aws.RemoveScrappedBucketFromList(regionBucket, buckets)
+		wg.Add(1)
 		//Starting multi-threading on the scrap of objects.
-		go fs.ListObjectsInBucket(regionBucket, region, priceList, wg, bucketChan)
+		go fs.ListObjectsInBucket(ctx, regionBucket, region, priceList, wg, bucketChan)
 
 	}
 	wg.Wait()
@@ -62,36 +121,127 @@ func RunS3Command(options *util.CliOptions) error {
 	for bucket := range bucketChan {
 		allBuckets = append(allBuckets, bucket...)
 	}
-	// Set Bucket cost with all the information gathered.
-	fs.SetBucketCost(allBuckets, priceList)
+	// The buckets left have no region since their location couldn't be fetched, they are already marked failed.
+	allBuckets = append(allBuckets, buckets...)
+	if err := ctx.Err(); err != nil {
+		logrus.Warn("Scan interrupted: ", err, ". The unfinished buckets are marked incomplete")
+	}
+	if isScanComplete(allBuckets) {
+		// The scan doesn't need to be resumed anymore.
+		if err := checkpoint.Remove(); err != nil {
+			logrus.Error(err)
+		}
+	} else if err := checkpoint.Save(); err != nil {
+		// The progress of the unfinished buckets is saved so they are scanned again when the scan is resumed.
+		logrus.Error(err)
+	} else if options.ResumeState != "" {
+		logrus.Info("Progress saved to ", options.ResumeState, ". Run again with --resume ", options.ResumeState, " to scan the unfinished buckets")
+	}
+	// Set Bucket cost with all the information gathered. The storage classes that can't be priced are errors of their region.
+	runErrors = util.AppendScanErrors(runErrors, fs.SetBucketCost(allBuckets, priceList)...)
+	runErrors = util.AppendScanErrors(runErrors, fs.SetSimulationCost(allBuckets, priceList)...)
+	runErrors = util.AppendScanErrors(runErrors, fs.SetProjectionCost(allBuckets, priceList)...)
+	// The errors of the prices are already reported with the cost of the buckets
+	tierListPrices, _ := fs.GetTierListPrices(priceList)
+	duplicatesReport, err := duplicates.Report(tierListPrices)
+	if err != nil {
+		logrus.Error("Unable to find the duplicates: ", err)
+		runErrors = append(runErrors, util.ScanError{Error: "unable to find the duplicates: " + err.Error()})
+	}
 	logrus.Info("Buckets have been fetched successfuly!")
 	logrus.Info("Execution Time: ", time.Since(start))
+	retryer.LogSummary()
 	logrus.Info("Printing data...")
 	//Print Data
-	util.OutputData(allBuckets, *options.OutputOptions, globalStorageClassSize.SizeMap)
+	if err := util.OutputData(allBuckets, *options.OutputOptions, globalStorageClassSize.SizeMap, duplicatesReport, runErrors); err != nil {
+		return err
+	}
 	logrus.Info("Done!")
+	if nbOfErrors := countScanErrors(allBuckets, runErrors); nbOfErrors != 0 {
+		logrus.Warn(nbOfErrors, " errors during the scan, see the Errors section of the output")
+		if options.FailOnError {
+			return fmt.Errorf("the scan has %d errors", nbOfErrors)
+		}
+	}
 	return nil
 }
 
-func initRegionStorageMap(regions []string) *util.StorageClassSize {
-	var globalStorageClassSize = &util.StorageClassSize{
-		SizeMap: make(util.RegionsStorageMap),
+// No bucket failed, timed out or was interrupted.
+func isScanComplete(buckets []util.CloudFilesystem) bool {
+	for _, bucket := range buckets {
+		if bucket.GetStatus() != util.BUCKET_STATUS_COMPLETE {
+			return false
+		}
+	}
+	return true
+}
+
+// Count the errors of the run and the buckets that are not complete.
+func countScanErrors(buckets []util.CloudFilesystem, runErrors []util.ScanError) int {
+	nbOfErrors := len(runErrors)
+	for _, bucket := range buckets {
+		if bucket.GetStatus() != util.BUCKET_STATUS_COMPLETE {
+			nbOfErrors++
+		}
+	}
+	return nbOfErrors
+}
+
+// The objects are spilled to temporary files with --duplicates.
+func newDuplicateIndex(options util.CliOptions) (*aws.DuplicateIndex, error) {
+	if !options.FindDuplicates {
+		return nil, nil
+	}
+	return aws.NewDuplicateIndex()
+}
+
+// The manifests are loaded once for all the regions. The destination buckets are read with a client in their region.
+func loadInventories(ctx context.Context, awsClient aws.AwsInterface, retryer *aws.Retryer, options util.CliOptions) (map[string]*aws.InventoryManifest, error) {
+	if options.Source != util.SOURCE_INVENTORY {
+		return nil, nil
+	}
+	return aws.LoadInventoryManifests(ctx, func(ctx context.Context, bucket string) (aws.AwsInterface, error) {
+		return aws.NewAwsClientForBucket(ctx, awsClient, bucket, retryer)
+	}, options.InventoryLocations)
+}
+
+// Load the state file given with --resume.
+func loadCheckpoint(options util.CliOptions) (*aws.Checkpoint, error) {
+	if options.ResumeState == "" {
+		return nil, nil
+	}
+	checkpoint, err := aws.LoadCheckpoint(options.ResumeState, options)
+	if err != nil {
+		return nil, err
+	}
+	if done := checkpoint.NbOfBucketsDone(); done != 0 {
+		logrus.Info("Resuming the scan from ", options.ResumeState, ", ", done, " buckets already scanned")
 	}
+	return checkpoint, nil
+}
+
+func initRegionStorageMap(globalStorageClassSize *util.StorageClassSize, regions []string) {
 	for _, region := range regions {
 		globalStorageClassSize.SizeMap[region] = make(map[string]float64)
 	}
-	return globalStorageClassSize
 }
 
-func fetchPrices(awsClient aws.AwsInterface, options util.CliOptions) (aws.MasterPriceList, error) {
+// The prices are fetched region by region, a region without prices doesn't prevent the cost of the others.
+func fetchPrices(ctx context.Context, awsClient aws.AwsInterface, regions []string) (aws.MasterPriceList, []util.ScanError) {
 	//Init connection to AWS pricing services
 	svc := aws.InitConnectionPricingList(awsClient)
-	//Get a list with all the skus for Amazon S3 product grouped by region
-	regionSkuList, err := svc.GetSkusForRegions(options.Regions)
-	if err != nil {
-		return nil, err
+	masterPriceList := make(aws.MasterPriceList)
+	var priceErrors []util.ScanError
+	for _, region := range regions {
+		//Get a list with all the skus for Amazon S3 product of the region
+		regionSkuList, err := svc.GetSkusForRegions(ctx, []string{region})
+		if err != nil {
+			logrus.Error("Unable to fetch the prices of ", region, ": ", err)
+			priceErrors = append(priceErrors, util.ScanError{Region: region, Error: "unable to fetch the prices, the costs are not computed: " + err.Error()})
+			continue
+		}
+		//Create a price list with all the different prices for the region
+		maps.Copy(masterPriceList, svc.GetRegionPriceList(ctx, regionSkuList))
 	}
-	//Create a price list with all the different prices for the wanted regions
-	masterPriceList := svc.GetRegionPriceList(regionSkuList)
-	return masterPriceList, nil
+	return masterPriceList, priceErrors
 }
@@ -0,0 +1,90 @@
+package util
+
+import "time"
+
+// Configuration of a bucket, fetched with --include-config.
+// A configuration that isn't set on the bucket is omitted.
+type BucketConfig struct {
+	// Enabled, Suspended or Disabled when it was never enabled
+	Versioning        string                   `json:",omitempty"`
+	MFADelete         string                   `json:",omitempty"`
+	LifecycleRules    []LifecycleRule          `json:",omitempty"`
+	Replication       *ReplicationConfig       `json:",omitempty"`
+	Encryption        []EncryptionRule         `json:",omitempty"`
+	ObjectLock        *ObjectLockConfig        `json:",omitempty"`
+	Logging           *LoggingConfig           `json:",omitempty"`
+	PublicAccessBlock *PublicAccessBlockConfig `json:",omitempty"`
+	ObjectOwnership   string                   `json:",omitempty"`
+
+	// Configurations that couldn't be fetched (Ex.: AccessDenied), by name
+	Errors map[string]string `json:",omitempty"`
+}
+
+// The filter of a rule is the prefix, the tags and the sizes of the objects, all of them must match.
+type LifecycleRule struct {
+	ID                    string `json:",omitempty"`
+	Status                string
+	Prefix                string            `json:",omitempty"`
+	Tags                  map[string]string `json:",omitempty"`
+	ObjectSizeGreaterThan int64             `json:",omitempty"`
+	ObjectSizeLessThan    int64             `json:",omitempty"`
+
+	Transitions               []LifecycleTransition `json:",omitempty"`
+	ExpirationDays            int32                 `json:",omitempty"`
+	ExpirationDate            *time.Time            `json:",omitempty"`
+	ExpiredObjectDeleteMarker bool                  `json:",omitempty"`
+
+	NoncurrentTransitions    []LifecycleTransition `json:",omitempty"`
+	NoncurrentExpirationDays int32                 `json:",omitempty"`
+	NewerNoncurrentVersions  int32                 `json:",omitempty"`
+
+	AbortIncompleteMultipartUploadDays int32 `json:",omitempty"`
+}
+
+// Days are counted from the creation of the object, or from the time it became noncurrent for noncurrent versions.
+type LifecycleTransition struct {
+	Days         int32      `json:",omitempty"`
+	Date         *time.Time `json:",omitempty"`
+	StorageClass string
+}
+
+type ReplicationConfig struct {
+	Role  string `json:",omitempty"`
+	Rules []ReplicationRule
+}
+
+type ReplicationRule struct {
+	ID                      string `json:",omitempty"`
+	Status                  string
+	Priority                int32  `json:",omitempty"`
+	Prefix                  string `json:",omitempty"`
+	DestinationBucket       string
+	DestinationStorageClass string `json:",omitempty"`
+	DeleteMarkerReplication bool   `json:",omitempty"`
+}
+
+type EncryptionRule struct {
+	Algorithm        string
+	KMSKeyId         string `json:",omitempty"`
+	BucketKeyEnabled bool   `json:",omitempty"`
+}
+
+// The default retention is in days or in years.
+type ObjectLockConfig struct {
+	Enabled bool
+	Mode    string `json:",omitempty"`
+	Days    int32  `json:",omitempty"`
+	Years   int32  `json:",omitempty"`
+}
+
+type LoggingConfig struct {
+	TargetBucket string
+	TargetPrefix string `json:",omitempty"`
+}
+
+type PublicAccessBlockConfig struct {
+	BlockPublicAcls       bool
+	IgnorePublicAcls      bool
+	BlockPublicPolicy     bool
+	RestrictPublicBuckets bool
+}
@@ -11,9 +11,28 @@ type CloudFilesystem interface {
 	SetNbOfFiles(value int64)
 	SetSizeOfBucket(value float64)
 	SetLastUpdateDate(value time.Time)
-	SetCost(value float64)
+	SetCost(value CostBreakdown)
 	SetStorageClass(value StorageClassSizeMap)
 	SetRegion(value string)
+	SetNoncurrentVersions(nbOfFiles int64, size float64, storageClassSize StorageClassSizeMap)
+	SetNbOfDeleteMarkers(value int64)
+	SetMultipartUploads(nbOfUploads int64, size float64, storageClassSize StorageClassSizeMap, oldestUploadAge int64)
+	SetStatus(status string, err string)
+	SetConfig(value *BucketConfig)
+	SetEncryption(encryption string, kmsKeyId string)
+	SetTags(value map[string]string)
+	SetSizeHistogram(total []HistogramBin, storageClass map[string][]HistogramBin)
+	SetObjectAges(oldest time.Time, median time.Time, total []HistogramBin, storageClass map[string][]HistogramBin)
+	SetStaleSize(value float64)
+	SetPrefixes(value []*PrefixUsage)
+	SetLargest(objects []TopObject, prefixes []TopPrefix)
+	SetSimulation(value *Simulation)
+	SetLifecycleProjection(value []CostProjection)
+	SetUsage(value *BucketUsage)
+	SetBillableSize(size float64, storageClassSize StorageClassSizeMap)
+	SetMinimumDurationSize(value StorageClassSizeMap)
+	SetMinimumDurationCost(value float64)
+	SetObjectEncryption(nbOfFiles map[string]int64, size StorageClassSizeMap)
 	ApplySizeConversion(sizeConvrsion float64)
 	GetName() string
 	GetCreationDate() time.Time
@@ -21,19 +40,123 @@ type CloudFilesystem interface {
 	GetSizeOfBucket() float64
 	GetLastUpdateDate() time.Time
 	GetCost() float64
+	GetCostBreakdown() CostBreakdown
 	GetStorageClass() StorageClassSizeMap
 	GetRegion() string
+	GetNbOfNoncurrentFiles() int64
+	GetNoncurrentSize() float64
+	GetNoncurrentStorageClass() StorageClassSizeMap
+	GetNbOfDeleteMarkers() int64
+	GetNbOfMultipartUploads() int64
+	GetMultipartUploadsSize() float64
+	GetMultipartStorageClass() StorageClassSizeMap
+	GetOldestMultipartUploadAge() int64
+	GetStatus() string
+	GetError() string
+	GetConfig() *BucketConfig
+	GetEncryption() string
+	GetTags() map[string]string
+	GetSizeHistogram() []HistogramBin
+	GetStorageClassSizeHistogram() map[string][]HistogramBin
+	GetOldestUpdateDate() time.Time
+	GetMedianUpdateDate() time.Time
+	GetAgeHistogram() []HistogramBin
+	GetStorageClassAgeHistogram() map[string][]HistogramBin
+	GetStaleSize() float64
+	GetPrefixes() []*PrefixUsage
+	GetLargest() ([]TopObject, []TopPrefix)
+	GetSimulation() *Simulation
+	GetLifecycleProjection() []CostProjection
+	GetUsage() *BucketUsage
+	GetBillableSize() float64
+	GetBillableStorageClass() StorageClassSizeMap
+	GetMinimumDurationSize() StorageClassSizeMap
+	GetMinimumDurationCost() float64
+	GetEncryptionKMSKeyId() string
+	GetObjectEncryptionNbOfFiles() map[string]int64
+	GetObjectEncryptionSize() StorageClassSizeMap
 }
 
 type BucketDTO struct {
-	Name             string
-	CreationDate     time.Time
-	NbOfFiles        int64
-	SizeOfBucket     float64
-	LastUpdateDate   time.Time
+	Name           string
+	CreationDate   time.Time
+	NbOfFiles      int64
+	SizeOfBucket   float64
+	LastUpdateDate time.Time
+	// Monthly cost, the total of the line items of the breakdown
 	Cost             float64
+	CostBreakdown    CostBreakdown
 	StorageClassSize StorageClassSizeMap
 	Region           string
+	// Monthly requests and transfers with --usage or --usage-from-metrics, and the monitored objects
+	Usage *BucketUsage `json:",omitempty"`
+	// Size billed for the current objects, the noncurrent versions and the incomplete multipart uploads, once the
+	// minimum billable sizes and the metadata of the archived objects are added
+	BillableSize             float64
+	BillableStorageClassSize StorageClassSizeMap `json:",omitempty"`
+	// Size of the current objects younger than the minimum storage duration of their storage class multiplied by the
+	// months left, and its cost. It's due even if the objects are deleted or transitioned before.
+	MinimumDurationSize StorageClassSizeMap `json:",omitempty"`
+	MinimumDurationCost float64             `json:",omitempty"`
+
+	// Only filled when the versions of the objects are scanned
+	NbOfNoncurrentFiles        int64               `json:",omitempty"`
+	NoncurrentSize             float64             `json:",omitempty"`
+	NoncurrentStorageClassSize StorageClassSizeMap `json:",omitempty"`
+	NbOfDeleteMarkers          int64               `json:",omitempty"`
+
+	// Incomplete multipart uploads. The age of the oldest upload is in days.
+	NbOfMultipartUploads      int64               `json:",omitempty"`
+	MultipartUploadsSize      float64             `json:",omitempty"`
+	MultipartStorageClassSize StorageClassSizeMap `json:",omitempty"`
+	OldestMultipartUploadAge  int64               `json:",omitempty"`
+
+	// complete, incomplete when the scan was interrupted by a timeout or a signal, or failed when AWS returned an error.
+	// The numbers of a bucket that isn't complete only cover the objects listed before.
+	Status string
+	Error  string `json:",omitempty"`
+
+	// Default encryption of the bucket: SSE-S3, SSE-KMS, DSSE-KMS, none, or unknown if it can't be fetched.
+	Encryption         string
+	EncryptionKMSKeyId string `json:",omitempty"`
+
+	Tags map[string]string `json:",omitempty"`
+
+	// Number and size of the current objects per bin of size, for the bucket and per storage class.
+	SizeHistogram             []HistogramBin            `json:",omitempty"`
+	StorageClassSizeHistogram map[string][]HistogramBin `json:",omitempty"`
+
+	// Last modification of the oldest and of the median current objects, the median is a day.
+	OldestUpdateDate time.Time
+	MedianUpdateDate time.Time
+	// Number and size of the current objects per bin of age, for the bucket and per storage class.
+	AgeHistogram             []HistogramBin            `json:",omitempty"`
+	StorageClassAgeHistogram map[string][]HistogramBin `json:",omitempty"`
+	// Size of the current objects older than --stale-days
+	StaleSize float64 `json:",omitempty"`
+	// Tree of the prefixes with --prefix-depth, the largest first
+	Prefixes []*PrefixUsage `json:",omitempty"`
+	// Largest current objects and prefixes with --top, the largest first
+	LargestObjects  []TopObject `json:",omitempty"`
+	LargestPrefixes []TopPrefix `json:",omitempty"`
+	// Projection of the lifecycle rules of the simulate command
+	Simulation *Simulation `json:",omitempty"`
+	// Cost projected with the lifecycle rules of the bucket with --lifecycle-projection
+	LifecycleProjection []CostProjection `json:",omitempty"`
+
+	// Current objects per encryption, only filled from the inventory reports or with --encryption-sample.
+	// The numbers are estimated from the sample when the objects are sampled.
+	ObjectEncryptionNbOfFiles map[string]int64    `json:",omitempty"`
+	ObjectEncryptionSize      StorageClassSizeMap `json:",omitempty"`
+
+	// Only filled with --include-config
+	Config *BucketConfig `json:",omitempty"`
+}
+
+type HistogramBin struct {
+	Bin       string
+	NbOfFiles int64
+	Size      float64
 }
 
 func NewCloudFileSystem(fsType string) CloudFilesystem {
@@ -65,8 +188,9 @@ func (bucket *BucketDTO) SetLastUpdateDate(value time.Time) {
 	bucket.LastUpdateDate = value
 }
 
-func (bucket *BucketDTO) SetCost(value float64) {
-	bucket.Cost = value
+func (bucket *BucketDTO) SetCost(value CostBreakdown) {
+	bucket.Cost = value.Total()
+	bucket.CostBreakdown = value
 }
 
 func (bucket *BucketDTO) SetStorageClass(value StorageClassSizeMap) {
@@ -77,6 +201,51 @@ func (bucket *BucketDTO) SetRegion(value string) {
 	bucket.Region = value
 }
 
+func (bucket *BucketDTO) SetNoncurrentVersions(nbOfFiles int64, size float64, storageClassSize StorageClassSizeMap) {
+	bucket.NbOfNoncurrentFiles = nbOfFiles
+	bucket.NoncurrentSize = size
+	bucket.NoncurrentStorageClassSize = storageClassSize
+}
+
+func (bucket *BucketDTO) SetNbOfDeleteMarkers(value int64) {
+	bucket.NbOfDeleteMarkers = value
+}
+
+func (bucket *BucketDTO) SetMultipartUploads(nbOfUploads int64, size float64, storageClassSize StorageClassSizeMap, oldestUploadAge int64) {
+	bucket.NbOfMultipartUploads = nbOfUploads
+	bucket.MultipartUploadsSize = size
+	bucket.MultipartStorageClassSize = storageClassSize
+	bucket.OldestMultipartUploadAge = oldestUploadAge
+}
+
+func (bucket *BucketDTO) SetStatus(status string, err string) {
+	bucket.Status = status
+	bucket.Error = err
+}
+
+func (bucket *BucketDTO) SetConfig(value *BucketConfig) {
+	bucket.Config = value
+}
+
+func (bucket *BucketDTO) SetEncryption(encryption string, kmsKeyId string) {
+	bucket.Encryption = encryption
+	bucket.EncryptionKMSKeyId = kmsKeyId
+}
+
+func (bucket *BucketDTO) SetTags(value map[string]string) {
+	bucket.Tags = value
+}
+
+func (bucket *BucketDTO) SetSizeHistogram(total []HistogramBin, storageClass map[string][]HistogramBin) {
+	bucket.SizeHistogram = total
+	bucket.StorageClassSizeHistogram = storageClass
+}
+
+func (bucket *BucketDTO) SetObjectEncryption(nbOfFiles map[string]int64, size StorageClassSizeMap) {
+	bucket.ObjectEncryptionNbOfFiles = nbOfFiles
+	bucket.ObjectEncryptionSize = size
+}
+
 func (bucket *BucketDTO) GetName() string {
 	return bucket.Name
 }
@@ -101,6 +270,10 @@ func (bucket *BucketDTO) GetCost() float64 {
 	return bucket.Cost
 }
 
+func (bucket *BucketDTO) GetCostBreakdown() CostBreakdown {
+	return bucket.CostBreakdown
+}
+
 func (bucket *BucketDTO) GetStorageClass() StorageClassSizeMap {
 	return bucket.StorageClassSize
 }
@@ -109,9 +282,223 @@ func (bucket *BucketDTO) GetRegion() string {
 	return bucket.Region
 }
 
+func (bucket *BucketDTO) GetNbOfNoncurrentFiles() int64 {
+	return bucket.NbOfNoncurrentFiles
+}
+
+func (bucket *BucketDTO) GetNoncurrentSize() float64 {
+	return bucket.NoncurrentSize
+}
+
+func (bucket *BucketDTO) GetNoncurrentStorageClass() StorageClassSizeMap {
+	return bucket.NoncurrentStorageClassSize
+}
+
+func (bucket *BucketDTO) GetNbOfDeleteMarkers() int64 {
+	return bucket.NbOfDeleteMarkers
+}
+
+func (bucket *BucketDTO) GetNbOfMultipartUploads() int64 {
+	return bucket.NbOfMultipartUploads
+}
+
+func (bucket *BucketDTO) GetMultipartUploadsSize() float64 {
+	return bucket.MultipartUploadsSize
+}
+
+func (bucket *BucketDTO) GetMultipartStorageClass() StorageClassSizeMap {
+	return bucket.MultipartStorageClassSize
+}
+
+func (bucket *BucketDTO) GetOldestMultipartUploadAge() int64 {
+	return bucket.OldestMultipartUploadAge
+}
+
+func (bucket *BucketDTO) GetStatus() string {
+	return bucket.Status
+}
+
+func (bucket *BucketDTO) GetError() string {
+	return bucket.Error
+}
+
+func (bucket *BucketDTO) GetConfig() *BucketConfig {
+	return bucket.Config
+}
+
+func (bucket *BucketDTO) GetEncryption() string {
+	return bucket.Encryption
+}
+
+func (bucket *BucketDTO) GetTags() map[string]string {
+	return bucket.Tags
+}
+
+func (bucket *BucketDTO) SetObjectAges(oldest time.Time, median time.Time, total []HistogramBin, storageClass map[string][]HistogramBin) {
+	bucket.OldestUpdateDate = oldest
+	bucket.MedianUpdateDate = median
+	bucket.AgeHistogram = total
+	bucket.StorageClassAgeHistogram = storageClass
+}
+
+func (bucket *BucketDTO) SetStaleSize(value float64) {
+	bucket.StaleSize = value
+}
+
+func (bucket *BucketDTO) GetOldestUpdateDate() time.Time {
+	return bucket.OldestUpdateDate
+}
+
+func (bucket *BucketDTO) GetMedianUpdateDate() time.Time {
+	return bucket.MedianUpdateDate
+}
+
+func (bucket *BucketDTO) GetAgeHistogram() []HistogramBin {
+	return bucket.AgeHistogram
+}
+
+func (bucket *BucketDTO) GetStorageClassAgeHistogram() map[string][]HistogramBin {
+	return bucket.StorageClassAgeHistogram
+}
+
+func (bucket *BucketDTO) GetStaleSize() float64 {
+	return bucket.StaleSize
+}
+
+func (bucket *BucketDTO) SetPrefixes(value []*PrefixUsage) {
+	bucket.Prefixes = value
+}
+
+func (bucket *BucketDTO) GetPrefixes() []*PrefixUsage {
+	return bucket.Prefixes
+}
+
+func (bucket *BucketDTO) SetLargest(objects []TopObject, prefixes []TopPrefix) {
+	bucket.LargestObjects = objects
+	bucket.LargestPrefixes = prefixes
+}
+
+func (bucket *BucketDTO) GetLargest() ([]TopObject, []TopPrefix) {
+	return bucket.LargestObjects, bucket.LargestPrefixes
+}
+
+func (bucket *BucketDTO) SetSimulation(value *Simulation) {
+	bucket.Simulation = value
+}
+
+func (bucket *BucketDTO) GetSimulation() *Simulation {
+	return bucket.Simulation
+}
+
+func (bucket *BucketDTO) SetLifecycleProjection(value []CostProjection) {
+	bucket.LifecycleProjection = value
+}
+
+func (bucket *BucketDTO) GetLifecycleProjection() []CostProjection {
+	return bucket.LifecycleProjection
+}
+
+func (bucket *BucketDTO) SetUsage(value *BucketUsage) {
+	bucket.Usage = value
+}
+
+func (bucket *BucketDTO) GetUsage() *BucketUsage {
+	return bucket.Usage
+}
+
+func (bucket *BucketDTO) SetBillableSize(size float64, storageClassSize StorageClassSizeMap) {
+	bucket.BillableSize = size
+	bucket.BillableStorageClassSize = storageClassSize
+}
+
+func (bucket *BucketDTO) GetBillableSize() float64 {
+	return bucket.BillableSize
+}
+
+func (bucket *BucketDTO) GetBillableStorageClass() StorageClassSizeMap {
+	return bucket.BillableStorageClassSize
+}
+
+func (bucket *BucketDTO) SetMinimumDurationSize(value StorageClassSizeMap) {
+	bucket.MinimumDurationSize = value
+}
+
+func (bucket *BucketDTO) GetMinimumDurationSize() StorageClassSizeMap {
+	return bucket.MinimumDurationSize
+}
+
+func (bucket *BucketDTO) SetMinimumDurationCost(value float64) {
+	bucket.MinimumDurationCost = value
+}
+
+func (bucket *BucketDTO) GetMinimumDurationCost() float64 {
+	return bucket.MinimumDurationCost
+}
+
+func (bucket *BucketDTO) GetSizeHistogram() []HistogramBin {
+	return bucket.SizeHistogram
+}
+
+func (bucket *BucketDTO) GetStorageClassSizeHistogram() map[string][]HistogramBin {
+	return bucket.StorageClassSizeHistogram
+}
+
+func (bucket *BucketDTO) GetEncryptionKMSKeyId() string {
+	return bucket.EncryptionKMSKeyId
+}
+
+func (bucket *BucketDTO) GetObjectEncryptionNbOfFiles() map[string]int64 {
+	return bucket.ObjectEncryptionNbOfFiles
+}
+
+func (bucket *BucketDTO) GetObjectEncryptionSize() StorageClassSizeMap {
+	return bucket.ObjectEncryptionSize
+}
+
 func (bucket *BucketDTO) ApplySizeConversion(sizeConversion float64) {
 	bucket.SizeOfBucket = bucket.SizeOfBucket / math.Pow(float64(1024), sizeConversion)
 	for k, v := range bucket.StorageClassSize {
 		bucket.StorageClassSize[k] = v / math.Pow(float64(1024), sizeConversion)
 	}
+	bucket.NoncurrentSize = bucket.NoncurrentSize / math.Pow(float64(1024), sizeConversion)
+	for k, v := range bucket.NoncurrentStorageClassSize {
+		bucket.NoncurrentStorageClassSize[k] = v / math.Pow(float64(1024), sizeConversion)
+	}
+	bucket.MultipartUploadsSize = bucket.MultipartUploadsSize / math.Pow(float64(1024), sizeConversion)
+	for k, v := range bucket.MultipartStorageClassSize {
+		bucket.MultipartStorageClassSize[k] = v / math.Pow(float64(1024), sizeConversion)
+	}
+	bucket.BillableSize = bucket.BillableSize / math.Pow(float64(1024), sizeConversion)
+	for k, v := range bucket.BillableStorageClassSize {
+		bucket.BillableStorageClassSize[k] = v / math.Pow(float64(1024), sizeConversion)
+	}
+	for k, v := range bucket.MinimumDurationSize {
+		bucket.MinimumDurationSize[k] = v / math.Pow(float64(1024), sizeConversion)
+	}
+	for k, v := range bucket.ObjectEncryptionSize {
+		bucket.ObjectEncryptionSize[k] = v / math.Pow(float64(1024), sizeConversion)
+	}
+	for i := range bucket.SizeHistogram {
+		bucket.SizeHistogram[i].Size = bucket.SizeHistogram[i].Size / math.Pow(float64(1024), sizeConversion)
+	}
+	for _, histogram := range bucket.StorageClassSizeHistogram {
+		for i := range histogram {
+			histogram[i].Size = histogram[i].Size / math.Pow(float64(1024), sizeConversion)
+		}
+	}
+	for i := range bucket.AgeHistogram {
+		bucket.AgeHistogram[i].Size = bucket.AgeHistogram[i].Size / math.Pow(float64(1024), sizeConversion)
+	}
+	for _, histogram := range bucket.StorageClassAgeHistogram {
+		for i := range histogram {
+			histogram[i].Size = histogram[i].Size / math.Pow(float64(1024), sizeConversion)
+		}
+	}
+	bucket.StaleSize = bucket.StaleSize / math.Pow(float64(1024), sizeConversion)
+	applyPrefixesSizeConversion(bucket.Prefixes, sizeConversion)
+	applyTopSizeConversion(bucket.LargestObjects, bucket.LargestPrefixes, sizeConversion)
+	if bucket.Simulation != nil {
+		bucket.Simulation.ApplySizeConversion(sizeConversion)
+	}
+	applyProjectionSizeConversion(bucket.LifecycleProjection, sizeConversion)
 }
@@ -7,3 +7,53 @@ const (
 	SIZE_CONV_GB = 3
 	SIZE_CONV_TB = 4
 )
+
+const (
+	SOURCE_LIST       = "list"
+	SOURCE_INVENTORY  = "inventory"
+	SOURCE_CLOUDWATCH = "cloudwatch"
+)
+
+const (
+	BUCKET_STATUS_COMPLETE   = "complete"
+	BUCKET_STATUS_INCOMPLETE = "incomplete"
+	BUCKET_STATUS_FAILED     = "failed"
+)
+
+// Server-side encryption of the buckets and the objects
+const (
+	ENCRYPTION_SSE_S3   = "SSE-S3"
+	ENCRYPTION_SSE_KMS  = "SSE-KMS"
+	ENCRYPTION_DSSE_KMS = "DSSE-KMS"
+	ENCRYPTION_SSE_C    = "SSE-C"
+	ENCRYPTION_NONE     = "none"
+	ENCRYPTION_UNKNOWN  = "unknown"
+)
+
+const (
+	GROUP_BY_TAG_PREFIX = "tag:"
+	GROUP_UNTAGGED      = "untagged"
+)
+
+// Where the volumes of the requests and the transfers of a bucket come from.
+const (
+	USAGE_SOURCE_FILE    = "usage file"
+	USAGE_SOURCE_METRICS = "metrics"
+	// Bucket name of the usage file for the buckets that are not in it
+	USAGE_ALL_BUCKETS = "*"
+)
+
+// Upper bounds of the bins of the object size histogram, the last bin has the objects larger than the last bound.
+// 128KB is the minimum billable size of the IA storage classes.
+var SIZE_HISTOGRAM_BOUNDS = []int64{1 << 10, 128 << 10, 1 << 20, 16 << 20, 128 << 20, 1 << 30, 5 << 30}
+var SIZE_HISTOGRAM_BINS = []string{"<1KB", "<128KB", "<1MB", "<16MB", "<128MB", "<1GB", "<5GB", ">=5GB"}
+
+// Delimiter of the levels of the prefixes with --prefix-depth.
+const PREFIX_DELIMITER = "/"
+
+// Upper bounds in days of the bins of the object age histogram, from the last modification of the objects.
+var AGE_HISTOGRAM_BOUNDS = []int64{30, 90, 365}
+var AGE_HISTOGRAM_BINS = []string{"<30d", "30-90d", "90-365d", ">=1y"}
+
+// Months after which the cost of the buckets is projected with their lifecycle rules.
+var PROJECTION_MONTHS = []int{3, 6, 12}
@@ -0,0 +1,48 @@
+package util
+
+import "math"
+
+// Objects with the same content found in the scanned buckets with --duplicates, by ETag and size.
+// The sizes are in the unit of the output and the costs are monthly.
+type DuplicatesReport struct {
+	NbOfGroups int
+	// Copies beyond the first one of each group
+	NbOfDuplicates int
+	WastedSize     float64
+	WastedCost     float64
+	// The groups that waste the most first
+	Groups []DuplicateGroup
+}
+
+type DuplicateGroup struct {
+	ETag       string
+	Size       float64
+	Multipart  bool
+	NbOfCopies int
+	WastedSize float64
+	WastedCost float64
+	Objects    []DuplicateObject
+}
+
+type DuplicateObject struct {
+	Bucket       string
+	Region       string
+	Key          string
+	StorageClass string
+}
+
+func (report *DuplicatesReport) AddGroup(group DuplicateGroup) {
+	report.NbOfGroups++
+	report.NbOfDuplicates += group.NbOfCopies - 1
+	report.WastedSize += group.WastedSize
+	report.WastedCost += group.WastedCost
+	report.Groups = append(report.Groups, group)
+}
+
+func (report *DuplicatesReport) ApplySizeConversion(sizeConversion float64) {
+	report.WastedSize = report.WastedSize / math.Pow(float64(1024), sizeConversion)
+	for i := range report.Groups {
+		report.Groups[i].Size = report.Groups[i].Size / math.Pow(float64(1024), sizeConversion)
+		report.Groups[i].WastedSize = report.Groups[i].WastedSize / math.Pow(float64(1024), sizeConversion)
+	}
+}
@@ -3,16 +3,60 @@ package util
 import (
 	"math"
 	"sync"
+	"time"
 )
 
 type CliOptions struct {
-	FilterByName         []string
-	FilterByStorageClass []string
-	OmitEmpty            bool
-	Regions              []string
-	OutputOptions        *OutputOptions
-	RateLimit            int
-	Threading            int
+	FilterByName            []string
+	FilterByStorageClass    []string
+	FilterByPrefix          []string
+	FilterByGlob            []string
+	FilterByRegex           []string
+	FilterByEncryption      []string
+	FilterByTag             []string
+	OmitEmpty               bool
+	IncludeVersions         bool
+	IncludeMultipartUploads bool
+	IncludeConfig           bool
+	FindDuplicates          bool
+	EncryptionSample        float64
+	Source                  string
+	InventoryLocations      []string
+	Regions                 []string
+	AllRegions              bool
+	LocationCache           string
+	LocationCacheTTL        time.Duration
+	OutputOptions           *OutputOptions
+	RateLimit               int
+	Threading               int
+	PartitionDepth          int
+	PrefixDepth             int
+	ResumeState             string
+	Timeout                 time.Duration
+	BucketTimeout           time.Duration
+	MaxRetries              int
+	RetryBudget             int
+	FailOnError             bool
+	// Lifecycle rules projected on the current objects by the simulate command
+	SimulationRules []LifecycleRule
+	// Monthly requests and transfers of the buckets by name with --usage
+	Usage map[string]BucketUsage
+	// The buckets without usage get theirs from the request metrics of the filter
+	UsageFromMetrics     bool
+	RequestMetricsFilter string
+}
+
+// Number of largest objects and prefixes to report with --top.
+func (options CliOptions) GetTop() int {
+	if options.OutputOptions == nil {
+		return 0
+	}
+	return options.OutputOptions.Top
+}
+
+// Whether the cost of the buckets is projected with their lifecycle rules.
+func (options CliOptions) GetLifecycleProjection() bool {
+	return options.OutputOptions != nil && options.OutputOptions.LifecycleProjection
 }
 
 type OutputOptions struct {
@@ -21,6 +65,14 @@ type OutputOptions struct {
 	OrderByInc     string
 	FileOutput     string
 	SizeConversion float64
+	// Report the buckets whose current objects are mostly older than the number of days, 0 to disable
+	StaleDays int64
+	// Number of largest objects and prefixes to report per bucket and for the account, 0 to disable
+	Top int
+	// Report the totals of the lifecycle simulation
+	Simulation bool
+	// Project the cost of the buckets with their own lifecycle rules
+	LifecycleProjection bool
 }
 
 type StorageClassSize struct {
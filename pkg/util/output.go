@@ -6,10 +6,22 @@ import (
 	"fmt"
 	"os"
 	"slices"
+	"strings"
 )
 
-func OutputData(buckets []CloudFilesystem, options OutputOptions, gloablStorageClass RegionsStorageMap) error {
+// Error that prevented a bucket or a region from being scanned completely.
+type ScanError struct {
+	Bucket string `json:",omitempty"`
+	Region string `json:",omitempty"`
+	Error  string
+}
+
+func OutputData(buckets []CloudFilesystem, options OutputOptions, gloablStorageClass RegionsStorageMap, duplicates *DuplicatesReport, runErrors []ScanError) error {
 	output := make(map[string]interface{})
+	// The errors are listed before the buckets are converted and grouped
+	if scanErrors := collectScanErrors(buckets, runErrors); len(scanErrors) != 0 {
+		output["Errors"] = scanErrors
+	}
 	if options.OrderByInc != "" {
 		buckets = orderByInc(options.OrderByInc, buckets)
 
@@ -17,44 +29,156 @@ func OutputData(buckets []CloudFilesystem, options OutputOptions, gloablStorageC
 		buckets = orderByDec(options.OrderByDec, buckets)
 
 	}
-	output["S3"] = buckets
-	output["S3"] = applyOutputOptions(buckets, options)
+	groups := applyOutputOptions(buckets, options)
+	output["S3"] = groups
+	output["S3-Totals"] = getGroupTotals(groups)
+	if options.StaleDays > 0 {
+		output["S3-Stale"] = getStaleBuckets(buckets)
+	}
+	if options.Top > 0 {
+		output["S3-Top"] = getTopReport(buckets, options.Top)
+	}
+	if options.LifecycleProjection {
+		output["S3-Projection"] = getProjectionTotals(buckets)
+	}
+	if options.Simulation {
+		output["S3-Simulation"] = getSimulationTotals(buckets)
+	}
+	if duplicates != nil {
+		duplicates.ApplySizeConversion(options.SizeConversion)
+		output["S3-Duplicates"] = duplicates
+	}
 	gloablStorageClass.ApplyConversion(options.SizeConversion)
 	output["S3-Stats"] = gloablStorageClass
 	data, err := json.MarshalIndent(output, "", "    ")
 	if err != nil {
 		return err
 	}
+	// The table of the prefixes goes to stderr when the JSON is printed, so the output can still be piped
 	if options.FileOutput != "" {
 		err := outputToFilePath(options.FileOutput, data)
 		if err != nil {
 			return err
 		}
-	} else {
-		fmt.Println(string(data))
+		return outputPrefixTable(os.Stdout, buckets)
 	}
-	return nil
+	fmt.Println(string(data))
+	return outputPrefixTable(os.Stderr, buckets)
+}
+
+// Add the errors that are not reported yet, the same error can be found by several steps of the run.
+func AppendScanErrors(scanErrors []ScanError, newErrors ...ScanError) []ScanError {
+	for _, scanError := range newErrors {
+		if !slices.Contains(scanErrors, scanError) {
+			scanErrors = append(scanErrors, scanError)
+		}
+	}
+	return scanErrors
+}
+
+// Errors of the run followed by the errors of the buckets that are not complete.
+func collectScanErrors(buckets []CloudFilesystem, runErrors []ScanError) []ScanError {
+	scanErrors := slices.Clone(runErrors)
+	for _, bucket := range buckets {
+		if bucket.GetError() != "" {
+			scanErrors = append(scanErrors, ScanError{Bucket: bucket.GetName(), Region: bucket.GetRegion(), Error: bucket.GetError()})
+		}
+	}
+	return scanErrors
 }
 
 func applyOutputOptions(data []CloudFilesystem, outputOptions OutputOptions) map[string][]CloudFilesystem {
 	applyConversion := outputOptions.SizeConversion > 0
-	applyGroupByRegion := outputOptions.GroupBy == "region"
 	output := make(map[string][]CloudFilesystem)
-	if applyConversion || applyGroupByRegion {
-		for _, bucket := range data {
-			if applyConversion {
-				bucket.ApplySizeConversion(outputOptions.SizeConversion)
-			}
-			if applyGroupByRegion {
-				output[bucket.GetRegion()] = append(output[bucket.GetRegion()], bucket)
-			} else {
-				output["Global"] = append(output["Global"], bucket)
-			}
+	for _, bucket := range data {
+		if applyConversion {
+			bucket.ApplySizeConversion(outputOptions.SizeConversion)
 		}
+		group := getGroup(bucket, outputOptions.GroupBy)
+		output[group] = append(output[group], bucket)
 	}
 	return output
 }
 
+// Group of a bucket for --group-by, all the buckets are in the Global group without grouping.
+// With tag:<key>, the buckets without the tag are in the untagged group.
+func getGroup(bucket CloudFilesystem, groupBy string) string {
+	if key, ok := strings.CutPrefix(groupBy, GROUP_BY_TAG_PREFIX); ok {
+		if value, ok := bucket.GetTags()[key]; ok {
+			return value
+		}
+		return GROUP_UNTAGGED
+	}
+	switch groupBy {
+	case "region":
+		return bucket.GetRegion()
+	case "encryption":
+		return bucket.GetEncryption()
+	default:
+		return "Global"
+	}
+}
+
+// Totals of a group of buckets, the size is in the unit of the output.
+type GroupTotal struct {
+	NbOfBuckets int
+	NbOfFiles   int64
+	Size        float64
+	// Size billed once the minimum billable sizes and the metadata of the archived objects are added
+	BillableSize float64
+	Cost         float64
+	// Monthly cost of the group by line item
+	CostBreakdown CostBreakdown
+}
+
+func getGroupTotals(groups map[string][]CloudFilesystem) map[string]GroupTotal {
+	totals := make(map[string]GroupTotal)
+	for group, buckets := range groups {
+		var total GroupTotal
+		for _, bucket := range buckets {
+			total.NbOfBuckets++
+			total.NbOfFiles += bucket.GetNbOfFiles()
+			total.Size += bucket.GetSizeOfBucket()
+			total.BillableSize += bucket.GetBillableSize()
+			total.Cost += bucket.GetCost()
+			total.CostBreakdown.add(bucket.GetCostBreakdown())
+		}
+		totals[group] = total
+	}
+	return totals
+}
+
+// Bucket whose current objects are mostly older than --stale-days, the sizes are in the unit of the output.
+type StaleBucket struct {
+	Name       string
+	Region     string
+	Size       float64
+	StaleSize  float64
+	StaleRatio float64
+}
+
+// Buckets with more than half of their size older than --stale-days, the most stale data first.
+func getStaleBuckets(buckets []CloudFilesystem) []StaleBucket {
+	staleBuckets := []StaleBucket{}
+	for _, bucket := range buckets {
+		size := bucket.GetSizeOfBucket()
+		if size <= 0 || 2*bucket.GetStaleSize() <= size {
+			continue
+		}
+		staleBuckets = append(staleBuckets, StaleBucket{
+			Name:       bucket.GetName(),
+			Region:     bucket.GetRegion(),
+			Size:       size,
+			StaleSize:  bucket.GetStaleSize(),
+			StaleRatio: bucket.GetStaleSize() / size,
+		})
+	}
+	slices.SortStableFunc(staleBuckets, func(a, b StaleBucket) int {
+		return cmp.Compare(b.StaleSize, a.StaleSize)
+	})
+	return staleBuckets
+}
+
 func orderByInc(key string, data []CloudFilesystem) []CloudFilesystem {
 	switch key {
 	case "cost":
@@ -293,3 +293,76 @@ func TestOrderByDec(t *testing.T) {
 		assert.Equal(t, test.output, output)
 	}
 }
+
+func TestCollectScanErrors(t *testing.T) {
+	buckets := []CloudFilesystem{
+		&BucketDTO{Name: "test1", Region: "ca-central-1", Status: BUCKET_STATUS_COMPLETE},
+		&BucketDTO{Name: "test2", Region: "ca-central-1", Status: BUCKET_STATUS_FAILED, Error: "AccessDenied"},
+		&BucketDTO{Name: "test3", Status: BUCKET_STATUS_FAILED, Error: "NoSuchBucket"},
+	}
+	runErrors := []ScanError{{Region: "us-east-1", Error: "invalid region"}}
+	assert.Equal(t, []ScanError{
+		{Region: "us-east-1", Error: "invalid region"},
+		{Bucket: "test2", Region: "ca-central-1", Error: "AccessDenied"},
+		{Bucket: "test3", Error: "NoSuchBucket"},
+	}, collectScanErrors(buckets, runErrors))
+	assert.Empty(t, collectScanErrors(buckets[:1], nil))
+}
+
+func TestAppendScanErrors(t *testing.T) {
+	scanErrors := []ScanError{{Region: "us-east-1", Error: "invalid region"}}
+	scanErrors = AppendScanErrors(scanErrors, ScanError{Region: "us-east-1", Error: "invalid region"}, ScanError{Region: "ca-central-1", Error: "invalid region"})
+	assert.Equal(t, []ScanError{
+		{Region: "us-east-1", Error: "invalid region"},
+		{Region: "ca-central-1", Error: "invalid region"},
+	}, scanErrors)
+}
+
+func TestGroupByEncryption(t *testing.T) {
+	input := []CloudFilesystem{
+		&BucketDTO{Name: "test1", Encryption: ENCRYPTION_SSE_S3},
+		&BucketDTO{Name: "test2", Encryption: ENCRYPTION_SSE_KMS},
+		&BucketDTO{Name: "test3", Encryption: ENCRYPTION_SSE_S3},
+	}
+	assert.Equal(t, map[string][]CloudFilesystem{
+		"SSE-S3":  {input[0], input[2]},
+		"SSE-KMS": {input[1]},
+	}, applyOutputOptions(input, OutputOptions{GroupBy: "encryption"}))
+	// Without grouping, all the buckets are in the same group
+	assert.Equal(t, map[string][]CloudFilesystem{
+		"Global": input,
+	}, applyOutputOptions(input, OutputOptions{}))
+}
+
+func TestGroupByTag(t *testing.T) {
+	input := []CloudFilesystem{
+		&BucketDTO{Name: "test1", NbOfFiles: 1, SizeOfBucket: 10, Cost: 1, Tags: map[string]string{"team": "data"}},
+		&BucketDTO{Name: "test2", NbOfFiles: 2, SizeOfBucket: 20, Cost: 2, Tags: map[string]string{"team": "web"}},
+		&BucketDTO{Name: "test3", NbOfFiles: 3, SizeOfBucket: 30, Cost: 3, Tags: map[string]string{"team": "data"}},
+		&BucketDTO{Name: "test4", NbOfFiles: 4, SizeOfBucket: 40, Cost: 4, Tags: map[string]string{"cost-center": "42"}},
+	}
+	groups := applyOutputOptions(input, OutputOptions{GroupBy: "tag:team"})
+	assert.Equal(t, map[string][]CloudFilesystem{
+		"data":     {input[0], input[2]},
+		"web":      {input[1]},
+		"untagged": {input[3]},
+	}, groups)
+	assert.Equal(t, map[string]GroupTotal{
+		"data":     {NbOfBuckets: 2, NbOfFiles: 4, Size: 40, Cost: 4},
+		"web":      {NbOfBuckets: 1, NbOfFiles: 2, Size: 20, Cost: 2},
+		"untagged": {NbOfBuckets: 1, NbOfFiles: 4, Size: 40, Cost: 4},
+	}, getGroupTotals(groups))
+}
+
+func TestGetStaleBuckets(t *testing.T) {
+	input := []CloudFilesystem{
+		&BucketDTO{Name: "test1", Region: "us-east-1", SizeOfBucket: 100, StaleSize: 60},
+		&BucketDTO{Name: "test2", Region: "us-east-1", SizeOfBucket: 100, StaleSize: 50},
+		&BucketDTO{Name: "test3", Region: "ca-central-1", SizeOfBucket: 200, StaleSize: 200},
+		&BucketDTO{Name: "test4", Region: "ca-central-1"},
+	}
+	assert.Equal(t, []StaleBucket{
+		{Name: "test3", Region: "ca-central-1", Size: 200, StaleSize: 200, StaleRatio: 1},
+		{Name: "test1", Region: "us-east-1", Size: 100, StaleSize: 60, StaleRatio: 0.6},
+	}, getStaleBuckets(input))
+}
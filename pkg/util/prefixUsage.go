@@ -0,0 +1,73 @@
+package util
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// Usage of the current objects under a prefix with --prefix-depth, including its sub-prefixes.
+// The objects directly under the bucket or under the deepest prefixes are only counted in the bucket or in those prefixes.
+type PrefixUsage struct {
+	Prefix           string
+	NbOfFiles        int64
+	Size             float64
+	StorageClassSize StorageClassSizeMap
+	LastModified     time.Time
+	Cost             float64
+	Prefixes         []*PrefixUsage `json:",omitempty"`
+}
+
+// Sort the prefixes by size, the largest first.
+func SortPrefixes(prefixes []*PrefixUsage) {
+	slices.SortStableFunc(prefixes, func(a, b *PrefixUsage) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), cmp.Compare(a.Prefix, b.Prefix))
+	})
+}
+
+func applyPrefixesSizeConversion(prefixes []*PrefixUsage, sizeConversion float64) {
+	for _, prefix := range prefixes {
+		prefix.Size = prefix.Size / math.Pow(float64(1024), sizeConversion)
+		for k, v := range prefix.StorageClassSize {
+			prefix.StorageClassSize[k] = v / math.Pow(float64(1024), sizeConversion)
+		}
+		applyPrefixesSizeConversion(prefix.Prefixes, sizeConversion)
+	}
+}
+
+type prefixRow struct {
+	bucket string
+	prefix *PrefixUsage
+}
+
+// Write the prefixes of all the buckets as a table sorted by size, the largest first.
+// The sizes are in the unit of the output.
+func outputPrefixTable(w io.Writer, buckets []CloudFilesystem) error {
+	var rows []prefixRow
+	var walk func(bucket string, prefixes []*PrefixUsage)
+	walk = func(bucket string, prefixes []*PrefixUsage) {
+		for _, prefix := range prefixes {
+			rows = append(rows, prefixRow{bucket: bucket, prefix: prefix})
+			walk(bucket, prefix.Prefixes)
+		}
+	}
+	for _, bucket := range buckets {
+		walk(bucket.GetName(), bucket.GetPrefixes())
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	slices.SortStableFunc(rows, func(a, b prefixRow) int {
+		return cmp.Or(cmp.Compare(b.prefix.Size, a.prefix.Size), cmp.Compare(a.bucket, b.bucket), cmp.Compare(a.prefix.Prefix, b.prefix.Prefix))
+	})
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "BUCKET\tPREFIX\tFILES\tSIZE\tCOST")
+	for _, row := range rows {
+		fmt.Fprintf(table, "%s\t%s\t%d\t%.2f\t%.2f\n", row.bucket, row.prefix.Prefix, row.prefix.NbOfFiles, row.prefix.Size, row.prefix.Cost)
+	}
+	return table.Flush()
+}
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputPrefixTable(t *testing.T) {
+	input := []CloudFilesystem{
+		&BucketDTO{Name: "test1", Prefixes: []*PrefixUsage{
+			{Prefix: "logs/", NbOfFiles: 3, Size: 2048, Cost: 1, Prefixes: []*PrefixUsage{
+				{Prefix: "logs/2024/", NbOfFiles: 2, Size: 1024, Cost: 0.5},
+			}},
+		}},
+		&BucketDTO{Name: "test2", Prefixes: []*PrefixUsage{
+			{Prefix: "data/", NbOfFiles: 1, Size: 1536, Cost: 0.75},
+		}},
+		&BucketDTO{Name: "test3"},
+	}
+	for _, bucket := range input {
+		bucket.ApplySizeConversion(SIZE_CONV_KB)
+	}
+	var output bytes.Buffer
+	assert.Nil(t, outputPrefixTable(&output, input))
+	assert.Equal(t, ""+
+		"BUCKET  PREFIX      FILES  SIZE  COST\n"+
+		"test1   logs/       3      2.00  1.00\n"+
+		"test2   data/       1      1.50  0.75\n"+
+		"test1   logs/2024/  2      1.00  0.50\n", output.String())
+
+	output.Reset()
+	assert.Nil(t, outputPrefixTable(&output, []CloudFilesystem{&BucketDTO{Name: "test3"}}))
+	assert.Empty(t, output.String())
+}
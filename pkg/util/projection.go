@@ -0,0 +1,56 @@
+package util
+
+import "math"
+
+// Cost of a bucket after a number of months once its lifecycle rules have been applied to the objects already
+// stored, without the objects added in between. The sizes are in the unit of the output and the costs are monthly.
+type CostProjection struct {
+	Months           int
+	Size             float64
+	StorageClassSize StorageClassSizeMap
+	// Priced with the minimum billable sizes and the metadata of the archived objects
+	BillableStorageClassSize StorageClassSizeMap `json:",omitempty"`
+	Cost                     float64
+	// Compared to the current cost of the bucket
+	Savings float64
+}
+
+func applyProjectionSizeConversion(projections []CostProjection, sizeConversion float64) {
+	for i := range projections {
+		projections[i].Size = projections[i].Size / math.Pow(float64(1024), sizeConversion)
+		for k, v := range projections[i].StorageClassSize {
+			projections[i].StorageClassSize[k] = v / math.Pow(float64(1024), sizeConversion)
+		}
+		for k, v := range projections[i].BillableStorageClassSize {
+			projections[i].BillableStorageClassSize[k] = v / math.Pow(float64(1024), sizeConversion)
+		}
+	}
+}
+
+// Projection of all the buckets after a number of months.
+type ProjectionTotal struct {
+	Months  int
+	Size    float64
+	Cost    float64
+	Savings float64
+}
+
+// Projections of all the buckets by number of months, the sizes are in the unit of the output.
+func getProjectionTotals(buckets []CloudFilesystem) []ProjectionTotal {
+	totals := make([]ProjectionTotal, len(PROJECTION_MONTHS))
+	for i, months := range PROJECTION_MONTHS {
+		totals[i].Months = months
+	}
+	for _, bucket := range buckets {
+		for _, projection := range bucket.GetLifecycleProjection() {
+			for i := range totals {
+				if totals[i].Months == projection.Months {
+					totals[i].Size += projection.Size
+					totals[i].Cost += projection.Cost
+					totals[i].Savings += projection.Savings
+				}
+			}
+		}
+	}
+	return totals
+}
@@ -0,0 +1,41 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProjectionTotals(t *testing.T) {
+	input := []CloudFilesystem{
+		&BucketDTO{
+			Name: "test1",
+			LifecycleProjection: []CostProjection{
+				{Months: 3, Size: 10, Cost: 4, Savings: 1},
+				{Months: 6, Size: 8, Cost: 3, Savings: 2},
+				{Months: 12, Size: 5, Cost: 2, Savings: 3},
+			},
+		},
+		&BucketDTO{
+			Name: "test2",
+			LifecycleProjection: []CostProjection{
+				{Months: 3, Size: 2, Cost: 1},
+				{Months: 6, Size: 2, Cost: 1},
+				{Months: 12, Size: 1, Cost: 0.5, Savings: 0.5},
+			},
+		},
+		&BucketDTO{Name: "test3"},
+	}
+	assert.Equal(t, []ProjectionTotal{
+		{Months: 3, Size: 12, Cost: 5, Savings: 1},
+		{Months: 6, Size: 10, Cost: 4, Savings: 2},
+		{Months: 12, Size: 6, Cost: 2.5, Savings: 3.5},
+	}, getProjectionTotals(input))
+}
+
+func TestApplyProjectionSizeConversion(t *testing.T) {
+	projections := []CostProjection{{Months: 3, Size: 2048, StorageClassSize: StorageClassSizeMap{"STANDARD": 1024}}}
+	applyProjectionSizeConversion(projections, 1)
+	assert.Equal(t, float64(2), projections[0].Size)
+	assert.Equal(t, float64(1), projections[0].StorageClassSize["STANDARD"])
+}
@@ -0,0 +1,89 @@
+package util
+
+import "math"
+
+// Projection of a lifecycle rule set on the current objects of a bucket with the simulate command, once the rules
+// have been applied to all of them. The sizes are in the unit of the output and the costs are monthly, except the
+// transition fees and the minimum duration penalties of the objects already stored that are only paid once.
+type Simulation struct {
+	CurrentStorageClassSize   StorageClassSizeMap
+	ProjectedStorageClassSize StorageClassSizeMap
+	// Priced with the minimum billable sizes and the metadata of the archived objects
+	CurrentBillableStorageClassSize   StorageClassSizeMap `json:",omitempty"`
+	ProjectedBillableStorageClassSize StorageClassSizeMap `json:",omitempty"`
+	// Objects moved by the rules, by target storage class
+	NbOfTransitions map[string]int64 `json:",omitempty"`
+	NbOfExpirations int64            `json:",omitempty"`
+	ExpiredSize     float64          `json:",omitempty"`
+	// Size of the objects moved or expired before the minimum storage duration of their storage class, multiplied by
+	// the months left, by storage class. The monthly size is the one of the rules whose stages are shorter than the minimum.
+	PenaltySize        StorageClassSizeMap `json:",omitempty"`
+	MonthlyPenaltySize StorageClassSizeMap `json:",omitempty"`
+
+	CurrentCost              float64
+	ProjectedCost            float64
+	TransitionFees           float64
+	MinimumDurationPenalties float64
+	// Penalties of the objects that keep reaching the end of a stage too short every month
+	MonthlyMinimumDurationPenalties float64
+	// Projected cost and monthly penalties compared to the current cost
+	MonthlySavings float64
+	// Months of savings needed to pay the transition fees and the penalties, only set when there are savings
+	PaybackMonths float64 `json:",omitempty"`
+}
+
+// Set the savings and the payback of the simulation from its costs.
+func (simulation *Simulation) ComputeSavings() {
+	simulation.MonthlySavings = simulation.CurrentCost - simulation.ProjectedCost - simulation.MonthlyMinimumDurationPenalties
+	simulation.PaybackMonths = 0
+	if simulation.MonthlySavings > 0 {
+		simulation.PaybackMonths = (simulation.TransitionFees + simulation.MinimumDurationPenalties) / simulation.MonthlySavings
+	}
+}
+
+func (simulation *Simulation) ApplySizeConversion(sizeConversion float64) {
+	for _, sizes := range []StorageClassSizeMap{simulation.CurrentStorageClassSize, simulation.ProjectedStorageClassSize, simulation.CurrentBillableStorageClassSize, simulation.ProjectedBillableStorageClassSize, simulation.PenaltySize, simulation.MonthlyPenaltySize} {
+		for k, v := range sizes {
+			sizes[k] = v / math.Pow(float64(1024), sizeConversion)
+		}
+	}
+	simulation.ExpiredSize = simulation.ExpiredSize / math.Pow(float64(1024), sizeConversion)
+}
+
+// Costs of the simulation for all the simulated buckets.
+type SimulationTotals struct {
+	NbOfBuckets                     int
+	CurrentCost                     float64
+	ProjectedCost                   float64
+	TransitionFees                  float64
+	MinimumDurationPenalties        float64
+	MonthlyMinimumDurationPenalties float64
+	MonthlySavings                  float64
+	PaybackMonths                   float64 `json:",omitempty"`
+}
+
+func getSimulationTotals(buckets []CloudFilesystem) SimulationTotals {
+	var totals SimulationTotals
+	var total Simulation
+	for _, bucket := range buckets {
+		simulation := bucket.GetSimulation()
+		if simulation == nil {
+			continue
+		}
+		totals.NbOfBuckets++
+		total.CurrentCost += simulation.CurrentCost
+		total.ProjectedCost += simulation.ProjectedCost
+		total.TransitionFees += simulation.TransitionFees
+		total.MinimumDurationPenalties += simulation.MinimumDurationPenalties
+		total.MonthlyMinimumDurationPenalties += simulation.MonthlyMinimumDurationPenalties
+	}
+	total.ComputeSavings()
+	totals.CurrentCost = total.CurrentCost
+	totals.ProjectedCost = total.ProjectedCost
+	totals.TransitionFees = total.TransitionFees
+	totals.MinimumDurationPenalties = total.MinimumDurationPenalties
+	totals.MonthlyMinimumDurationPenalties = total.MonthlyMinimumDurationPenalties
+	totals.MonthlySavings = total.MonthlySavings
+	totals.PaybackMonths = total.PaybackMonths
+	return totals
+}
@@ -0,0 +1,38 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSimulationTotals(t *testing.T) {
+	input := []CloudFilesystem{
+		&BucketDTO{
+			Name:       "test1",
+			Simulation: &Simulation{CurrentCost: 10, ProjectedCost: 4, TransitionFees: 3, MinimumDurationPenalties: 1, MonthlyMinimumDurationPenalties: 1},
+		},
+		&BucketDTO{
+			Name:       "test2",
+			Simulation: &Simulation{CurrentCost: 2, ProjectedCost: 3},
+		},
+		&BucketDTO{Name: "test3"},
+	}
+	assert.Equal(t, SimulationTotals{
+		NbOfBuckets:                     2,
+		CurrentCost:                     12,
+		ProjectedCost:                   7,
+		TransitionFees:                  3,
+		MinimumDurationPenalties:        1,
+		MonthlyMinimumDurationPenalties: 1,
+		MonthlySavings:                  4,
+		PaybackMonths:                   1,
+	}, getSimulationTotals(input))
+}
+
+func TestSimulationWithoutSavings(t *testing.T) {
+	simulation := &Simulation{CurrentCost: 2, ProjectedCost: 3, TransitionFees: 1, PaybackMonths: 5}
+	simulation.ComputeSavings()
+	assert.Equal(t, float64(-1), simulation.MonthlySavings)
+	assert.Equal(t, float64(0), simulation.PaybackMonths)
+}
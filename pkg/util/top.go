@@ -0,0 +1,75 @@
+package util
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+)
+
+// One of the largest objects with --top, the bucket is only set in the report of the account.
+type TopObject struct {
+	Bucket       string `json:",omitempty"`
+	Key          string
+	Size         float64
+	StorageClass string
+	LastModified time.Time
+}
+
+// One of the largest prefixes with --top, the bucket is only set in the report of the account.
+type TopPrefix struct {
+	Bucket           string `json:",omitempty"`
+	Prefix           string
+	NbOfFiles        int64
+	Size             float64
+	StorageClassSize StorageClassSizeMap
+	LastModified     time.Time
+}
+
+// Largest objects and prefixes of all the buckets.
+type TopReport struct {
+	Objects  []TopObject
+	Prefixes []TopPrefix
+}
+
+// Sort the prefixes by size, the largest first.
+func SortTopPrefixes(prefixes []TopPrefix) {
+	slices.SortStableFunc(prefixes, func(a, b TopPrefix) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), cmp.Compare(a.Bucket, b.Bucket), cmp.Compare(a.Prefix, b.Prefix))
+	})
+}
+
+// The largest objects and prefixes of the account are the largest of the buckets.
+func getTopReport(buckets []CloudFilesystem, top int) TopReport {
+	report := TopReport{Objects: []TopObject{}, Prefixes: []TopPrefix{}}
+	for _, bucket := range buckets {
+		objects, prefixes := bucket.GetLargest()
+		for _, object := range objects {
+			object.Bucket = bucket.GetName()
+			report.Objects = append(report.Objects, object)
+		}
+		for _, prefix := range prefixes {
+			prefix.Bucket = bucket.GetName()
+			report.Prefixes = append(report.Prefixes, prefix)
+		}
+	}
+	slices.SortStableFunc(report.Objects, func(a, b TopObject) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), cmp.Compare(a.Bucket, b.Bucket), cmp.Compare(a.Key, b.Key))
+	})
+	SortTopPrefixes(report.Prefixes)
+	report.Objects = report.Objects[:min(top, len(report.Objects))]
+	report.Prefixes = report.Prefixes[:min(top, len(report.Prefixes))]
+	return report
+}
+
+func applyTopSizeConversion(objects []TopObject, prefixes []TopPrefix, sizeConversion float64) {
+	for i := range objects {
+		objects[i].Size = objects[i].Size / math.Pow(float64(1024), sizeConversion)
+	}
+	for i := range prefixes {
+		prefixes[i].Size = prefixes[i].Size / math.Pow(float64(1024), sizeConversion)
+		for k, v := range prefixes[i].StorageClassSize {
+			prefixes[i].StorageClassSize[k] = v / math.Pow(float64(1024), sizeConversion)
+		}
+	}
+}
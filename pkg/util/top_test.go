@@ -0,0 +1,29 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTopReport(t *testing.T) {
+	input := []CloudFilesystem{
+		&BucketDTO{
+			Name:            "test1",
+			LargestObjects:  []TopObject{{Key: "a", Size: 30}, {Key: "b", Size: 10}},
+			LargestPrefixes: []TopPrefix{{Prefix: "logs/", Size: 40}},
+		},
+		&BucketDTO{
+			Name:            "test2",
+			LargestObjects:  []TopObject{{Key: "c", Size: 20}},
+			LargestPrefixes: []TopPrefix{{Prefix: "data/", Size: 20}, {Prefix: "tmp/", Size: 5}},
+		},
+		&BucketDTO{Name: "test3"},
+	}
+	assert.Equal(t, TopReport{
+		Objects:  []TopObject{{Bucket: "test1", Key: "a", Size: 30}, {Bucket: "test2", Key: "c", Size: 20}},
+		Prefixes: []TopPrefix{{Bucket: "test1", Prefix: "logs/", Size: 40}, {Bucket: "test2", Prefix: "data/", Size: 20}},
+	}, getTopReport(input, 2))
+	// The buckets keep their reports without the name of the bucket
+	assert.Equal(t, "", input[0].(*BucketDTO).LargestObjects[0].Bucket)
+}
@@ -0,0 +1,48 @@
+package util
+
+// Monthly volumes of a bucket billed on top of its storage. The requests and the data read are split over the
+// storage classes of the bucket by size, since their prices depend on the storage class.
+type BucketUsage struct {
+	// PUT, COPY, POST and LIST requests
+	Tier1Requests float64
+	// GET, SELECT and the other requests, the DELETE requests are free
+	Tier2Requests float64
+	// Data read from the bucket, billed for the storage classes with a retrieval fee
+	RetrievalGB float64
+	// Data transferred out to the internet
+	EgressGB float64
+	// Intelligent-Tiering objects of 128KB or more billed for monitoring, counted by the scan
+	MonitoredObjects int64
+	// usage file or metrics, empty when only the monitored objects are known
+	Source string `json:",omitempty"`
+}
+
+// Volumes of the usage file for a bucket, the usage of * applies to the buckets that are not in the file.
+func GetBucketUsage(usage map[string]BucketUsage, bucketName string) (BucketUsage, bool) {
+	if bucketUsage, ok := usage[bucketName]; ok {
+		return bucketUsage, true
+	}
+	bucketUsage, ok := usage[USAGE_ALL_BUCKETS]
+	return bucketUsage, ok
+}
+
+// Monthly cost of a bucket by line item.
+type CostBreakdown struct {
+	Storage    float64
+	Requests   float64
+	Retrieval  float64
+	Monitoring float64
+	Transfer   float64
+}
+
+func (breakdown CostBreakdown) Total() float64 {
+	return breakdown.Storage + breakdown.Requests + breakdown.Retrieval + breakdown.Monitoring + breakdown.Transfer
+}
+
+func (breakdown *CostBreakdown) add(other CostBreakdown) {
+	breakdown.Storage += other.Storage
+	breakdown.Requests += other.Requests
+	breakdown.Retrieval += other.Retrieval
+	breakdown.Monitoring += other.Monitoring
+	breakdown.Transfer += other.Transfer
+}
@@ -0,0 +1,33 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBucketUsage(t *testing.T) {
+	usage := map[string]BucketUsage{
+		"poc-1": {EgressGB: 1},
+		"*":     {Tier1Requests: 10},
+	}
+	bucketUsage, ok := GetBucketUsage(usage, "poc-1")
+	assert.True(t, ok)
+	assert.Equal(t, BucketUsage{EgressGB: 1}, bucketUsage)
+	bucketUsage, ok = GetBucketUsage(usage, "poc-2")
+	assert.True(t, ok)
+	assert.Equal(t, BucketUsage{Tier1Requests: 10}, bucketUsage)
+	_, ok = GetBucketUsage(nil, "poc-2")
+	assert.False(t, ok)
+}
+
+func TestGroupTotalsCostBreakdown(t *testing.T) {
+	bucket1 := &BucketDTO{Name: "test1"}
+	bucket1.SetCost(CostBreakdown{Storage: 1, Requests: 2, Transfer: 3})
+	bucket2 := &BucketDTO{Name: "test2"}
+	bucket2.SetCost(CostBreakdown{Storage: 4, Retrieval: 0.5, Monitoring: 0.25})
+	totals := getGroupTotals(map[string][]CloudFilesystem{"Global": {bucket1, bucket2}})
+	assert.Equal(t, float64(6), bucket1.GetCost())
+	assert.Equal(t, CostBreakdown{Storage: 5, Requests: 2, Retrieval: 0.5, Monitoring: 0.25, Transfer: 3}, totals["Global"].CostBreakdown)
+	assert.Equal(t, 10.75, totals["Global"].Cost)
+}